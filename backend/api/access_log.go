@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"proxy-subscription/models"
+	"proxy-subscription/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recordSubscriptionAccess 记录一次订阅下载，令牌和输出配置由前面的处理函数写入上下文
+func recordSubscriptionAccess(c *gin.Context, format string, cacheHit bool, bytes int) {
+	entry := models.AccessLog{
+		ClientIP:  c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Format:    format,
+		CacheHit:  cacheHit,
+		Bytes:     bytes,
+	}
+	if value, exists := c.Get("access_token"); exists {
+		if token, ok := value.(models.AccessToken); ok {
+			entry.TokenID = token.ID
+			entry.TokenLabel = token.Label
+		}
+	}
+	if value, exists := c.Get("profile_id"); exists {
+		if profileID, ok := value.(uint); ok {
+			entry.ProfileID = profileID
+		}
+	}
+	services.RecordAccess(entry)
+}
+
+// GetAccessLogs 获取最近的订阅访问日志，支持按令牌、输出配置和客户端IP过滤
+func GetAccessLogs(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的limit参数"})
+		return
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	query := models.DB.Model(&models.AccessLog{})
+	if tokenID := c.Query("token_id"); tokenID != "" {
+		query = query.Where("token_id = ?", tokenID)
+	}
+	if profileID := c.Query("profile_id"); profileID != "" {
+		query = query.Where("profile_id = ?", profileID)
+	}
+	if clientIP := c.Query("client_ip"); clientIP != "" {
+		query = query.Where("client_ip = ?", clientIP)
+	}
+
+	logs := make([]models.AccessLog, 0)
+	if err := query.Order("id DESC").Limit(limit).Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, logs)
+}
+
+// GetAccessStats 按客户端、格式和日期汇总最近若干天的订阅访问
+func GetAccessStats(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if err != nil || days <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的days参数"})
+		return
+	}
+
+	now := time.Now()
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1-days)
+	stats, err := services.SummarizeAccessLogs(since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
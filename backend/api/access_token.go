@@ -0,0 +1,269 @@
+package api
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"proxy-subscription/models"
+	"proxy-subscription/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessTokenRequest 创建或更新访问令牌的请求
+type AccessTokenRequest struct {
+	Label      string     `json:"label"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	AllowedIPs []string   `json:"allowed_ips"`
+}
+
+// trustedProxyNetworks 信任的反向代理地址，由 ConfigureTrustedProxies 设置
+var trustedProxyNetworks []*net.IPNet
+
+// ConfigureTrustedProxies 设置信任的反向代理（逗号分隔的IP或CIDR）。
+// 列表为空时不信任任何转发头，ClientIP始终返回连接的对端地址
+func ConfigureTrustedProxies(r *gin.Engine, proxies string) error {
+	trusted := make([]string, 0)
+	networks := make([]*net.IPNet, 0)
+	for _, entry := range strings.Split(proxies, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		trusted = append(trusted, entry)
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return errors.New("无效的IP或CIDR: " + entry)
+		}
+		networks = append(networks, network)
+	}
+	trustedProxyNetworks = networks
+	if len(trusted) == 0 {
+		return r.SetTrustedProxies(nil)
+	}
+	return r.SetTrustedProxies(trusted)
+}
+
+// isTrustedProxy 判断请求的对端地址是否为信任的反向代理
+func isTrustedProxy(c *gin.Context) bool {
+	ip := net.ParseIP(c.RemoteIP())
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxyNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// SubscriptionTokenRequired 订阅访问令牌中间件，令牌可放在路径参数或token查询参数中
+func SubscriptionTokenRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := c.Param("token")
+		if tokenString == "" {
+			tokenString = c.Query("token")
+		}
+		if tokenString == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "未提供访问令牌"})
+			c.Abort()
+			return
+		}
+
+		var token models.AccessToken
+		if err := models.DB.Where("token = ?", tokenString).First(&token).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的访问令牌"})
+			c.Abort()
+			return
+		}
+
+		now := time.Now()
+		if !token.IsActive(now) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "访问令牌已吊销或已过期"})
+			c.Abort()
+			return
+		}
+
+		clientIP := c.ClientIP()
+		if !token.AllowsIP(clientIP) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "当前IP不允许使用该访问令牌"})
+			c.Abort()
+			return
+		}
+
+		// 记录最近使用信息，失败不影响订阅获取
+		if err := models.DB.Model(&token).Updates(map[string]interface{}{
+			"last_used_at":    now,
+			"last_used_ip":    clientIP,
+			"last_user_agent": c.Request.UserAgent(),
+		}).Error; err != nil {
+			utils.Warn("更新访问令牌使用记录失败 ID=%d, 错误: %v", token.ID, err)
+		}
+
+		c.Set("access_token", token)
+		c.Next()
+	}
+}
+
+// GetAccessTokens 获取所有访问令牌
+func GetAccessTokens(c *gin.Context) {
+	tokens := make([]models.AccessToken, 0)
+	if err := models.DB.Order("id").Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// AddAccessToken 创建访问令牌
+func AddAccessToken(c *gin.Context) {
+	var req AccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	token := models.AccessToken{}
+	if err := applyAccessTokenRequest(&token, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret, err := utils.RandomToken(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
+		return
+	}
+	token.Token = secret
+
+	if err := models.DB.Create(&token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, token)
+}
+
+// UpdateAccessToken 更新访问令牌的标签、过期时间和IP白名单
+func UpdateAccessToken(c *gin.Context) {
+	token, ok := findAccessToken(c)
+	if !ok {
+		return
+	}
+
+	var req AccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+	if err := applyAccessTokenRequest(&token, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := models.DB.Save(&token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, token)
+}
+
+// RotateAccessToken 重新生成令牌值，旧链接立即失效
+func RotateAccessToken(c *gin.Context) {
+	token, ok := findAccessToken(c)
+	if !ok {
+		return
+	}
+
+	secret, err := utils.RandomToken(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
+		return
+	}
+	token.Token = secret
+	token.Revoked = false
+
+	if err := models.DB.Save(&token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, token)
+}
+
+// RevokeAccessToken 吊销访问令牌
+func RevokeAccessToken(c *gin.Context) {
+	token, ok := findAccessToken(c)
+	if !ok {
+		return
+	}
+
+	if err := models.DB.Model(&token).Update("revoked", true).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	token.Revoked = true
+	c.JSON(http.StatusOK, token)
+}
+
+// DeleteAccessToken 删除访问令牌
+func DeleteAccessToken(c *gin.Context) {
+	token, ok := findAccessToken(c)
+	if !ok {
+		return
+	}
+
+	if err := models.DB.Delete(&token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "访问令牌已删除"})
+}
+
+// findAccessToken 根据路径参数查找访问令牌，失败时直接写入响应
+func findAccessToken(c *gin.Context) (models.AccessToken, bool) {
+	var token models.AccessToken
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return token, false
+	}
+	if err := models.DB.First(&token, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "访问令牌不存在"})
+		return token, false
+	}
+	return token, true
+}
+
+// applyAccessTokenRequest 校验并写入可编辑的令牌字段
+func applyAccessTokenRequest(token *models.AccessToken, req AccessTokenRequest) error {
+	allowedIPs := make([]string, 0, len(req.AllowedIPs))
+	for _, entry := range req.AllowedIPs {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			if _, _, err := net.ParseCIDR(entry); err != nil {
+				return errors.New("无效的CIDR: " + entry)
+			}
+		} else if net.ParseIP(entry) == nil {
+			return errors.New("无效的IP地址: " + entry)
+		}
+		allowedIPs = append(allowedIPs, entry)
+	}
+
+	token.Label = strings.TrimSpace(req.Label)
+	token.ExpiresAt = req.ExpiresAt
+	token.AllowedIPs = allowedIPs
+	return nil
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"proxy-subscription/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTokenTestRouter(t *testing.T, trustedProxies string) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	if err := ConfigureTrustedProxies(r, trustedProxies); err != nil {
+		t.Fatalf("ConfigureTrustedProxies() error = %v", err)
+	}
+	r.GET("/merged/:token", SubscriptionTokenRequired(), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	return r
+}
+
+func TestSubscriptionTokenRequiredIgnoresSpoofedForwardedFor(t *testing.T) {
+	setupTestDB(t)
+	token := models.AccessToken{Token: "secret", AllowedIPs: []string{"198.51.100.9"}}
+	if err := models.DB.Create(&token).Error; err != nil {
+		t.Fatalf("create token error = %v", err)
+	}
+
+	request := func(r *gin.Engine, remoteAddr, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/merged/secret", nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	r := newTokenTestRouter(t, "")
+	assertEqual(t, request(r, "203.0.113.7:40000", "198.51.100.9"), http.StatusForbidden, "spoofed X-Forwarded-For")
+	assertEqual(t, request(r, "198.51.100.9:40000", ""), http.StatusOK, "allowed remote address")
+
+	var stored models.AccessToken
+	if err := models.DB.First(&stored, token.ID).Error; err != nil {
+		t.Fatalf("load token error = %v", err)
+	}
+	assertEqual(t, stored.LastUsedIP, "198.51.100.9", "last used ip")
+
+	// 来自信任代理的请求使用转发头中的客户端地址
+	r = newTokenTestRouter(t, "127.0.0.1, 10.0.0.0/8")
+	assertEqual(t, request(r, "10.0.0.2:40000", "198.51.100.9"), http.StatusOK, "trusted proxy forwarded")
+	assertEqual(t, request(r, "203.0.113.7:40000", "198.51.100.9"), http.StatusForbidden, "untrusted proxy forwarded")
+}
+
+func TestSubscriptionTokenRequiredRejectsInactiveTokens(t *testing.T) {
+	setupTestDB(t)
+	token := models.AccessToken{Token: "revoked"}
+	if err := models.DB.Create(&token).Error; err != nil {
+		t.Fatalf("create token error = %v", err)
+	}
+	if err := models.DB.Model(&token).Update("revoked", true).Error; err != nil {
+		t.Fatalf("revoke token error = %v", err)
+	}
+
+	r := newTokenTestRouter(t, "")
+	for path, want := range map[string]int{
+		"/merged/revoked": http.StatusUnauthorized,
+		"/merged/missing": http.StatusUnauthorized,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assertEqual(t, w.Code, want, path)
+	}
+}
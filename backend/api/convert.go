@@ -0,0 +1,92 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"proxy-subscription/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxConvertBodySize POST转换时允许的最大订阅内容
+const maxConvertBodySize = 10 << 20
+
+// ConvertSubscription 拉取订阅URL并转换为目标格式，不保存任何数据
+func ConvertSubscription(c *gin.Context) {
+	url := strings.TrimSpace(c.Query("url"))
+	if url == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请提供订阅URL"})
+		return
+	}
+
+	// 该接口只需访问令牌，禁止借此访问服务器所在的内网
+	fetched, err := services.FetchSubscription(url, services.FetchOptions{UserAgent: c.Query("ua"), PublicOnly: true})
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "status_code": fetched.StatusCode})
+		return
+	}
+	convertSubscriptionContent(c, fetched.Content)
+}
+
+// ConvertSubscriptionContent 将请求体中的原始订阅内容转换为目标格式，不保存任何数据
+func ConvertSubscriptionContent(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxConvertBodySize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取请求内容失败"})
+		return
+	}
+	if len(body) > maxConvertBodySize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "订阅内容过大"})
+		return
+	}
+	if strings.TrimSpace(string(body)) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请提供订阅内容"})
+		return
+	}
+	convertSubscriptionContent(c, string(body))
+}
+
+// convertSubscriptionContent 依次执行解析、过滤、重名处理和生成，并写入响应
+func convertSubscriptionContent(c *gin.Context, content string) {
+	format := c.Query("target")
+	if format == "" {
+		format = resolveOutputFormat(c, "")
+	}
+	if !isSupportedFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的格式: " + format})
+		return
+	}
+
+	include, exclude := c.Query("include"), c.Query("exclude")
+	if err := services.ValidateNameFilters(include, exclude, nil); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := services.ParseSubscription(content, c.Query("type"))
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "report": result})
+		return
+	}
+
+	proxies, err := services.FilterProxiesByName(result.Proxies, include, exclude)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	proxies = services.EnsureUniqueNames(proxies)
+
+	generated, err := generateSubscriptionContent(proxies, format, GenerateOptions{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	item := newSubscriptionItem(generated, format)
+	for key, value := range item.Headers {
+		c.Header(key, value)
+	}
+	c.Header("Content-Type", item.ContentType)
+	c.String(http.StatusOK, item.Content)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"proxy-subscription/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCountryAliases 获取生效的国家别名表及设置中的覆盖项
+func GetCountryAliases(c *gin.Context) {
+	overrides := models.LoadCountryAliases()
+	table := models.CountryAliasTable(overrides)
+	aliases := make([]models.CountryAlias, 0, len(table))
+	for alias, code := range table {
+		aliases = append(aliases, models.CountryAlias{Alias: alias, Code: code})
+	}
+	sort.Slice(aliases, func(i, j int) bool {
+		if aliases[i].Code != aliases[j].Code {
+			return aliases[i].Code < aliases[j].Code
+		}
+		return aliases[i].Alias < aliases[j].Alias
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"aliases":   aliases,
+		"overrides": overrides,
+	})
+}
+
+// MatchCountryName 测试节点名称会被识别为哪个国家
+func MatchCountryName(c *gin.Context) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "节点名称不能为空"})
+		return
+	}
+
+	alias, code := models.MatchCountry(req.Name)
+	proxy := models.Proxy{Name: req.Name}
+	c.JSON(http.StatusOK, gin.H{
+		"alias":        alias,
+		"code":         code,
+		"display_name": proxy.GetDisplayName(),
+		"flag":         models.CountryFlag(code),
+	})
+}
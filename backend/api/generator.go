@@ -0,0 +1,733 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"proxy-subscription/models"
+	"proxy-subscription/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GenerateOptions 生成订阅内容的选项，随输出格式的需要扩展
+type GenerateOptions struct {
+	RegionGroups   services.RegionGroupOptions // 按地区生成节点分组，Clash和sing-box使用
+	RuleSets       []models.RuleSet            // 在配置中引用的规则集，Clash、sing-box和Surge使用
+	RuleSetBaseURL string                      // 托管规则集的地址前缀，后接"/规则集名称"
+}
+
+// hasRuleSets 是否需要在配置中引用规则集
+func (options GenerateOptions) hasRuleSets() bool {
+	return len(options.RuleSets) > 0 && options.RuleSetBaseURL != ""
+}
+
+// ruleSetURL 返回托管规则集的地址
+func (options GenerateOptions) ruleSetURL(name, format string) string {
+	return options.RuleSetBaseURL + "/" + name + "?format=" + format
+}
+
+// Generator 订阅输出格式生成器
+type Generator interface {
+	// Name 格式名称，即format参数的取值
+	Name() string
+	// ContentType 响应的Content-Type
+	ContentType() string
+	// Description 格式说明
+	Description() string
+	// SupportedTypes 能够表达的节点类型，其他类型的节点会被跳过；nil表示支持全部类型
+	SupportedTypes() []string
+	// SupportsChains 能否表达通过前置节点连接的链式代理，不能表达时跳过链式节点
+	SupportsChains() bool
+	// Generate 生成订阅内容，传入的节点均属于SupportedTypes
+	Generate(proxies []models.Proxy, options GenerateOptions) (string, error)
+}
+
+// GeneratorInfo 输出格式的描述信息
+type GeneratorInfo struct {
+	Name           string   `json:"name"`
+	ContentType    string   `json:"content_type"`
+	Description    string   `json:"description"`
+	SupportedTypes []string `json:"supported_types"`
+	SupportsChains bool     `json:"supports_chains"`
+}
+
+// GeneratedSubscription 生成结果，Skipped为格式无法表达而被跳过的节点，SkippedChains为被跳过的链式节点
+type GeneratedSubscription struct {
+	Content       string
+	ContentType   string
+	Skipped       []models.Proxy
+	SkippedChains []services.SkippedChain
+}
+
+// 已注册的生成器，generatorOrder保持注册顺序
+var (
+	generators     = make(map[string]Generator)
+	generatorOrder []string
+)
+
+func init() {
+	RegisterGenerator(base64Generator{})
+	RegisterGenerator(clashGenerator{})
+	RegisterGenerator(jsonGenerator{})
+	RegisterGenerator(singboxGenerator{})
+	RegisterGenerator(surgeGenerator{})
+}
+
+// RegisterGenerator 注册输出格式生成器，同名生成器会被替换
+func RegisterGenerator(generator Generator) {
+	name := generator.Name()
+	if _, exists := generators[name]; !exists {
+		generatorOrder = append(generatorOrder, name)
+	}
+	generators[name] = generator
+}
+
+// GetGenerator 按名称获取生成器
+func GetGenerator(name string) (Generator, bool) {
+	generator, ok := generators[name]
+	return generator, ok
+}
+
+// isSupportedFormat 检查是否支持该输出格式
+func isSupportedFormat(name string) bool {
+	_, ok := GetGenerator(name)
+	return ok
+}
+
+// GetOutputFormats 获取支持的输出格式、Content-Type及可表达的节点类型
+func GetOutputFormats(c *gin.Context) {
+	formats := make([]GeneratorInfo, 0, len(generatorOrder))
+	for _, name := range generatorOrder {
+		generator := generators[name]
+		formats = append(formats, GeneratorInfo{
+			Name:           generator.Name(),
+			ContentType:    generator.ContentType(),
+			Description:    generator.Description(),
+			SupportedTypes: generator.SupportedTypes(),
+			SupportsChains: generator.SupportsChains(),
+		})
+	}
+	c.JSON(http.StatusOK, formats)
+}
+
+// generateSubscriptionContent 使用指定格式的生成器生成订阅内容，跳过该格式不支持的节点。
+// 格式不支持链式代理时跳过链式节点，前置节点被跳过的链式节点也一并跳过
+func generateSubscriptionContent(proxies []models.Proxy, format string, options GenerateOptions) (GeneratedSubscription, error) {
+	generator, ok := GetGenerator(format)
+	if !ok {
+		return GeneratedSubscription{}, errors.New("不支持的格式: " + format)
+	}
+
+	supportedTypes := generator.SupportedTypes()
+	supported := make(map[string]struct{}, len(supportedTypes))
+	for _, proxyType := range supportedTypes {
+		supported[proxyType] = struct{}{}
+	}
+
+	accepted := make([]models.Proxy, 0, len(proxies))
+	skipped := make([]models.Proxy, 0)
+	skippedChains := make([]services.SkippedChain, 0)
+	for _, proxy := range proxies {
+		if _, ok := supported[proxy.Type]; !ok && supportedTypes != nil {
+			skipped = append(skipped, proxy)
+			continue
+		}
+		if proxy.DialerProxy != "" && !generator.SupportsChains() {
+			skippedChains = append(skippedChains, services.SkippedChain{Name: proxy.Name, Via: proxy.DialerProxy, Reason: "该格式不支持链式代理"})
+			continue
+		}
+		accepted = append(accepted, proxy)
+	}
+	accepted, broken := services.DropBrokenChains(accepted)
+	skippedChains = append(skippedChains, broken...)
+
+	content, err := generator.Generate(accepted, options)
+	if err != nil {
+		return GeneratedSubscription{}, err
+	}
+	return GeneratedSubscription{
+		Content:       content,
+		ContentType:   generator.ContentType(),
+		Skipped:       skipped,
+		SkippedChains: skippedChains,
+	}, nil
+}
+
+// skippedTypes 返回被跳过节点的类型列表（去重并排序）
+func skippedTypes(skipped []models.Proxy) []string {
+	seen := make(map[string]struct{})
+	types := make([]string, 0)
+	for _, proxy := range skipped {
+		if _, exists := seen[proxy.Type]; exists {
+			continue
+		}
+		seen[proxy.Type] = struct{}{}
+		types = append(types, proxy.Type)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// proxyRawConfig 解析节点的RawConfig，无效时返回空map
+func proxyRawConfig(proxy models.Proxy) map[string]interface{} {
+	rawConfig := map[string]interface{}{}
+	if proxy.RawConfig != "" {
+		_ = json.Unmarshal([]byte(proxy.RawConfig), &rawConfig)
+	}
+	return rawConfig
+}
+
+// rawConfigValue 按顺序读取RawConfig中第一个非空的字符串字段
+func rawConfigValue(rawConfig map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := rawConfig[key].(string); ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// rawConfigInt 读取RawConfig中的整数字段，兼容数字和字符串形式，如 "100" 或 "100 Mbps"
+func rawConfigInt(rawConfig map[string]interface{}, key string) int {
+	switch value := rawConfig[key].(type) {
+	case float64:
+		return int(value)
+	case string:
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			return 0
+		}
+		number, _ := strconv.Atoi(fields[0])
+		return number
+	}
+	return 0
+}
+
+// parsePluginOpts 解析形如 "obfs=http;obfs-host=example.com" 的插件参数
+func parsePluginOpts(pluginOpts string) map[string]string {
+	opts := make(map[string]string)
+	for _, opt := range strings.Split(pluginOpts, ";") {
+		if kv := strings.SplitN(opt, "=", 2); len(kv) == 2 {
+			opts[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return opts
+}
+
+// splitList 拆分逗号分隔的列表并去除空白
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// base64Generator 生成Base64编码的分享链接列表
+type base64Generator struct{}
+
+// shareLinkGenerators 各节点类型的分享链接生成函数
+var shareLinkGenerators = map[string]func(models.Proxy) string{
+	"vmess":     generateVmessURL,
+	"vless":     generateVlessURL,
+	"ss":        generateSSURL,
+	"trojan":    generateTrojanURL,
+	"tuic":      generateCredentialProxyURL,
+	"anytls":    generateCredentialProxyURL,
+	"hysteria2": generateCredentialProxyURL,
+	"hysteria":  generateHysteriaURL,
+	"wireguard": generateWireGuardURL,
+	"ssr":       generateSSRURL,
+	"http":      generateAuthProxyURL,
+	"socks":     generateAuthProxyURL,
+}
+
+func (base64Generator) Name() string        { return "base64" }
+func (base64Generator) ContentType() string { return "text/plain;charset=utf-8" }
+func (base64Generator) Description() string {
+	return "Base64编码的分享链接（v2rayN/NekoRay/Shadowrocket）"
+}
+
+func (base64Generator) SupportedTypes() []string {
+	return []string{"vmess", "vless", "ss", "ssr", "trojan", "tuic", "anytls", "hysteria2", "hysteria", "wireguard", "http", "socks"}
+}
+
+// SupportsChains 分享链接无法引用其他节点
+func (base64Generator) SupportsChains() bool { return false }
+
+func (base64Generator) Generate(proxies []models.Proxy, options GenerateOptions) (string, error) {
+	var content strings.Builder
+	for _, proxy := range proxies {
+		generate, ok := shareLinkGenerators[proxy.Type]
+		if !ok {
+			continue
+		}
+		if proxyURL := generate(proxy); proxyURL != "" {
+			content.WriteString(proxyURL + "\n")
+		}
+	}
+	return base64.StdEncoding.EncodeToString([]byte(content.String())), nil
+}
+
+// clashGenerator 生成Clash/mihomo的proxies配置，启用地区分组时附带proxy-groups
+type clashGenerator struct{}
+
+func (clashGenerator) Name() string        { return "clash" }
+func (clashGenerator) ContentType() string { return "text/yaml;charset=utf-8" }
+func (clashGenerator) Description() string { return "Clash/mihomo 配置" }
+
+func (clashGenerator) SupportedTypes() []string {
+	return []string{"ss", "ssr", "vmess", "vless", "trojan", "tuic", "anytls", "hysteria2", "hysteria", "wireguard", "http", "socks"}
+}
+
+// SupportsChains 链式节点通过mihomo的dialer-proxy字段指定前置节点
+func (clashGenerator) SupportsChains() bool { return true }
+
+func (clashGenerator) Generate(proxies []models.Proxy, options GenerateOptions) (string, error) {
+	config := generateClashConfig(proxies)
+
+	// 引用规则集时需要一个供规则指向的代理分组
+	mainGroup := ""
+	reserved := []string{"DIRECT", "REJECT"}
+	if options.hasRuleSets() {
+		mainGroup = uniqueProxyName(clashMainGroup, proxies)
+		reserved = append(reserved, mainGroup)
+	}
+	groups := services.GroupProxiesByRegion(proxies, options.RegionGroups, reserved...)
+	if mainGroup != "" || len(groups) > 0 {
+		config += generateClashProxyGroups(mainGroup, proxies, groups)
+	}
+	if mainGroup != "" {
+		config += generateClashRules(mainGroup, options)
+	}
+	return config, nil
+}
+
+// clashMainGroup Clash配置中选择节点的分组名称
+const clashMainGroup = "Proxy"
+
+// uniqueProxyName 与节点重名时追加序号
+func uniqueProxyName(name string, proxies []models.Proxy) string {
+	used := make(map[string]struct{}, len(proxies))
+	for _, proxy := range proxies {
+		used[proxy.Name] = struct{}{}
+	}
+	candidate := name
+	for suffix := 2; ; suffix++ {
+		if _, exists := used[candidate]; !exists {
+			return candidate
+		}
+		candidate = name + " " + strconv.Itoa(suffix)
+	}
+}
+
+// generateClashProxyGroups 生成proxy-groups配置，mainGroup不为空时先生成包含地区分组和全部节点的手动选择分组
+func generateClashProxyGroups(mainGroup string, proxies []models.Proxy, groups []services.RegionGroup) string {
+	var yaml strings.Builder
+	yaml.WriteString("proxy-groups:\n")
+	if mainGroup != "" {
+		yaml.WriteString("  - name: " + yamlString(mainGroup) + "\n")
+		yaml.WriteString("    type: select\n")
+		yaml.WriteString("    proxies:\n")
+		for _, group := range groups {
+			yaml.WriteString("      - " + yamlString(group.Name) + "\n")
+		}
+		for _, proxy := range proxies {
+			yaml.WriteString("      - " + yamlString(proxy.Name) + "\n")
+		}
+		if len(groups) == 0 && len(proxies) == 0 {
+			yaml.WriteString("      - DIRECT\n")
+		}
+		yaml.WriteString("\n")
+	}
+	for _, group := range groups {
+		yaml.WriteString("  - name: " + yamlString(group.Name) + "\n")
+		yaml.WriteString("    type: " + group.Type + "\n")
+		if group.Type == services.RegionGroupURLTest {
+			yaml.WriteString("    url: http://www.gstatic.com/generate_204\n")
+			yaml.WriteString("    interval: 300\n")
+		}
+		yaml.WriteString("    proxies:\n")
+		for _, name := range group.Proxies {
+			yaml.WriteString("      - " + yamlString(name) + "\n")
+		}
+		yaml.WriteString("\n")
+	}
+	return yaml.String()
+}
+
+// generateClashRules 生成引用托管规则集的rule-providers和rules，未匹配的流量使用mainGroup
+func generateClashRules(mainGroup string, options GenerateOptions) string {
+	var yaml strings.Builder
+	yaml.WriteString("rule-providers:\n")
+	for _, ruleSet := range options.RuleSets {
+		yaml.WriteString("  " + ruleSet.Name + ":\n")
+		yaml.WriteString("    type: http\n")
+		yaml.WriteString("    behavior: classical\n")
+		yaml.WriteString("    url: \"" + options.ruleSetURL(ruleSet.Name, "clash") + "\"\n")
+		yaml.WriteString("    path: ./rule-providers/" + ruleSet.Name + ".yaml\n")
+		yaml.WriteString("    interval: 86400\n")
+	}
+	yaml.WriteString("\nrules:\n")
+	for _, ruleSet := range options.RuleSets {
+		yaml.WriteString("  - RULE-SET," + ruleSet.Name + "," + clashRulePolicy(ruleSet.Policy, mainGroup) + "\n")
+	}
+	yaml.WriteString("  - MATCH," + mainGroup + "\n")
+	return yaml.String()
+}
+
+// clashRulePolicy 规则集策略在Clash中对应的策略名称
+func clashRulePolicy(policy, mainGroup string) string {
+	switch policy {
+	case models.RulePolicyDirect:
+		return "DIRECT"
+	case models.RulePolicyReject:
+		return "REJECT"
+	default:
+		return mainGroup
+	}
+}
+
+// jsonGenerator 生成通用的JSON节点列表，可表达所有节点类型
+type jsonGenerator struct{}
+
+func (jsonGenerator) Name() string             { return "json" }
+func (jsonGenerator) ContentType() string      { return "application/json;charset=utf-8" }
+func (jsonGenerator) Description() string      { return "JSON 节点列表" }
+func (jsonGenerator) SupportedTypes() []string { return nil }
+func (jsonGenerator) SupportsChains() bool     { return true }
+
+func (jsonGenerator) Generate(proxies []models.Proxy, options GenerateOptions) (string, error) {
+	return generateJSONConfig(proxies)
+}
+
+// generateClashConfig 生成Clash配置
+func generateClashConfig(proxies []models.Proxy) string {
+	// 实现Clash配置生成逻辑
+	var yaml strings.Builder
+
+	yaml.WriteString("proxies:\n")
+	for _, proxy := range proxies {
+		// 根据代理类型生成对应的Clash配置
+		yaml.WriteString("  - name: " + yamlString(proxy.Name) + "\n")
+		yaml.WriteString("    type: " + clashProxyType(proxy.Type) + "\n")
+		yaml.WriteString("    server: " + yamlString(proxy.Server) + "\n")
+		yaml.WriteString("    port: " + strconv.Itoa(proxy.Port) + "\n")
+
+		// 根据代理类型添加特定配置
+		switch proxy.Type {
+		case "ss":
+			yaml.WriteString("    cipher: " + yamlString(proxy.Method) + "\n")
+			yaml.WriteString("    password: " + yamlString(proxy.Password) + "\n")
+
+			// 添加插件配置
+			if proxy.Plugin != "" {
+				yaml.WriteString("    plugin: " + yamlString(proxy.Plugin) + "\n")
+				if proxy.PluginOpts != "" {
+					yaml.WriteString("    plugin-opts:\n")
+					// 解析插件选项
+					opts := strings.Split(proxy.PluginOpts, ";")
+					for _, opt := range opts {
+						if kv := strings.SplitN(opt, "=", 2); len(kv) == 2 {
+							yaml.WriteString("      " + yamlString(kv[0]) + ": " + yamlString(kv[1]) + "\n")
+						}
+					}
+				}
+			}
+
+		case "vmess":
+			yaml.WriteString("    uuid: " + yamlString(proxy.UUID) + "\n")
+			if proxy.Network != "" {
+				yaml.WriteString("    network: " + yamlString(proxy.Network) + "\n")
+			}
+			if proxy.TLS {
+				yaml.WriteString("    tls: true\n")
+			}
+			if proxy.Path != "" {
+				yaml.WriteString("    ws-path: " + yamlString(proxy.Path) + "\n")
+			}
+			if proxy.Host != "" {
+				yaml.WriteString("    ws-headers:\n")
+				yaml.WriteString("      Host: " + yamlString(proxy.Host) + "\n")
+			}
+
+		case "vless":
+			yaml.WriteString("    uuid: " + yamlString(proxy.UUID) + "\n")
+			if proxy.Network != "" {
+				yaml.WriteString("    network: " + yamlString(proxy.Network) + "\n")
+			}
+			if proxy.TLS {
+				yaml.WriteString("    tls: true\n")
+			}
+			if proxy.SNI != "" {
+				yaml.WriteString("    servername: " + yamlString(proxy.SNI) + "\n")
+			}
+			if proxy.Path != "" {
+				yaml.WriteString("    ws-path: " + yamlString(proxy.Path) + "\n")
+			}
+			if proxy.Host != "" {
+				yaml.WriteString("    ws-headers:\n")
+				yaml.WriteString("      Host: " + yamlString(proxy.Host) + "\n")
+			}
+
+		case "trojan":
+			yaml.WriteString("    password: " + yamlString(proxy.Password) + "\n")
+			if proxy.SNI != "" {
+				yaml.WriteString("    sni: " + yamlString(proxy.SNI) + "\n")
+			}
+			if proxy.ALPN != "" {
+				yaml.WriteString("    alpn:\n")
+				for _, alpn := range strings.Split(proxy.ALPN, ",") {
+					yaml.WriteString("      - " + yamlString(alpn) + "\n")
+				}
+			}
+			if proxy.AllowInsecure {
+				yaml.WriteString("    skip-cert-verify: true\n")
+			}
+		case "tuic":
+			yaml.WriteString("    uuid: " + yamlString(proxy.UUID) + "\n")
+			yaml.WriteString("    password: " + yamlString(proxy.Password) + "\n")
+			if proxy.SNI != "" {
+				yaml.WriteString("    sni: " + yamlString(proxy.SNI) + "\n")
+			}
+			if proxy.ALPN != "" {
+				yaml.WriteString("    alpn:\n")
+				for _, alpn := range strings.Split(proxy.ALPN, ",") {
+					yaml.WriteString("      - " + yamlString(strings.TrimSpace(alpn)) + "\n")
+				}
+			}
+			if proxy.AllowInsecure {
+				yaml.WriteString("    skip-cert-verify: true\n")
+			}
+		case "anytls", "hysteria2":
+			yaml.WriteString("    password: " + yamlString(proxy.Password) + "\n")
+			if obfs := rawConfigValue(proxyRawConfig(proxy), "obfs"); proxy.Type == "hysteria2" && obfs != "" {
+				yaml.WriteString("    obfs: " + yamlString(obfs) + "\n")
+				yaml.WriteString("    obfs-password: " + yamlString(rawConfigValue(proxyRawConfig(proxy), "obfs-password", "obfs_password")) + "\n")
+			}
+			if proxy.SNI != "" {
+				yaml.WriteString("    sni: " + yamlString(proxy.SNI) + "\n")
+			}
+			if proxy.ALPN != "" {
+				yaml.WriteString("    alpn:\n")
+				for _, alpn := range strings.Split(proxy.ALPN, ",") {
+					yaml.WriteString("      - " + yamlString(strings.TrimSpace(alpn)) + "\n")
+				}
+			}
+			if proxy.AllowInsecure {
+				yaml.WriteString("    skip-cert-verify: true\n")
+			}
+		case "ssr":
+			rawConfig := proxyRawConfig(proxy)
+			yaml.WriteString("    cipher: " + yamlString(proxy.Method) + "\n")
+			yaml.WriteString("    password: " + yamlString(proxy.Password) + "\n")
+			yaml.WriteString("    protocol: " + yamlString(firstNonEmpty(rawConfigValue(rawConfig, "protocol"), "origin")) + "\n")
+			yaml.WriteString("    obfs: " + yamlString(firstNonEmpty(rawConfigValue(rawConfig, "obfs"), "plain")) + "\n")
+			if protoparam := rawConfigValue(rawConfig, "protoparam"); protoparam != "" {
+				yaml.WriteString("    protocol-param: " + yamlString(protoparam) + "\n")
+			}
+			if obfsparam := rawConfigValue(rawConfig, "obfsparam"); obfsparam != "" {
+				yaml.WriteString("    obfs-param: " + yamlString(obfsparam) + "\n")
+			}
+		case "http", "socks":
+			if username := rawConfigValue(proxyRawConfig(proxy), "username"); username != "" {
+				yaml.WriteString("    username: " + yamlString(username) + "\n")
+			}
+			if proxy.Password != "" {
+				yaml.WriteString("    password: " + yamlString(proxy.Password) + "\n")
+			}
+			if proxy.TLS {
+				yaml.WriteString("    tls: true\n")
+			}
+			if proxy.SNI != "" {
+				yaml.WriteString("    sni: " + yamlString(proxy.SNI) + "\n")
+			}
+			if proxy.AllowInsecure {
+				yaml.WriteString("    skip-cert-verify: true\n")
+			}
+		case "hysteria":
+			rawConfig := proxyRawConfig(proxy)
+			if proxy.Password != "" {
+				yaml.WriteString("    auth-str: " + yamlString(proxy.Password) + "\n")
+			}
+			yaml.WriteString("    up: " + strconv.Itoa(rawConfigInt(rawConfig, "up")) + "\n")
+			yaml.WriteString("    down: " + strconv.Itoa(rawConfigInt(rawConfig, "down")) + "\n")
+			if protocol := rawConfigValue(rawConfig, "protocol"); protocol != "" {
+				yaml.WriteString("    protocol: " + yamlString(protocol) + "\n")
+			}
+			if obfs := rawConfigValue(rawConfig, "obfs"); obfs != "" {
+				yaml.WriteString("    obfs: " + yamlString(obfs) + "\n")
+			}
+			if proxy.SNI != "" {
+				yaml.WriteString("    sni: " + yamlString(proxy.SNI) + "\n")
+			}
+			if proxy.ALPN != "" {
+				yaml.WriteString("    alpn:\n")
+				for _, alpn := range splitList(proxy.ALPN) {
+					yaml.WriteString("      - " + yamlString(alpn) + "\n")
+				}
+			}
+			if proxy.AllowInsecure {
+				yaml.WriteString("    skip-cert-verify: true\n")
+			}
+		case "wireguard":
+			rawConfig := proxyRawConfig(proxy)
+			// Clash的ip/ipv6字段不带前缀长度
+			if ip := rawConfigValue(rawConfig, "ip"); ip != "" {
+				yaml.WriteString("    ip: " + yamlString(strings.SplitN(ip, "/", 2)[0]) + "\n")
+			}
+			if ipv6 := rawConfigValue(rawConfig, "ipv6"); ipv6 != "" {
+				yaml.WriteString("    ipv6: " + yamlString(strings.SplitN(ipv6, "/", 2)[0]) + "\n")
+			}
+			yaml.WriteString("    private-key: " + yamlString(rawConfigValue(rawConfig, "private_key")) + "\n")
+			yaml.WriteString("    public-key: " + yamlString(rawConfigValue(rawConfig, "public_key")) + "\n")
+			if preSharedKey := rawConfigValue(rawConfig, "pre_shared_key"); preSharedKey != "" {
+				yaml.WriteString("    pre-shared-key: " + yamlString(preSharedKey) + "\n")
+			}
+			if mtu := rawConfigInt(rawConfig, "mtu"); mtu > 0 {
+				yaml.WriteString("    mtu: " + strconv.Itoa(mtu) + "\n")
+			}
+			if reserved := rawConfigValue(rawConfig, "reserved"); reserved != "" {
+				yaml.WriteString("    reserved: [" + strings.Join(splitList(reserved), ", ") + "]\n")
+			}
+			yaml.WriteString("    udp: true\n")
+		}
+
+		if proxy.DialerProxy != "" {
+			yaml.WriteString("    dialer-proxy: " + yamlString(proxy.DialerProxy) + "\n")
+		}
+		yaml.WriteString("\n")
+	}
+
+	return yaml.String()
+}
+
+// yamlDatePattern YAML会解析为时间的值
+var yamlDatePattern = regexp.MustCompile(`^\d{4}-\d{1,2}-\d{1,2}`)
+
+// yamlString 返回可写入YAML的字符串值，名称中包含": "、" #"或以"["等标记开头时会改变YAML结构，需加双引号
+// Go的转义写法与YAML双引号字符串兼容
+func yamlString(value string) string {
+	if yamlPlainSafe(value) {
+		return value
+	}
+	return strconv.Quote(value)
+}
+
+// yamlPlainSafe 判断字符串能否不加引号写入YAML且仍被解析为原字符串
+func yamlPlainSafe(value string) bool {
+	if value == "" || value != strings.TrimSpace(value) {
+		return false
+	}
+	if strings.ContainsAny(value[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return false
+	}
+	if strings.Contains(value, ": ") || strings.Contains(value, " #") || strings.HasSuffix(value, ":") {
+		return false
+	}
+	for _, r := range value {
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+	// 布尔值、空值、数字和日期会被解析为其他类型
+	switch strings.ToLower(value) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null", "~", ".inf", ".nan":
+		return false
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return false
+	}
+	if _, err := strconv.ParseInt(value, 0, 64); err == nil {
+		return false
+	}
+	return !yamlDatePattern.MatchString(value)
+}
+
+// clashProxyType 返回节点类型在Clash配置中的名称
+func clashProxyType(proxyType string) string {
+	if proxyType == "socks" {
+		return "socks5"
+	}
+	return proxyType
+}
+
+// generateJSONConfig 生成JSON配置
+func generateJSONConfig(proxies []models.Proxy) (string, error) {
+	// 实现JSON配置生成逻辑
+	type jsonProxy struct {
+		Name          string `json:"name"`
+		Type          string `json:"type"`
+		Server        string `json:"server"`
+		Port          int    `json:"port"`
+		UUID          string `json:"uuid,omitempty"`
+		Password      string `json:"password,omitempty"`
+		Method        string `json:"method,omitempty"`
+		Network       string `json:"network,omitempty"`
+		Path          string `json:"path,omitempty"`
+		Host          string `json:"host,omitempty"`
+		TLS           bool   `json:"tls,omitempty"`
+		SNI           string `json:"sni,omitempty"`
+		ALPN          string `json:"alpn,omitempty"`
+		Plugin        string `json:"plugin,omitempty"`
+		PluginOpts    string `json:"plugin_opts,omitempty"`
+		AllowInsecure bool   `json:"allow_insecure,omitempty"`
+		DialerProxy   string `json:"dialer_proxy,omitempty"`
+		// RawConfig 保存各类型特有的字段，如WireGuard密钥、Hysteria带宽
+		RawConfig map[string]interface{} `json:"raw_config,omitempty"`
+	}
+
+	var jsonProxies []jsonProxy
+	for _, proxy := range proxies {
+		jp := jsonProxy{
+			Name:          proxy.Name,
+			Type:          proxy.Type,
+			Server:        proxy.Server,
+			Port:          proxy.Port,
+			UUID:          proxy.UUID,
+			Password:      proxy.Password,
+			Method:        proxy.Method,
+			Network:       proxy.Network,
+			Path:          proxy.Path,
+			Host:          proxy.Host,
+			TLS:           proxy.TLS,
+			SNI:           proxy.SNI,
+			ALPN:          proxy.ALPN,
+			Plugin:        proxy.Plugin,
+			PluginOpts:    proxy.PluginOpts,
+			AllowInsecure: proxy.AllowInsecure,
+			DialerProxy:   proxy.DialerProxy,
+		}
+		if rawConfig := proxyRawConfig(proxy); len(rawConfig) > 0 {
+			jp.RawConfig = rawConfig
+		}
+		jsonProxies = append(jsonProxies, jp)
+	}
+
+	jsonData, err := json.Marshal(jsonProxies)
+	if err != nil {
+		return "", err
+	}
+
+	return string(jsonData), nil
+}
@@ -0,0 +1,373 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"proxy-subscription/models"
+	"proxy-subscription/services"
+)
+
+// singboxGenerator 生成sing-box完整配置，包含节点出站、手动选择、自动测速分组及可选的地区分组
+type singboxGenerator struct{}
+
+func (singboxGenerator) Name() string        { return "singbox" }
+func (singboxGenerator) ContentType() string { return "application/json;charset=utf-8" }
+func (singboxGenerator) Description() string { return "sing-box 配置（SFA/SFI/SFM）" }
+
+func (singboxGenerator) SupportedTypes() []string {
+	return []string{"ss", "vmess", "vless", "trojan", "tuic", "anytls", "hysteria2", "hysteria", "wireguard", "http", "socks"}
+}
+
+// SupportsChains 链式节点通过出站的detour字段指定前置节点
+func (singboxGenerator) SupportsChains() bool { return true }
+
+// singboxProxyTags 为与固定出站标签同名的节点追加序号，
+// 返回改名后的节点以及原名称到新名称的映射，供detour引用
+func singboxProxyTags(proxies []models.Proxy) ([]models.Proxy, map[string]string) {
+	tagged := make([]models.Proxy, len(proxies))
+	copy(tagged, proxies)
+	tagged = services.EnsureUniqueNames(tagged, singboxReservedTags...)
+
+	renamed := make(map[string]string, len(proxies))
+	for i, proxy := range proxies {
+		renamed[proxy.Name] = tagged[i].Name
+	}
+	return tagged, renamed
+}
+
+// singboxReservedTags 配置中固定使用的出站标签，节点不能与其重名
+var singboxReservedTags = []string{"proxy", "auto", "direct"}
+
+func (singboxGenerator) Generate(proxies []models.Proxy, options GenerateOptions) (string, error) {
+	proxies, renamed := singboxProxyTags(proxies)
+	tags := make([]string, 0, len(proxies))
+	nodes := make([]map[string]interface{}, 0, len(proxies))
+	for _, proxy := range proxies {
+		tags = append(tags, proxy.Name)
+		outbound := singboxOutbound(proxy)
+		if proxy.DialerProxy != "" {
+			outbound["detour"] = renamed[proxy.DialerProxy]
+		}
+		nodes = append(nodes, outbound)
+	}
+
+	// 地区分组排在自动选择之后、节点之前，便于在手动选择中切换地区，
+	// 分组名称会避开节点标签和固定标签
+	groups := services.GroupProxiesByRegion(proxies, options.RegionGroups, singboxReservedTags...)
+	selectorOutbounds := []string{"auto"}
+	for _, group := range groups {
+		selectorOutbounds = append(selectorOutbounds, group.Name)
+	}
+	selectorOutbounds = append(selectorOutbounds, tags...)
+	outbounds := []map[string]interface{}{
+		{"type": "selector", "tag": "proxy", "outbounds": selectorOutbounds, "default": "auto"},
+		{"type": "urltest", "tag": "auto", "outbounds": tags, "url": "https://www.gstatic.com/generate_204", "interval": "5m"},
+	}
+	for _, group := range groups {
+		outbounds = append(outbounds, singboxGroupOutbound(group))
+	}
+	if len(tags) == 0 {
+		// urltest不允许空的出站列表
+		outbounds = []map[string]interface{}{
+			{"type": "selector", "tag": "proxy", "outbounds": []string{"direct"}},
+		}
+	}
+	outbounds = append(outbounds, nodes...)
+	outbounds = append(outbounds, map[string]interface{}{"type": "direct", "tag": "direct"})
+
+	config := map[string]interface{}{
+		"log": map[string]interface{}{"level": "info"},
+		"inbounds": []map[string]interface{}{
+			{"type": "mixed", "tag": "mixed-in", "listen": "127.0.0.1", "listen_port": 2080},
+		},
+		"outbounds": outbounds,
+		"route":     singboxRoute(options),
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// singboxRoute 生成路由配置，引用规则集时按优先级为每个规则集生成一条路由规则，未匹配的流量使用proxy
+func singboxRoute(options GenerateOptions) map[string]interface{} {
+	route := map[string]interface{}{
+		"final":                 "proxy",
+		"auto_detect_interface": true,
+	}
+	if !options.hasRuleSets() {
+		return route
+	}
+
+	ruleSets := make([]map[string]interface{}, 0)
+	rules := make([]map[string]interface{}, 0)
+	geoIPTags := make(map[string]struct{})
+	for _, ruleSet := range options.RuleSets {
+		tags := make([]string, 0)
+		if services.HasSingboxRules(ruleSet.Rules) {
+			tags = append(tags, ruleSet.Name)
+			ruleSets = append(ruleSets, map[string]interface{}{
+				"type":            "remote",
+				"tag":             ruleSet.Name,
+				"format":          "source",
+				"url":             options.ruleSetURL(ruleSet.Name, "singbox"),
+				"download_detour": "direct",
+			})
+		}
+		for _, code := range services.RuleSetGeoIPCodes(ruleSet.Rules) {
+			tag := "geoip-" + strings.ToLower(code)
+			tags = append(tags, tag)
+			if _, exists := geoIPTags[tag]; exists {
+				continue
+			}
+			geoIPTags[tag] = struct{}{}
+			ruleSets = append(ruleSets, map[string]interface{}{
+				"type":   "remote",
+				"tag":    tag,
+				"format": "binary",
+				"url":    fmt.Sprintf(services.SingboxGeoIPRuleSetURL, strings.ToLower(code)),
+			})
+		}
+		if len(tags) == 0 {
+			continue
+		}
+
+		rule := map[string]interface{}{"rule_set": tags}
+		switch ruleSet.Policy {
+		case models.RulePolicyReject:
+			rule["action"] = "reject"
+		case models.RulePolicyDirect:
+			rule["outbound"] = "direct"
+		default:
+			rule["outbound"] = "proxy"
+		}
+		rules = append(rules, rule)
+	}
+
+	route["rule_set"] = ruleSets
+	route["rules"] = rules
+	return route
+}
+
+// singboxGroupOutbound 将地区分组转换为urltest或selector出站
+func singboxGroupOutbound(group services.RegionGroup) map[string]interface{} {
+	if group.Type == services.RegionGroupSelect {
+		return map[string]interface{}{"type": "selector", "tag": group.Name, "outbounds": group.Proxies}
+	}
+	return map[string]interface{}{"type": "urltest", "tag": group.Name, "outbounds": group.Proxies, "url": "https://www.gstatic.com/generate_204", "interval": "5m"}
+}
+
+// singboxOutbound 将节点转换为sing-box出站配置
+func singboxOutbound(proxy models.Proxy) map[string]interface{} {
+	rawConfig := proxyRawConfig(proxy)
+	outbound := map[string]interface{}{
+		"tag":         proxy.Name,
+		"server":      proxy.Server,
+		"server_port": proxy.Port,
+	}
+
+	switch proxy.Type {
+	case "ss":
+		outbound["type"] = "shadowsocks"
+		outbound["method"] = proxy.Method
+		outbound["password"] = proxy.Password
+		if proxy.Plugin != "" {
+			outbound["plugin"] = proxy.Plugin
+			outbound["plugin_opts"] = proxy.PluginOpts
+		}
+	case "vmess":
+		outbound["type"] = "vmess"
+		outbound["uuid"] = proxy.UUID
+		outbound["security"] = "auto"
+		outbound["alter_id"] = 0
+		setSingboxTLS(outbound, proxy, rawConfig, proxy.TLS)
+		setSingboxTransport(outbound, proxy, rawConfig)
+	case "vless":
+		outbound["type"] = "vless"
+		outbound["uuid"] = proxy.UUID
+		if flow := rawConfigValue(rawConfig, "flow"); flow != "" {
+			outbound["flow"] = flow
+		}
+		setSingboxTLS(outbound, proxy, rawConfig, proxy.TLS)
+		setSingboxTransport(outbound, proxy, rawConfig)
+	case "trojan":
+		outbound["type"] = "trojan"
+		outbound["password"] = proxy.Password
+		setSingboxTLS(outbound, proxy, rawConfig, true)
+		setSingboxTransport(outbound, proxy, rawConfig)
+	case "tuic":
+		outbound["type"] = "tuic"
+		outbound["uuid"] = proxy.UUID
+		outbound["password"] = proxy.Password
+		if value := rawConfigValue(rawConfig, "congestion_control"); value != "" {
+			outbound["congestion_control"] = value
+		}
+		if value := rawConfigValue(rawConfig, "udp_relay_mode"); value != "" {
+			outbound["udp_relay_mode"] = value
+		}
+		setSingboxTLS(outbound, proxy, rawConfig, true)
+	case "anytls":
+		outbound["type"] = "anytls"
+		outbound["password"] = proxy.Password
+		setSingboxTLS(outbound, proxy, rawConfig, true)
+	case "hysteria2":
+		outbound["type"] = "hysteria2"
+		outbound["password"] = proxy.Password
+		if obfs := rawConfigValue(rawConfig, "obfs"); obfs != "" {
+			outbound["obfs"] = map[string]interface{}{
+				"type":     obfs,
+				"password": rawConfigValue(rawConfig, "obfs-password", "obfs_password"),
+			}
+		}
+		setSingboxTLS(outbound, proxy, rawConfig, true)
+	case "hysteria":
+		outbound["type"] = "hysteria"
+		outbound["up_mbps"] = rawConfigInt(rawConfig, "up")
+		outbound["down_mbps"] = rawConfigInt(rawConfig, "down")
+		if proxy.Password != "" {
+			outbound["auth_str"] = proxy.Password
+		}
+		if obfs := rawConfigValue(rawConfig, "obfs"); obfs != "" {
+			outbound["obfs"] = obfs
+		}
+		setSingboxTLS(outbound, proxy, rawConfig, true)
+	case "wireguard":
+		outbound["type"] = "wireguard"
+		addresses := make([]string, 0, 2)
+		for _, key := range []string{"ip", "ipv6"} {
+			if addr := rawConfigValue(rawConfig, key); addr != "" {
+				addresses = append(addresses, singboxPrefix(addr))
+			}
+		}
+		outbound["local_address"] = addresses
+		outbound["private_key"] = rawConfigValue(rawConfig, "private_key")
+		outbound["peer_public_key"] = rawConfigValue(rawConfig, "public_key")
+		if preSharedKey := rawConfigValue(rawConfig, "pre_shared_key"); preSharedKey != "" {
+			outbound["pre_shared_key"] = preSharedKey
+		}
+		if mtu := rawConfigInt(rawConfig, "mtu"); mtu > 0 {
+			outbound["mtu"] = mtu
+		}
+		if reserved := rawConfigValue(rawConfig, "reserved"); reserved != "" {
+			values := make([]int, 0, 3)
+			for _, item := range splitList(reserved) {
+				if value, err := strconv.Atoi(item); err == nil {
+					values = append(values, value)
+				}
+			}
+			outbound["reserved"] = values
+		}
+	case "http":
+		outbound["type"] = "http"
+		setSingboxCredentials(outbound, proxy, rawConfig)
+		if proxy.TLS {
+			setSingboxTLS(outbound, proxy, rawConfig, true)
+		}
+	case "socks":
+		outbound["type"] = "socks"
+		outbound["version"] = "5"
+		setSingboxCredentials(outbound, proxy, rawConfig)
+	}
+	return outbound
+}
+
+// setSingboxTLS 写入TLS配置，包括Reality和uTLS指纹
+func setSingboxTLS(outbound map[string]interface{}, proxy models.Proxy, rawConfig map[string]interface{}, enabled bool) {
+	security := rawConfigValue(rawConfig, "security")
+	if !enabled && security != "tls" && security != "reality" {
+		return
+	}
+
+	tls := map[string]interface{}{"enabled": true}
+	if serverName := firstNonEmpty(proxy.SNI, rawConfigValue(rawConfig, "sni", "servername")); serverName != "" {
+		tls["server_name"] = serverName
+	}
+	if proxy.ALPN != "" {
+		tls["alpn"] = splitList(proxy.ALPN)
+	}
+	if proxy.AllowInsecure {
+		tls["insecure"] = true
+	}
+	if fingerprint := rawConfigValue(rawConfig, "fp", "fingerprint"); fingerprint != "" {
+		tls["utls"] = map[string]interface{}{"enabled": true, "fingerprint": fingerprint}
+	}
+	if security == "reality" {
+		reality := map[string]interface{}{
+			"enabled":    true,
+			"public_key": rawConfigValue(rawConfig, "pbk", "public-key"),
+		}
+		if shortID := rawConfigValue(rawConfig, "sid", "short-id"); shortID != "" {
+			reality["short_id"] = shortID
+		}
+		tls["reality"] = reality
+		if _, exists := tls["utls"]; !exists {
+			// Reality 必须启用uTLS
+			tls["utls"] = map[string]interface{}{"enabled": true, "fingerprint": "chrome"}
+		}
+	}
+	outbound["tls"] = tls
+}
+
+// setSingboxTransport 写入ws/grpc/http传输层配置，tcp不需要额外配置
+func setSingboxTransport(outbound map[string]interface{}, proxy models.Proxy, rawConfig map[string]interface{}) {
+	switch strings.ToLower(proxy.Network) {
+	case "ws":
+		transport := map[string]interface{}{"type": "ws"}
+		if proxy.Path != "" {
+			transport["path"] = proxy.Path
+		}
+		if proxy.Host != "" {
+			transport["headers"] = map[string]interface{}{"Host": proxy.Host}
+		}
+		outbound["transport"] = transport
+	case "grpc":
+		outbound["transport"] = map[string]interface{}{
+			"type":         "grpc",
+			"service_name": firstNonEmpty(rawConfigValue(rawConfig, "serviceName", "service_name"), proxy.Path),
+		}
+	case "h2", "http":
+		transport := map[string]interface{}{"type": "http"}
+		if proxy.Host != "" {
+			transport["host"] = splitList(proxy.Host)
+		}
+		if proxy.Path != "" {
+			transport["path"] = proxy.Path
+		}
+		outbound["transport"] = transport
+	case "httpupgrade":
+		transport := map[string]interface{}{"type": "httpupgrade"}
+		if proxy.Host != "" {
+			transport["host"] = proxy.Host
+		}
+		if proxy.Path != "" {
+			transport["path"] = proxy.Path
+		}
+		outbound["transport"] = transport
+	}
+}
+
+// singboxPrefix 为不带前缀长度的地址补全 /32 或 /128
+func singboxPrefix(addr string) string {
+	if strings.Contains(addr, "/") {
+		return addr
+	}
+	if strings.Contains(addr, ":") {
+		return addr + "/128"
+	}
+	return addr + "/32"
+}
+
+// setSingboxCredentials 写入http/socks节点的用户名和密码
+func setSingboxCredentials(outbound map[string]interface{}, proxy models.Proxy, rawConfig map[string]interface{}) {
+	if username := rawConfigValue(rawConfig, "username"); username != "" {
+		outbound["username"] = username
+	}
+	if proxy.Password != "" {
+		outbound["password"] = proxy.Password
+	}
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+
+	"proxy-subscription/models"
+	"proxy-subscription/services"
+)
+
+// surgeGenerator 生成Surge配置，包含[Proxy]、[Proxy Group]以及引用规则集的[Rule]
+type surgeGenerator struct{}
+
+func (surgeGenerator) Name() string        { return "surge" }
+func (surgeGenerator) ContentType() string { return "text/plain;charset=utf-8" }
+func (surgeGenerator) Description() string { return "Surge 配置" }
+
+func (surgeGenerator) SupportedTypes() []string {
+	return []string{"ss", "vmess", "trojan", "tuic", "hysteria2", "http", "socks"}
+}
+
+// SupportsChains 链式节点通过underlying-proxy参数指定前置节点
+func (surgeGenerator) SupportsChains() bool { return true }
+
+func (surgeGenerator) Generate(proxies []models.Proxy, options GenerateOptions) (string, error) {
+	var config strings.Builder
+	proxies, renamed := surgeProxyNames(proxies)
+	names := make([]string, 0, len(proxies))
+	mainGroup := uniqueProxyName("Proxy", proxies)
+	autoGroup := uniqueProxyName("Auto", proxies)
+
+	config.WriteString("[General]\nloglevel = notify\nskip-proxy = 127.0.0.1, 192.168.0.0/16, 10.0.0.0/8, 172.16.0.0/12, localhost, *.local\n\n")
+	config.WriteString("[Proxy]\n")
+	for _, proxy := range proxies {
+		names = append(names, proxy.Name)
+		params := surgeProxyParams(proxy)
+		if proxy.DialerProxy != "" {
+			dialer, ok := renamed[proxy.DialerProxy]
+			if !ok {
+				dialer = surgeName(proxy.DialerProxy)
+			}
+			params = append(params, "underlying-proxy="+dialer)
+		}
+		config.WriteString(proxy.Name + " = " + strings.Join(params, ", ") + "\n")
+	}
+
+	config.WriteString("\n[Proxy Group]\n")
+	if len(names) == 0 {
+		config.WriteString(mainGroup + " = select, DIRECT\n")
+	} else {
+		config.WriteString(mainGroup + " = select, " + autoGroup + ", " + strings.Join(names, ", ") + "\n")
+		config.WriteString(autoGroup + " = url-test, " + strings.Join(names, ", ") + ", url=http://www.gstatic.com/generate_204, interval=300\n")
+	}
+
+	// 路由规则来自启用的规则集，未匹配的流量使用主分组
+	config.WriteString("\n[Rule]\n")
+	if options.hasRuleSets() {
+		for _, ruleSet := range options.RuleSets {
+			config.WriteString("RULE-SET," + options.ruleSetURL(ruleSet.Name, "surge") + "," + clashRulePolicy(ruleSet.Policy, mainGroup) + "\n")
+		}
+	}
+	config.WriteString("FINAL," + mainGroup + "\n")
+	return config.String(), nil
+}
+
+// surgeProxyNames 先去除名称中破坏Surge语法的字符再重新去重，
+// 返回改名后的节点以及原名称到新名称的映射，供链式代理引用
+func surgeProxyNames(proxies []models.Proxy) ([]models.Proxy, map[string]string) {
+	cleaned := make([]models.Proxy, len(proxies))
+	for i, proxy := range proxies {
+		cleaned[i] = proxy
+		cleaned[i].Name = surgeName(proxy.Name)
+	}
+	cleaned = services.EnsureUniqueNames(cleaned)
+
+	renamed := make(map[string]string, len(proxies))
+	for i, proxy := range proxies {
+		renamed[proxy.Name] = cleaned[i].Name
+	}
+	return cleaned, renamed
+}
+
+// surgeProxyParams 生成[Proxy]中等号右侧的参数列表
+func surgeProxyParams(proxy models.Proxy) []string {
+	rawConfig := proxyRawConfig(proxy)
+	port := strconv.Itoa(proxy.Port)
+
+	switch proxy.Type {
+	case "ss":
+		params := []string{"ss", proxy.Server, port, "encrypt-method=" + proxy.Method, "password=" + proxy.Password}
+		if strings.Contains(proxy.Plugin, "obfs") {
+			opts := parsePluginOpts(proxy.PluginOpts)
+			if mode := opts["obfs"]; mode != "" {
+				params = append(params, "obfs="+mode)
+			}
+			if host := opts["obfs-host"]; host != "" {
+				params = append(params, "obfs-host="+host)
+			}
+		}
+		return append(params, "udp-relay=true")
+	case "vmess":
+		params := []string{"vmess", proxy.Server, port, "username=" + proxy.UUID, "vmess-aead=true"}
+		params = append(params, surgeTransportParams(proxy)...)
+		if proxy.TLS {
+			params = append(params, "tls=true")
+			params = append(params, surgeTLSParams(proxy, rawConfig)...)
+		}
+		return params
+	case "trojan":
+		params := []string{"trojan", proxy.Server, port, "password=" + proxy.Password}
+		params = append(params, surgeTransportParams(proxy)...)
+		return append(params, surgeTLSParams(proxy, rawConfig)...)
+	case "tuic":
+		params := []string{"tuic-v5", proxy.Server, port, "password=" + proxy.Password, "uuid=" + proxy.UUID}
+		if proxy.ALPN != "" {
+			params = append(params, "alpn="+splitList(proxy.ALPN)[0])
+		}
+		return append(params, surgeTLSParams(proxy, rawConfig)...)
+	case "hysteria2":
+		params := []string{"hysteria2", proxy.Server, port, "password=" + proxy.Password}
+		return append(params, surgeTLSParams(proxy, rawConfig)...)
+	case "http":
+		proxyType := "http"
+		if proxy.TLS {
+			proxyType = "https"
+		}
+		return append([]string{proxyType, proxy.Server, port}, surgeCredentials(proxy, rawConfig)...)
+	case "socks":
+		proxyType := "socks5"
+		if proxy.TLS {
+			proxyType = "socks5-tls"
+		}
+		return append([]string{proxyType, proxy.Server, port}, surgeCredentials(proxy, rawConfig)...)
+	}
+	return []string{proxy.Type, proxy.Server, port}
+}
+
+// surgeTransportParams 生成WebSocket传输参数，Surge仅支持ws
+func surgeTransportParams(proxy models.Proxy) []string {
+	if !strings.EqualFold(proxy.Network, "ws") {
+		return nil
+	}
+	params := []string{"ws=true"}
+	if proxy.Path != "" {
+		params = append(params, "ws-path="+proxy.Path)
+	}
+	if proxy.Host != "" {
+		params = append(params, "ws-headers=Host:"+proxy.Host)
+	}
+	return params
+}
+
+// surgeTLSParams 生成SNI和证书校验参数
+func surgeTLSParams(proxy models.Proxy, rawConfig map[string]interface{}) []string {
+	var params []string
+	if sni := firstNonEmpty(proxy.SNI, rawConfigValue(rawConfig, "sni", "servername")); sni != "" {
+		params = append(params, "sni="+sni)
+	}
+	if proxy.AllowInsecure {
+		params = append(params, "skip-cert-verify=true")
+	}
+	return params
+}
+
+// surgeCredentials 生成http/socks5节点的用户名和密码参数。
+// Surge按位置读取用户名和密码，缺少用户名时无法表达，两者都不输出
+func surgeCredentials(proxy models.Proxy, rawConfig map[string]interface{}) []string {
+	username := rawConfigValue(rawConfig, "username")
+	if username == "" {
+		return nil
+	}
+	return []string{username, proxy.Password}
+}
+
+// surgeName 去除节点名中会破坏Surge配置语法的字符
+func surgeName(name string) string {
+	return strings.NewReplacer(",", " ", "=", "-", "\n", " ", "\r", "").Replace(name)
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"proxy-subscription/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetGeoIPStatus 获取数据目录下正在使用的GeoIP数据库
+func GetGeoIPStatus(c *gin.Context) {
+	status, err := services.GetGeoIPStatus()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// RefreshGeoLocations 按当前数据库重新查询所有节点的实际位置，通常在更新数据库文件后调用
+func RefreshGeoLocations(c *gin.Context) {
+	located, total, err := services.RefreshGeoLocations(c.Request.Context())
+	if errors.Is(err, services.ErrGeoIPUnavailable) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"located": located, "total": total})
+}
@@ -0,0 +1,239 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"proxy-subscription/models"
+	"proxy-subscription/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetProxyHealth 获取节点的健康检查历史
+func GetProxyHealth(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	var proxy models.Proxy
+	if err := models.DB.First(&proxy, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "代理节点不存在"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	history, err := services.HealthHistory(proxy, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"endpoint":  proxy.HealthEndpoint(),
+		"supported": services.IsProbeSupported(proxy),
+		"history":   history,
+	})
+}
+
+// CheckProxyHealth 立即探测单个节点
+func CheckProxyHealth(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	var proxy models.Proxy
+	if err := models.DB.First(&proxy, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "代理节点不存在"})
+		return
+	}
+
+	if !services.IsProbeSupported(proxy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持通过TCP探测的节点类型: " + proxy.Type})
+		return
+	}
+
+	result, err := services.CheckProxyHealth(proxy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ProtocolTestRequest 协议测试请求，未指定地址时使用设置中的测试地址
+type ProtocolTestRequest struct {
+	URL string `json:"url"`
+}
+
+// TestProxyProtocol 通过节点协议访问测试地址，结果同时计入健康检查历史
+func TestProxyProtocol(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	var proxy models.Proxy
+	if err := models.DB.First(&proxy, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "代理节点不存在"})
+		return
+	}
+
+	var request ProtocolTestRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if request.URL == "" {
+		request.URL = models.GetSetting(models.SettingProtocolTestURL, services.DefaultProtocolTestURL)
+	}
+	if err := validateProtocolTestURL(request.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.CheckProtocolSupport(proxy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := services.TestProxyProtocol(proxy, request.URL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// validateProtocolTestURL 校验协议测试地址
+func validateProtocolTestURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("协议测试地址必须是有效的HTTP(S)地址")
+	}
+	return nil
+}
+
+// RunHealthChecks 后台探测所有节点
+func RunHealthChecks(c *gin.Context) {
+	if err := services.StartHealthChecks(); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "健康检查已开始"})
+}
+
+// deadNodeOptions 输出订阅时对失效节点的处理方式
+type deadNodeOptions struct {
+	Policy    string `json:"policy"`
+	Threshold int    `json:"threshold"`
+}
+
+// resolveDeadNodeOptions 读取失效节点处理设置，输出配置中的设置优先于全局设置
+func resolveDeadNodeOptions(profile *models.Profile) deadNodeOptions {
+	options := deadNodeOptions{
+		Policy:    models.GetSetting(models.SettingDeadNodePolicy, models.DeadNodeKeep),
+		Threshold: services.DefaultDeadNodeThreshold,
+	}
+	if threshold, err := strconv.Atoi(models.GetSetting(models.SettingDeadNodeThreshold, "")); err == nil && threshold > 0 {
+		options.Threshold = threshold
+	}
+	if profile != nil {
+		if profile.DeadNodePolicy != "" {
+			options.Policy = profile.DeadNodePolicy
+		}
+		if profile.DeadNodeThreshold > 0 {
+			options.Threshold = profile.DeadNodeThreshold
+		}
+	}
+	if !models.IsValidDeadNodePolicy(options.Policy) {
+		options.Policy = models.DeadNodeKeep
+	}
+	return options
+}
+
+// applyDeadNodePolicy 按处理方式移除失效节点或将其移到末尾，返回输出节点和失效节点
+func applyDeadNodePolicy(proxies []models.Proxy, options deadNodeOptions) ([]models.Proxy, []models.Proxy, error) {
+	if options.Policy == models.DeadNodeKeep {
+		return proxies, nil, nil
+	}
+
+	streaks, err := services.FailureStreaks()
+	if err != nil {
+		return nil, nil, err
+	}
+	alive, dead := services.SplitDeadProxies(proxies, streaks, options.Threshold, services.CurrentHealthKey())
+	if options.Policy == models.DeadNodeDemote {
+		return append(alive, dead...), dead, nil
+	}
+	return alive, dead, nil
+}
+
+// addDeadNodeHeaders 通过响应头告知被移除或后移的失效节点数量
+func addDeadNodeHeaders(headers map[string]string, policy string, dead []models.Proxy) {
+	if len(dead) == 0 {
+		return
+	}
+	switch policy {
+	case models.DeadNodeExclude:
+		headers["X-Excluded-Proxies"] = strconv.Itoa(len(dead))
+	case models.DeadNodeDemote:
+		headers["X-Demoted-Proxies"] = strconv.Itoa(len(dead))
+	}
+}
+
+// serveDeadNodeReport 返回订阅中的失效节点及其连续失败次数，不使用缓存
+func serveDeadNodeReport(c *gin.Context, options deadNodeOptions, build func() ([]models.Proxy, error)) {
+	proxies, err := build()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	proxies = services.EnsureUniqueNames(proxies)
+
+	streaks, err := services.FailureStreaks()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	healthKey := services.CurrentHealthKey()
+	_, dead := services.SplitDeadProxies(proxies, streaks, options.Threshold, healthKey)
+
+	type deadNode struct {
+		Name     string `json:"name"`
+		Type     string `json:"type"`
+		Endpoint string `json:"endpoint"`
+		Failures int    `json:"failures"`
+	}
+	nodes := make([]deadNode, 0, len(dead))
+	for _, proxy := range dead {
+		nodes = append(nodes, deadNode{
+			Name:     proxy.Name,
+			Type:     proxy.Type,
+			Endpoint: proxy.HealthEndpoint(),
+			Failures: streaks[healthKey(proxy)],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"policy":    options.Policy,
+		"threshold": options.Threshold,
+		"total":     len(proxies),
+		"dead":      nodes,
+	})
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"proxy-subscription/models"
+	"proxy-subscription/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setTestSetting(t *testing.T, key, value string) {
+	t.Helper()
+	if err := models.DB.Create(&models.Setting{Key: key, Value: value}).Error; err != nil {
+		t.Fatalf("create setting error = %v", err)
+	}
+}
+
+// recordHealthChecks 按顺序写入探测结果，true为成功
+func recordHealthChecks(t *testing.T, endpoint string, results ...bool) {
+	t.Helper()
+	for _, success := range results {
+		if err := models.DB.Create(&models.HealthCheck{Endpoint: endpoint, Mode: "tcp", Success: success}).Error; err != nil {
+			t.Fatalf("create health check error = %v", err)
+		}
+	}
+}
+
+func TestResolveDeadNodeOptions(t *testing.T) {
+	setupTestDB(t)
+
+	options := resolveDeadNodeOptions(nil)
+	assertEqual(t, options.Policy, models.DeadNodeKeep, "default policy")
+	assertEqual(t, options.Threshold, services.DefaultDeadNodeThreshold, "default threshold")
+
+	setTestSetting(t, models.SettingDeadNodePolicy, models.DeadNodeExclude)
+	setTestSetting(t, models.SettingDeadNodeThreshold, "5")
+	options = resolveDeadNodeOptions(nil)
+	assertEqual(t, options.Policy, models.DeadNodeExclude, "global policy")
+	assertEqual(t, options.Threshold, 5, "global threshold")
+
+	options = resolveDeadNodeOptions(&models.Profile{})
+	assertEqual(t, options.Policy, models.DeadNodeExclude, "profile falls back to global policy")
+	assertEqual(t, options.Threshold, 5, "profile falls back to global threshold")
+
+	options = resolveDeadNodeOptions(&models.Profile{DeadNodePolicy: models.DeadNodeDemote, DeadNodeThreshold: 2})
+	assertEqual(t, options.Policy, models.DeadNodeDemote, "profile policy")
+	assertEqual(t, options.Threshold, 2, "profile threshold")
+
+	options = resolveDeadNodeOptions(&models.Profile{DeadNodePolicy: models.DeadNodeKeep})
+	assertEqual(t, options.Policy, models.DeadNodeKeep, "profile keeps dead nodes")
+	assertEqual(t, options.Threshold, 5, "profile without threshold")
+}
+
+func TestResolveDeadNodeOptionsInvalidSettings(t *testing.T) {
+	setupTestDB(t)
+	setTestSetting(t, models.SettingDeadNodePolicy, "drop")
+	setTestSetting(t, models.SettingDeadNodeThreshold, "-1")
+
+	options := resolveDeadNodeOptions(nil)
+	assertEqual(t, options.Policy, models.DeadNodeKeep, "invalid policy")
+	assertEqual(t, options.Threshold, services.DefaultDeadNodeThreshold, "invalid threshold")
+}
+
+func TestApplyDeadNodePolicyThreshold(t *testing.T) {
+	setupTestDB(t)
+
+	proxies := []models.Proxy{
+		{Name: "dead", Server: "dead.example.com", Port: 443},
+		{Name: "flaky", Server: "flaky.example.com", Port: 443},
+		{Name: "recovered", Server: "recovered.example.com", Port: 443},
+		{Name: "unchecked", Server: "unchecked.example.com", Port: 443},
+	}
+	recordHealthChecks(t, proxies[0].HealthEndpoint(), true, false, false, false)
+	// 连续失败次数未达到阈值的节点仍视为可用
+	recordHealthChecks(t, proxies[1].HealthEndpoint(), false, false)
+	// 成功后重新计数
+	recordHealthChecks(t, proxies[2].HealthEndpoint(), false, false, false, true, false)
+
+	output, dead, err := applyDeadNodePolicy(proxies, deadNodeOptions{Policy: models.DeadNodeKeep, Threshold: 3})
+	if err != nil {
+		t.Fatalf("applyDeadNodePolicy(keep) error = %v", err)
+	}
+	assertEqual(t, len(output), 4, "keep output")
+	assertEqual(t, len(dead), 0, "keep dead")
+
+	output, dead, err = applyDeadNodePolicy(proxies, deadNodeOptions{Policy: models.DeadNodeExclude, Threshold: 3})
+	if err != nil {
+		t.Fatalf("applyDeadNodePolicy(exclude) error = %v", err)
+	}
+	assertEqual(t, len(output), 3, "exclude output")
+	assertEqual(t, len(dead), 1, "exclude dead")
+	assertEqual(t, dead[0].Name, "dead", "dead node")
+
+	output, dead, err = applyDeadNodePolicy(proxies, deadNodeOptions{Policy: models.DeadNodeDemote, Threshold: 2})
+	if err != nil {
+		t.Fatalf("applyDeadNodePolicy(demote) error = %v", err)
+	}
+	assertEqual(t, len(output), 4, "demote output")
+	assertEqual(t, len(dead), 2, "demote dead")
+	assertEqual(t, output[0].Name, "recovered", "alive first")
+	assertEqual(t, output[1].Name, "unchecked", "alive keeps order")
+	assertEqual(t, output[2].Name, "dead", "demoted in order")
+	assertEqual(t, output[3].Name, "flaky", "demoted at threshold")
+}
+
+// serveTestSubscription 通过serveSubscription输出节点并返回响应
+func serveTestSubscription(t *testing.T, query string, options outputOptions, proxies []models.Proxy) *httptest.ResponseRecorder {
+	t.Helper()
+	services.InvalidateCache()
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/merged/token"+query, nil)
+	serveSubscription(c, "test", "json", options, func() ([]models.Proxy, error) {
+		result := make([]models.Proxy, len(proxies))
+		copy(result, proxies)
+		return result, nil
+	})
+	return w
+}
+
+func TestServeSubscriptionDeadNodeHeaders(t *testing.T) {
+	setupTestDB(t)
+
+	proxies := []models.Proxy{
+		{Type: "trojan", Name: "dead", Server: "dead.example.com", Port: 443, Password: "secret"},
+		{Type: "trojan", Name: "alive", Server: "alive.example.com", Port: 443, Password: "secret"},
+	}
+	recordHealthChecks(t, proxies[0].HealthEndpoint(), false, false, false)
+	recordHealthChecks(t, proxies[1].HealthEndpoint(), true)
+
+	outputNames := func(w *httptest.ResponseRecorder) []string {
+		var nodes []map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &nodes); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v\nbody: %s", err, w.Body.String())
+		}
+		names := make([]string, 0, len(nodes))
+		for _, node := range nodes {
+			names = append(names, node["name"].(string))
+		}
+		return names
+	}
+
+	w := serveTestSubscription(t, "", outputOptions{DeadNodes: deadNodeOptions{Policy: models.DeadNodeExclude, Threshold: 3}}, proxies)
+	assertEqual(t, w.Code, http.StatusOK, "exclude status")
+	assertEqual(t, w.Header().Get("X-Excluded-Proxies"), "1", "excluded header")
+	assertEqual(t, w.Header().Get("X-Demoted-Proxies"), "", "no demoted header")
+	names := outputNames(w)
+	assertEqual(t, len(names), 1, "excluded output")
+	assertEqual(t, names[0], "alive", "alive output")
+
+	w = serveTestSubscription(t, "", outputOptions{DeadNodes: deadNodeOptions{Policy: models.DeadNodeDemote, Threshold: 3}}, proxies)
+	assertEqual(t, w.Header().Get("X-Demoted-Proxies"), "1", "demoted header")
+	assertEqual(t, w.Header().Get("X-Excluded-Proxies"), "", "no excluded header")
+	names = outputNames(w)
+	assertEqual(t, len(names), 2, "demoted output")
+	assertEqual(t, names[1], "dead", "dead node last")
+
+	// 未达到阈值时不输出响应头
+	w = serveTestSubscription(t, "", outputOptions{DeadNodes: deadNodeOptions{Policy: models.DeadNodeExclude, Threshold: 4}}, proxies)
+	assertEqual(t, w.Header().Get("X-Excluded-Proxies"), "", "below threshold")
+	assertEqual(t, len(outputNames(w)), 2, "below threshold output")
+}
+
+func TestServeSubscriptionDeadNodeReport(t *testing.T) {
+	setupTestDB(t)
+
+	proxies := []models.Proxy{
+		{Type: "trojan", Name: "dead", Server: "dead.example.com", Port: 443, Password: "secret"},
+		{Type: "trojan", Name: "alive", Server: "alive.example.com", Port: 443, Password: "secret"},
+	}
+	recordHealthChecks(t, proxies[0].HealthEndpoint(), false, false, false, false)
+
+	w := serveTestSubscription(t, "?debug=health", outputOptions{DeadNodes: deadNodeOptions{Policy: models.DeadNodeKeep, Threshold: 3}}, proxies)
+	assertEqual(t, w.Code, http.StatusOK, "report status")
+	assertEqual(t, w.Header().Get("X-Cache"), "", "report bypasses cache")
+
+	var report struct {
+		Policy    string `json:"policy"`
+		Threshold int    `json:"threshold"`
+		Total     int    `json:"total"`
+		Dead      []struct {
+			Name     string `json:"name"`
+			Endpoint string `json:"endpoint"`
+			Failures int    `json:"failures"`
+		} `json:"dead"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v\nbody: %s", err, w.Body.String())
+	}
+	assertEqual(t, report.Policy, models.DeadNodeKeep, "report policy")
+	assertEqual(t, report.Threshold, 3, "report threshold")
+	assertEqual(t, report.Total, 2, "report total")
+	assertEqual(t, len(report.Dead), 1, "report dead count")
+	assertEqual(t, report.Dead[0].Name, "dead", "report dead name")
+	assertEqual(t, report.Dead[0].Endpoint, "dead.example.com:443", "report endpoint")
+	assertEqual(t, report.Dead[0].Failures, 4, "report failures")
+}
@@ -0,0 +1,266 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"proxy-subscription/models"
+	"proxy-subscription/services"
+	"proxy-subscription/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetProfiles 获取所有输出配置
+func GetProfiles(c *gin.Context) {
+	profiles := make([]models.Profile, 0)
+	if err := models.DB.Find(&profiles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, profiles)
+}
+
+// GetProfile 获取单个输出配置
+func GetProfile(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	var profile models.Profile
+	if err := models.DB.First(&profile, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "输出配置不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+// AddProfile 创建输出配置，并自动生成访问令牌
+func AddProfile(c *gin.Context) {
+	var profile models.Profile
+	if err := c.ShouldBindJSON(&profile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := normalizeProfile(&profile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := utils.RandomToken(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
+		return
+	}
+	profile.Token = token
+
+	if err := models.DB.Create(&profile).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, profile)
+}
+
+// UpdateProfile 更新输出配置，令牌只能通过重置接口修改
+func UpdateProfile(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	var existing models.Profile
+	if err := models.DB.First(&existing, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "输出配置不存在"})
+		return
+	}
+
+	var profile models.Profile
+	if err := c.ShouldBindJSON(&profile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := normalizeProfile(&profile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile.ID = existing.ID
+	profile.CreatedAt = existing.CreatedAt
+	profile.Token = existing.Token
+	if err := models.DB.Save(&profile).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	services.InvalidateCache()
+	c.JSON(http.StatusOK, profile)
+}
+
+// DeleteProfile 删除输出配置
+func DeleteProfile(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	if err := models.DB.Delete(&models.Profile{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	services.InvalidateCache()
+	c.JSON(http.StatusOK, gin.H{"message": "输出配置已删除"})
+}
+
+// RotateProfileToken 重置输出配置的访问令牌，旧链接立即失效
+func RotateProfileToken(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	var profile models.Profile
+	if err := models.DB.First(&profile, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "输出配置不存在"})
+		return
+	}
+
+	token, err := utils.RandomToken(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
+		return
+	}
+	if err := models.DB.Model(&profile).Update("token", token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	profile.Token = token
+
+	services.InvalidateCache()
+	c.JSON(http.StatusOK, profile)
+}
+
+// GetProfileSubscription 通过令牌获取输出配置对应的订阅内容
+func GetProfileSubscription(c *gin.Context) {
+	var profile models.Profile
+	if err := models.DB.Where("token = ?", c.Param("token")).First(&profile).Error; err != nil || !profile.Enabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "订阅不存在"})
+		return
+	}
+
+	format := resolveOutputFormat(c, profile.DefaultFormat)
+	c.Set("profile_id", profile.ID)
+	scope := "profile:" + strconv.FormatUint(uint64(profile.ID), 10)
+	options := resolveOutputOptions(&profile)
+	options.BaseURL = requestBaseURL(c)
+	options.RuleSetPath = "/sub/" + profile.Token + "/rules"
+	serveSubscription(c, scope, format, options, func() ([]models.Proxy, error) {
+		return buildProfileProxies(profile)
+	})
+}
+
+// buildProfileProxies 加载输出配置包含的节点，并依次执行过滤、去重和重命名
+func buildProfileProxies(profile models.Profile) ([]models.Proxy, error) {
+	proxies := make([]models.Proxy, 0)
+	if len(profile.SubscriptionIDs) > 0 || len(profile.ProxyIDs) > 0 {
+		// 与合并订阅一致，已禁用订阅的节点不参与输出
+		query := models.DB.Model(&models.Proxy{}).
+			Joins("LEFT JOIN subscriptions ON proxies.subscription_id = subscriptions.id").
+			Where("proxies.is_custom = ? OR subscriptions.enabled = ?", true, true)
+		switch {
+		case len(profile.SubscriptionIDs) > 0 && len(profile.ProxyIDs) > 0:
+			query = query.Where("(proxies.subscription_id IN ? AND proxies.is_custom = ?) OR proxies.id IN ?", profile.SubscriptionIDs, false, profile.ProxyIDs)
+		case len(profile.SubscriptionIDs) > 0:
+			query = query.Where("proxies.subscription_id IN ? AND proxies.is_custom = ?", profile.SubscriptionIDs, false)
+		default:
+			query = query.Where("proxies.id IN ?", profile.ProxyIDs)
+		}
+		if err := query.Find(&proxies).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	proxies, err := services.FilterProxiesByName(proxies, profile.IncludeFilter, profile.ExcludeFilter)
+	if err != nil {
+		return nil, err
+	}
+	proxies = services.FilterProxiesByCountry(proxies, profile.IncludeCountries, profile.ExcludeCountries)
+	proxies, err = dedupOutputProxies(proxies)
+	if err != nil {
+		return nil, err
+	}
+	return services.RenameProxies(proxies, profile.RenameRules)
+}
+
+// normalizeCountryCodes 将国家代码转换为大写并去重，代码必须为两位字母
+func normalizeCountryCodes(codes []string) ([]string, error) {
+	result := make([]string, 0, len(codes))
+	seen := make(map[string]struct{}, len(codes))
+	for _, code := range codes {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+			return nil, errors.New("无效的国家代码: " + code)
+		}
+		if _, exists := seen[code]; exists {
+			continue
+		}
+		seen[code] = struct{}{}
+		result = append(result, code)
+	}
+	return result, nil
+}
+
+// normalizeProfile 规范化并校验输出配置
+func normalizeProfile(profile *models.Profile) error {
+	profile.Name = strings.TrimSpace(profile.Name)
+	profile.IncludeFilter = strings.TrimSpace(profile.IncludeFilter)
+	profile.ExcludeFilter = strings.TrimSpace(profile.ExcludeFilter)
+	profile.DefaultFormat = strings.TrimSpace(profile.DefaultFormat)
+
+	if profile.Name == "" {
+		return errors.New("配置名称不能为空")
+	}
+	if profile.DefaultFormat != "" && !isSupportedFormat(profile.DefaultFormat) {
+		return errors.New("不支持的输出格式: " + profile.DefaultFormat)
+	}
+	profile.DeadNodePolicy = strings.TrimSpace(profile.DeadNodePolicy)
+	if profile.DeadNodePolicy != "" && !models.IsValidDeadNodePolicy(profile.DeadNodePolicy) {
+		return errors.New("不支持的失效节点处理方式: " + profile.DeadNodePolicy)
+	}
+	if profile.DeadNodeThreshold < 0 {
+		return errors.New("失效判定次数不能为负数")
+	}
+	profile.SortStrategy = strings.TrimSpace(profile.SortStrategy)
+	if profile.SortStrategy != "" && !services.IsValidSortStrategy(profile.SortStrategy) {
+		return errors.New("不支持的排序策略: " + profile.SortStrategy)
+	}
+	includeCountries, err := normalizeCountryCodes(profile.IncludeCountries)
+	if err != nil {
+		return err
+	}
+	excludeCountries, err := normalizeCountryCodes(profile.ExcludeCountries)
+	if err != nil {
+		return err
+	}
+	profile.IncludeCountries = includeCountries
+	profile.ExcludeCountries = excludeCountries
+	if profile.SubscriptionIDs == nil {
+		profile.SubscriptionIDs = []uint{}
+	}
+	if profile.ProxyIDs == nil {
+		profile.ProxyIDs = []uint{}
+	}
+	if profile.RenameRules == nil {
+		profile.RenameRules = []models.RenameRule{}
+	}
+	return services.ValidateNameFilters(profile.IncludeFilter, profile.ExcludeFilter, profile.RenameRules)
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"path/filepath"
+	"testing"
+
+	"proxy-subscription/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB 使用临时目录下的SQLite数据库替换全局连接，测试结束后恢复
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open() error = %v", err)
+	}
+	if err := db.AutoMigrate(&models.Subscription{}, &models.Proxy{}, &models.Setting{}, &models.Profile{}, &models.AccessToken{}, &models.AccessLog{}, &models.HealthCheck{}, &models.RuleSet{}, &models.ProxyChain{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+
+	original := models.DB
+	models.DB = db
+	t.Cleanup(func() {
+		models.DB = original
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+func createTestSubscription(t *testing.T, name string, enabled bool) models.Subscription {
+	t.Helper()
+	subscription := models.Subscription{Name: name, URL: "https://example.com/" + name, Type: "auto"}
+	if err := models.DB.Create(&subscription).Error; err != nil {
+		t.Fatalf("create subscription error = %v", err)
+	}
+	// enabled带有默认值，false需要单独更新
+	if !enabled {
+		if err := models.DB.Model(&subscription).Update("enabled", false).Error; err != nil {
+			t.Fatalf("disable subscription error = %v", err)
+		}
+	}
+	return subscription
+}
+
+func createTestProxy(t *testing.T, proxy models.Proxy) models.Proxy {
+	t.Helper()
+	if err := models.DB.Create(&proxy).Error; err != nil {
+		t.Fatalf("create proxy error = %v", err)
+	}
+	return proxy
+}
+
+func TestBuildProfileProxiesSkipsDisabledSubscriptions(t *testing.T) {
+	setupTestDB(t)
+
+	enabled := createTestSubscription(t, "enabled", true)
+	disabled := createTestSubscription(t, "disabled", false)
+	createTestProxy(t, models.Proxy{SubscriptionID: enabled.ID, Name: "enabled-01", Type: "trojan", Server: "a.example.com", Port: 443, Password: "a"})
+	disabledProxy := createTestProxy(t, models.Proxy{SubscriptionID: disabled.ID, Name: "disabled-01", Type: "trojan", Server: "b.example.com", Port: 443, Password: "b"})
+	custom := createTestProxy(t, models.Proxy{IsCustom: true, Name: "custom-01", Type: "trojan", Server: "c.example.com", Port: 443, Password: "c"})
+
+	profile := models.Profile{
+		Name:            "test",
+		SubscriptionIDs: []uint{enabled.ID, disabled.ID},
+		ProxyIDs:        []uint{custom.ID, disabledProxy.ID},
+	}
+	proxies, err := buildProfileProxies(profile)
+	if err != nil {
+		t.Fatalf("buildProfileProxies() error = %v", err)
+	}
+
+	names := map[string]bool{}
+	for _, proxy := range proxies {
+		names[proxy.Name] = true
+	}
+	assertEqual(t, len(proxies), 2, "proxy count")
+	assertEqual(t, names["enabled-01"], true, "enabled subscription node")
+	assertEqual(t, names["custom-01"], true, "custom node")
+	assertEqual(t, names["disabled-01"], false, "disabled subscription node")
+}
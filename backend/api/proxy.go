@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -11,6 +12,7 @@ import (
 
 	"proxy-subscription/models"
 	"proxy-subscription/services"
+	"proxy-subscription/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,7 +21,8 @@ import (
 func GetProxies(c *gin.Context) {
 	type ProxyWithSubscription struct {
 		models.Proxy
-		SubscriptionName string `json:"subscription_name"`
+		SubscriptionName string                  `json:"subscription_name"`
+		Health           *services.HealthSummary `json:"health"`
 	}
 
 	var proxies []models.Proxy
@@ -64,11 +67,22 @@ func GetProxies(c *gin.Context) {
 		}
 	}
 
+	healthSummaries, err := services.LoadHealthSummaries()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	healthKey := services.CurrentHealthKey()
+
 	results := make([]ProxyWithSubscription, 0, len(proxies))
-	// 为每个代理设置显示名称
+	// 为每个代理设置显示名称和最近的健康检查结果
 	for _, proxy := range proxies {
-		proxy.DisplayName = proxy.GetDisplayName()
+		proxy.FillDisplayFields()
 		result := ProxyWithSubscription{Proxy: proxy}
+		if summary, exists := healthSummaries[healthKey(proxy)]; exists {
+			result.Health = &summary
+		}
 		if proxy.IsCustom {
 			result.SubscriptionName = "自定义节点"
 		} else {
@@ -95,9 +109,19 @@ func GetProxy(c *gin.Context) {
 	}
 
 	// 设置显示名称
-	proxy.DisplayName = proxy.GetDisplayName()
+	proxy.FillDisplayFields()
 
-	c.JSON(http.StatusOK, proxy)
+	response := struct {
+		models.Proxy
+		Health *services.HealthSummary `json:"health"`
+	}{Proxy: proxy}
+	if healthSummaries, err := services.LoadHealthSummaries(); err == nil {
+		if summary, exists := healthSummaries[services.CurrentHealthKey()(proxy)]; exists {
+			response.Health = &summary
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // AddCustomProxy 添加手动自定义代理节点
@@ -116,6 +140,8 @@ func AddCustomProxy(c *gin.Context) {
 	proxy.IsCustom = true
 	proxy.ManualOverride = true
 	proxy.SourceKey = proxy.BuildSourceKey()
+	proxy.GeoLocation = models.GeoLocation{}
+	applyProxyGeoLocation(c, &proxy)
 
 	if err := models.DB.Create(&proxy).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -123,10 +149,113 @@ func AddCustomProxy(c *gin.Context) {
 	}
 
 	services.InvalidateCache()
-	proxy.DisplayName = proxy.GetDisplayName()
+	proxy.FillDisplayFields()
 	c.JSON(http.StatusCreated, proxy)
 }
 
+// ProxyImportRequest 批量导入节点的请求，内容可以是分享链接、Base64订阅或Clash片段
+type ProxyImportRequest struct {
+	Content string `json:"content"`
+}
+
+// ProxyImportResult 单个导入条目的结果，Status为created、duplicate或error
+type ProxyImportResult struct {
+	Line   int    `json:"line,omitempty"`
+	Status string `json:"status"`
+	ID     uint   `json:"id,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Input  string `json:"input,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportCustomProxies 从粘贴的内容批量创建自定义节点，返回逐条结果
+func ImportCustomProxies(c *gin.Context) {
+	var req ProxyImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Content) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请提供要导入的内容"})
+		return
+	}
+
+	format, entries, err := services.ParseImportContent(req.Content)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "format": format})
+		return
+	}
+
+	// 已存在的自定义节点不重复导入
+	var existingKeys []string
+	if err := models.DB.Model(&models.Proxy{}).Where("is_custom = ?", true).Pluck("source_key", &existingKeys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	seen := make(map[string]struct{}, len(existingKeys))
+	for _, key := range existingKeys {
+		seen[key] = struct{}{}
+	}
+
+	results := make([]ProxyImportResult, 0, len(entries))
+	counts := map[string]int{"created": 0, "duplicate": 0, "error": 0}
+	created := make([]models.Proxy, 0, len(entries))
+	for _, entry := range entries {
+		result := ProxyImportResult{Line: entry.Line}
+		switch {
+		case entry.Proxy == nil:
+			result.Status = "error"
+			result.Input = entry.Input
+			result.Error = entry.Error
+		default:
+			proxy := *entry.Proxy
+			result.Name = proxy.Name
+			result.Type = proxy.Type
+			if err := normalizeProxyFields(&proxy); err != nil {
+				result.Status = "error"
+				result.Error = err.Error()
+				break
+			}
+			proxy.SubscriptionID = 0
+			proxy.IsCustom = true
+			proxy.ManualOverride = true
+			proxy.SourceKey = proxy.BuildSourceKey()
+			proxy.GeoLocation = models.GeoLocation{}
+			if _, exists := seen[proxy.SourceKey]; exists {
+				result.Status = "duplicate"
+				break
+			}
+			if err := models.DB.Create(&proxy).Error; err != nil {
+				result.Status = "error"
+				result.Error = err.Error()
+				break
+			}
+			seen[proxy.SourceKey] = struct{}{}
+			result.Status = "created"
+			result.ID = proxy.ID
+			created = append(created, proxy)
+		}
+		counts[result.Status]++
+		results = append(results, result)
+	}
+
+	if counts["created"] > 0 {
+		// 导入完成后统一查询位置，相同服务器只解析一次
+		if err := services.ApplyGeoLocations(c.Request.Context(), created); err == nil {
+			if err := services.SaveGeoLocations(created); err != nil {
+				utils.Warn("保存节点位置失败: %v", err)
+			}
+		} else if !errors.Is(err, services.ErrGeoIPUnavailable) {
+			utils.Warn("查询节点位置失败: %v", err)
+		}
+		services.InvalidateCache()
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"format":    format,
+		"created":   counts["created"],
+		"duplicate": counts["duplicate"],
+		"failed":    counts["error"],
+		"results":   results,
+	})
+}
+
 // UpdateProxy 手动更新代理节点
 func UpdateProxy(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -153,6 +282,8 @@ func UpdateProxy(c *gin.Context) {
 
 	proxy.ID = existing.ID
 	proxy.CreatedAt = existing.CreatedAt
+	proxy.SortOrder = existing.SortOrder
+	proxy.GeoLocation = existing.GeoLocation
 	proxy.SubscriptionID = existing.SubscriptionID
 	proxy.IsCustom = existing.IsCustom
 	proxy.ManualOverride = true
@@ -161,16 +292,81 @@ func UpdateProxy(c *gin.Context) {
 	} else {
 		proxy.SourceKey = existing.BuildSourceKey()
 	}
+	applyProxyGeoLocation(c, &proxy)
 	if err := models.DB.Save(&proxy).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	services.InvalidateCache()
-	proxy.DisplayName = proxy.GetDisplayName()
+	proxy.FillDisplayFields()
 	c.JSON(http.StatusOK, proxy)
 }
 
+// applyProxyGeoLocation 查询单个节点的实际位置，没有GeoIP数据库时保留原有位置
+func applyProxyGeoLocation(c *gin.Context, proxy *models.Proxy) {
+	proxies := []models.Proxy{*proxy}
+	if err := services.ApplyGeoLocations(c.Request.Context(), proxies); err != nil && !errors.Is(err, services.ErrGeoIPUnavailable) {
+		utils.Warn("查询节点位置失败 %s: %v", proxy.Server, err)
+	}
+	proxy.GeoLocation = proxies[0].GeoLocation
+}
+
+// ProxyOrderRequest 手动排序请求，按数组顺序为节点分配序号
+type ProxyOrderRequest struct {
+	IDs []uint `json:"ids"`
+}
+
+// ReorderProxies 保存节点的手动顺序，未列出的节点清除序号，空数组表示清除全部手动顺序
+func ReorderProxies(c *gin.Context) {
+	var req ProxyOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的排序参数"})
+		return
+	}
+
+	seen := make(map[uint]struct{}, len(req.IDs))
+	for _, id := range req.IDs {
+		if _, exists := seen[id]; exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "节点ID重复: " + strconv.FormatUint(uint64(id), 10)})
+			return
+		}
+		seen[id] = struct{}{}
+	}
+	if len(req.IDs) > 0 {
+		var count int64
+		if err := models.DB.Model(&models.Proxy{}).Where("id IN ?", req.IDs).Count(&count).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if int(count) != len(req.IDs) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "部分节点不存在"})
+			return
+		}
+	}
+
+	tx := models.DB.Begin()
+	if err := tx.Model(&models.Proxy{}).Where("sort_order <> ?", 0).Update("sort_order", 0).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i, id := range req.IDs {
+		if err := tx.Model(&models.Proxy{}).Where("id = ?", id).Update("sort_order", i+1).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	services.InvalidateCache()
+	c.JSON(http.StatusOK, gin.H{"message": "节点顺序已保存", "count": len(req.IDs)})
+}
+
 // DeleteCustomProxy 删除手动自定义代理节点
 func DeleteCustomProxy(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -189,7 +385,19 @@ func DeleteCustomProxy(c *gin.Context) {
 		return
 	}
 
-	if err := models.DB.Delete(&proxy).Error; err != nil {
+	// 删除节点及以其为目标或前置节点的链式关系
+	tx := models.DB.Begin()
+	if err := services.DeleteProxyChains(tx, []uint{proxy.ID}); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Delete(&proxy).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -250,111 +458,315 @@ func normalizeProxyFields(proxy *models.Proxy) error {
 			return errors.New("AnyTLS/Hysteria2 节点必须填写密码")
 		}
 		proxy.TLS = true
+	case "http", "socks":
+		// 用户名保存在RawConfig中，用户名和密码均可为空
+		if rawConfigValue(proxyRawConfig(*proxy), "username") != "" && proxy.Password == "" {
+			return errors.New("HTTP/SOCKS 节点填写用户名时必须填写密码")
+		}
+	case "ssr":
+		if proxy.Method == "" || proxy.Password == "" {
+			return errors.New("SSR 节点必须填写加密方式和密码")
+		}
+		rawConfig := proxyRawConfig(*proxy)
+		if rawConfigValue(rawConfig, "protocol") == "" {
+			rawConfig["protocol"] = "origin"
+		}
+		if rawConfigValue(rawConfig, "obfs") == "" {
+			rawConfig["obfs"] = "plain"
+		}
+		setProxyRawConfig(proxy, rawConfig)
+	case "hysteria":
+		rawConfig := proxyRawConfig(*proxy)
+		if rawConfigInt(rawConfig, "up") <= 0 || rawConfigInt(rawConfig, "down") <= 0 {
+			return errors.New("Hysteria 节点必须填写上行和下行带宽（Mbps）")
+		}
+		proxy.TLS = true
+	case "wireguard":
+		rawConfig := proxyRawConfig(*proxy)
+		for _, key := range []string{"private_key", "public_key", "pre_shared_key"} {
+			value := rawConfigValue(rawConfig, key)
+			if value == "" && key == "pre_shared_key" {
+				continue
+			}
+			if decoded, err := base64.StdEncoding.DecodeString(value); err != nil || len(decoded) != 32 {
+				return errors.New("WireGuard 节点的私钥、公钥和预共享密钥必须是32字节的Base64密钥")
+			}
+		}
+		ip, ipv6 := rawConfigValue(rawConfig, "ip"), rawConfigValue(rawConfig, "ipv6")
+		if ip == "" && ipv6 == "" {
+			return errors.New("WireGuard 节点必须填写本地地址")
+		}
+		for _, addr := range []string{ip, ipv6} {
+			if addr != "" && !isValidIPOrCIDR(addr) {
+				return errors.New("无效的 WireGuard 本地地址: " + addr)
+			}
+		}
 	default:
-		return errors.New("仅支持 vmess、vless、ss、trojan、tuic、anytls、hysteria2 类型")
+		return errors.New("仅支持 vmess、vless、ss、ssr、trojan、tuic、anytls、hysteria、hysteria2、wireguard、http、socks 类型")
 	}
 	return nil
 }
 
+// setProxyRawConfig 将修改后的RawConfig写回节点
+func setProxyRawConfig(proxy *models.Proxy, rawConfig map[string]interface{}) {
+	if data, err := json.Marshal(rawConfig); err == nil {
+		proxy.RawConfig = string(data)
+	}
+}
+
+// isValidIPOrCIDR 判断地址是否为IP或CIDR
+func isValidIPOrCIDR(addr string) bool {
+	if strings.Contains(addr, "/") {
+		_, _, err := net.ParseCIDR(addr)
+		return err == nil
+	}
+	return net.ParseIP(addr) != nil
+}
+
 // GetMergedSubscription 获取合并后的订阅
 func GetMergedSubscription(c *gin.Context) {
-	format := c.DefaultQuery("format", "base64")
+	format := resolveOutputFormat(c, "")
+
+	options := resolveOutputOptions(nil)
+	token := c.Param("token")
+	if token == "" {
+		token = c.Query("token")
+	}
+	options.BaseURL = requestBaseURL(c)
+	options.RuleSetPath = "/api/rules/" + token
+
+	serveSubscription(c, "merged", format, options, func() ([]models.Proxy, error) {
+		// 获取所有启用订阅的节点以及自定义节点
+		var proxies []models.Proxy
+		if err := models.DB.Joins("LEFT JOIN subscriptions ON proxies.subscription_id = subscriptions.id").
+			Where("proxies.is_custom = ? OR subscriptions.enabled = ?", true, true).
+			Find(&proxies).Error; err != nil {
+			return nil, err
+		}
+		return dedupOutputProxies(proxies)
+	})
+}
+
+// resolveOutputFormat 确定输出格式：format查询参数 > User-Agent识别 > fallback > 默认格式设置
+func resolveOutputFormat(c *gin.Context, fallback string) string {
+	if format := c.Query("format"); format != "" {
+		return format
+	}
+
+	// 同一链接按客户端返回不同内容，需告知中间缓存
+	c.Header("Vary", "User-Agent")
+	if format := services.DetectFormatFromUserAgent(c.Request.UserAgent(), services.LoadUAFormatRules(), isSupportedFormat); format != "" {
+		return format
+	}
+	if fallback != "" {
+		return fallback
+	}
+	return defaultOutputFormat()
+}
+
+// defaultOutputFormat 读取默认格式设置，设置无效时使用base64
+func defaultOutputFormat() string {
+	format := models.GetSetting(models.SettingDefaultFormat, "base64")
+	if !isSupportedFormat(format) {
+		return "base64"
+	}
+	return format
+}
+
+// outputOptions 输出订阅时的排序、失效节点处理方式、名称国旗、地区分组和规则集地址
+type outputOptions struct {
+	SortStrategy string
+	DeadNodes    deadNodeOptions
+	FlagEmoji    bool // 在节点名称前添加国旗emoji
+	RegionGroups services.RegionGroupOptions
+	// BaseURL 访问本服务的地址，与 RuleSetPath（包含访问令牌）拼接为引用托管规则集的地址前缀
+	BaseURL     string
+	RuleSetPath string
+}
+
+// resolveOutputOptions 读取输出设置，输出配置中的设置优先于全局设置
+func resolveOutputOptions(profile *models.Profile) outputOptions {
+	options := outputOptions{
+		SortStrategy: models.GetSetting(models.SettingOutputSort, services.SortStrategyNone),
+		DeadNodes:    resolveDeadNodeOptions(profile),
+		FlagEmoji:    models.GetSetting(models.SettingExportFlag, "false") == "true",
+		RegionGroups: services.LoadRegionGroupOptions(),
+	}
+	if profile != nil && profile.SortStrategy != "" {
+		options.SortStrategy = profile.SortStrategy
+	}
+	if !services.IsValidSortStrategy(options.SortStrategy) {
+		options.SortStrategy = services.SortStrategyNone
+	}
+	return options
+}
+
+// serveSubscription 按格式输出订阅内容，优先使用缓存
+// debug=health 查询参数返回失效节点报告而不是订阅内容
+func serveSubscription(c *gin.Context, scope string, format string, options outputOptions, build func() ([]models.Proxy, error)) {
+	if !isSupportedFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的格式: " + format})
+		return
+	}
+	if c.Query("debug") == "health" {
+		serveDeadNodeReport(c, options.DeadNodes, build)
+		return
+	}
+	// 规则集路径包含访问令牌，不同令牌的内容分别缓存；访问地址来自请求，不放入缓存键，
+	// 与缓存内容生成时的地址不同时重新生成并覆盖，避免缓存条目随请求的Host无限增长
+	cacheKey := scope + ":" + format + ":" + options.RuleSetPath
 
 	// 尝试从缓存获取
-	if content, contentType, found := services.GetSubscriptionCache(format); found {
-		c.Header("Content-Type", contentType)
-		c.Header("X-Cache", "HIT")
-		c.String(http.StatusOK, content)
+	if item, found := services.GetSubscriptionCache(cacheKey); found && item.Variant == options.BaseURL {
+		writeSubscription(c, item, "HIT")
+		recordSubscriptionAccess(c, format, true, len(item.Content))
 		return
 	}
 
 	// 缓存未命中，生成新的内容
-	// 获取所有启用的订阅
-	var subscriptions []models.Subscription
-	if err := models.DB.Where("enabled = ?", true).Find(&subscriptions).Error; err != nil {
+	proxies, err := build()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if options.FlagEmoji {
+		proxies = services.AddFlagEmoji(proxies)
+	}
+	proxies = services.EnsureUniqueNames(proxies)
+	// 先排序再处理失效节点，后移的失效节点始终位于末尾
+	proxies, err = sortOutputProxies(proxies, options.SortStrategy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	proxies, dead, err := applyDeadNodePolicy(proxies, options.DeadNodes)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 获取所有代理节点
-	var proxies []models.Proxy
-	if err := models.DB.Joins("LEFT JOIN subscriptions ON proxies.subscription_id = subscriptions.id").
-		Where("proxies.is_custom = ? OR subscriptions.enabled = ?", true, true).
-		Find(&proxies).Error; err != nil {
+	// 链式代理的前置节点名称在重命名之后确定
+	chains, err := services.LoadProxyChains()
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	proxies, skippedChains := services.ResolveProxyChains(proxies, chains)
 
 	// 根据请求的格式生成订阅内容
-	content, contentType, err := generateSubscriptionContent(proxies, format)
+	ruleSets, err := services.LoadEnabledRuleSets()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ruleSetBaseURL := ""
+	if options.RuleSetPath != "" {
+		ruleSetBaseURL = options.BaseURL + options.RuleSetPath
+	}
+	generated, err := generateSubscriptionContent(proxies, format, GenerateOptions{
+		RegionGroups:   options.RegionGroups,
+		RuleSets:       ruleSets,
+		RuleSetBaseURL: ruleSetBaseURL,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	generated.SkippedChains = append(skippedChains, generated.SkippedChains...)
+
+	item := newSubscriptionItem(generated, format)
+	addDeadNodeHeaders(item.Headers, options.DeadNodes.Policy, dead)
+	item.Variant = options.BaseURL
 
 	// 存入缓存
-	services.SetSubscriptionCache(format, content, contentType)
-
-	// 设置响应头
-	c.Header("Content-Type", contentType)
-	c.Header("X-Cache", "MISS")
-	c.String(http.StatusOK, content)
-}
-
-// 生成订阅内容
-func generateSubscriptionContent(proxies []models.Proxy, format string) (string, string, error) {
-	var content strings.Builder
-	contentType := "text/plain;charset=utf-8"
-
-	// 根据不同格式生成内容
-	switch format {
-	case "base64":
-		// 生成每个代理的URL
-		for _, proxy := range proxies {
-			var proxyURL string
-			switch proxy.Type {
-			case "vmess":
-				// 生成vmess链接
-				proxyURL = generateVmessURL(proxy)
-			case "vless":
-				proxyURL = generateVlessURL(proxy)
-			case "ss":
-				// 生成ss链接
-				proxyURL = generateSSURL(proxy)
-			case "trojan":
-				// 生成trojan链接
-				proxyURL = generateTrojanURL(proxy)
-			case "tuic", "anytls", "hysteria2":
-				proxyURL = generateCredentialProxyURL(proxy)
-			default:
-				continue
-			}
+	services.SetSubscriptionCache(cacheKey, item)
 
-			if proxyURL != "" {
-				content.WriteString(proxyURL + "\n")
-			}
+	writeSubscription(c, item, "MISS")
+	recordSubscriptionAccess(c, format, false, len(item.Content))
+}
+
+// newSubscriptionItem 将生成结果包装为响应内容，该格式无法表达的节点及链式节点被跳过，通过响应头告知客户端
+func newSubscriptionItem(generated GeneratedSubscription, format string) services.CacheItem {
+	item := services.CacheItem{
+		Content:     generated.Content,
+		ContentType: generated.ContentType,
+		Headers:     map[string]string{},
+	}
+	if len(generated.Skipped) > 0 {
+		types := skippedTypes(generated.Skipped)
+		item.Headers["X-Skipped-Proxies"] = strconv.Itoa(len(generated.Skipped))
+		item.Headers["X-Skipped-Types"] = strings.Join(types, ",")
+		utils.Warn("%s 格式不支持 %s 类型，已跳过 %d 个节点", format, strings.Join(types, "/"), len(generated.Skipped))
+	}
+	if len(generated.SkippedChains) > 0 {
+		item.Headers["X-Skipped-Chains"] = strconv.Itoa(len(generated.SkippedChains))
+		for _, chain := range generated.SkippedChains {
+			utils.Warn("%s 格式已跳过链式节点 %s（前置节点 %s）: %s", format, chain.Name, chain.Via, chain.Reason)
 		}
+	}
+	return item
+}
 
-		// Base64编码
-		encodedContent := base64.StdEncoding.EncodeToString([]byte(content.String()))
-		return encodedContent, contentType, nil
+// writeSubscription 写入订阅内容及缓存的响应头
+func writeSubscription(c *gin.Context, item services.CacheItem, cacheStatus string) {
+	for key, value := range item.Headers {
+		c.Header(key, value)
+	}
+	c.Header("Content-Type", item.ContentType)
+	c.Header("X-Cache", cacheStatus)
+	c.String(http.StatusOK, item.Content)
+}
 
-	case "clash":
-		// 生成Clash配置
-		yamlContent := generateClashConfig(proxies)
-		return yamlContent, "text/yaml;charset=utf-8", nil
+// dedupOutputProxies 按设置对输出节点进行跨订阅去重
+func dedupOutputProxies(proxies []models.Proxy) ([]models.Proxy, error) {
+	if models.GetSetting(models.SettingDedupEnabled, "true") != "true" {
+		return proxies, nil
+	}
 
-	case "json":
-		// 生成JSON格式
-		jsonContent, err := generateJSONConfig(proxies)
+	priorities, err := loadSubscriptionPriorities()
+	if err != nil {
+		return nil, err
+	}
+	return services.DeduplicateProxies(proxies, services.DedupOptions{
+		Strategy:   models.GetSetting(models.SettingDedupStrategy, services.DedupStrategyCustomFirst),
+		Priorities: priorities,
+	}), nil
+}
+
+// sortOutputProxies 按排序策略调整输出顺序，只加载策略需要的数据
+func sortOutputProxies(proxies []models.Proxy, strategy string) ([]models.Proxy, error) {
+	options := services.SortOptions{Strategy: strategy}
+	switch strategy {
+	case services.SortStrategyNone:
+		return proxies, nil
+	case services.SortStrategyLatency:
+		health, err := services.LoadHealthSummaries()
 		if err != nil {
-			return "", "", err
+			return nil, err
 		}
-		return jsonContent, "application/json;charset=utf-8", nil
+		options.Health = health
+		options.HealthKey = services.CurrentHealthKey()
+	case services.SortStrategyPriority:
+		priorities, err := loadSubscriptionPriorities()
+		if err != nil {
+			return nil, err
+		}
+		options.Priorities = priorities
+	}
+	return services.SortProxies(proxies, options), nil
+}
 
-	default:
-		return "", "", errors.New("不支持的格式: " + format)
+// loadSubscriptionPriorities 读取订阅ID到优先级的映射
+func loadSubscriptionPriorities() (map[uint]int, error) {
+	var subscriptions []models.Subscription
+	if err := models.DB.Select("id", "priority").Find(&subscriptions).Error; err != nil {
+		return nil, err
 	}
+	priorities := make(map[uint]int, len(subscriptions))
+	for _, subscription := range subscriptions {
+		priorities[subscription.ID] = subscription.Priority
+	}
+	return priorities, nil
 }
 
 // 生成Vmess URL
@@ -595,177 +1007,131 @@ func generateCredentialProxyURL(proxy models.Proxy) string {
 	return result
 }
 
-// 生成Clash配置
-func generateClashConfig(proxies []models.Proxy) string {
-	// 实现Clash配置生成逻辑
-	var yaml strings.Builder
+// generateAuthProxyURL 生成HTTP/HTTPS和SOCKS5代理链接，用户名保存在RawConfig中
+func generateAuthProxyURL(proxy models.Proxy) string {
+	rawConfig := proxyRawConfig(proxy)
+	link := url.URL{Host: net.JoinHostPort(proxy.Server, strconv.Itoa(proxy.Port))}
+	params := url.Values{}
 
-	yaml.WriteString("proxies:\n")
-	for _, proxy := range proxies {
-		// 根据代理类型生成对应的Clash配置
-		yaml.WriteString("  - name: " + proxy.Name + "\n")
-		yaml.WriteString("    type: " + proxy.Type + "\n")
-		yaml.WriteString("    server: " + proxy.Server + "\n")
-		yaml.WriteString("    port: " + strconv.Itoa(proxy.Port) + "\n")
-
-		// 根据代理类型添加特定配置
-		switch proxy.Type {
-		case "ss":
-			yaml.WriteString("    cipher: " + proxy.Method + "\n")
-			yaml.WriteString("    password: " + proxy.Password + "\n")
-
-			// 添加插件配置
-			if proxy.Plugin != "" {
-				yaml.WriteString("    plugin: " + proxy.Plugin + "\n")
-				if proxy.PluginOpts != "" {
-					yaml.WriteString("    plugin-opts:\n")
-					// 解析插件选项
-					opts := strings.Split(proxy.PluginOpts, ";")
-					for _, opt := range opts {
-						if kv := strings.SplitN(opt, "=", 2); len(kv) == 2 {
-							yaml.WriteString("      " + kv[0] + ": " + kv[1] + "\n")
-						}
-					}
-				}
-			}
+	switch proxy.Type {
+	case "http":
+		link.Scheme = "http"
+		if proxy.TLS {
+			link.Scheme = "https"
+		}
+	case "socks":
+		link.Scheme = "socks5"
+		if proxy.TLS {
+			params.Set("tls", "true")
+		}
+		if udp, ok := rawConfig["udp"].(bool); ok && udp {
+			params.Set("udp", "true")
+		}
+	default:
+		return ""
+	}
 
-		case "vmess":
-			yaml.WriteString("    uuid: " + proxy.UUID + "\n")
-			if proxy.Network != "" {
-				yaml.WriteString("    network: " + proxy.Network + "\n")
-			}
-			if proxy.TLS {
-				yaml.WriteString("    tls: true\n")
-			}
-			if proxy.Path != "" {
-				yaml.WriteString("    ws-path: " + proxy.Path + "\n")
-			}
-			if proxy.Host != "" {
-				yaml.WriteString("    ws-headers:\n")
-				yaml.WriteString("      Host: " + proxy.Host + "\n")
-			}
+	if username := rawConfigValue(rawConfig, "username"); username != "" || proxy.Password != "" {
+		link.User = url.UserPassword(username, proxy.Password)
+	}
+	if proxy.SNI != "" && proxy.SNI != proxy.Server {
+		params.Set("sni", proxy.SNI)
+	}
+	if proxy.AllowInsecure {
+		params.Set("skip-cert-verify", "true")
+	}
+	link.RawQuery = params.Encode()
 
-		case "vless":
-			yaml.WriteString("    uuid: " + proxy.UUID + "\n")
-			if proxy.Network != "" {
-				yaml.WriteString("    network: " + proxy.Network + "\n")
-			}
-			if proxy.TLS {
-				yaml.WriteString("    tls: true\n")
-			}
-			if proxy.SNI != "" {
-				yaml.WriteString("    servername: " + proxy.SNI + "\n")
-			}
-			if proxy.Path != "" {
-				yaml.WriteString("    ws-path: " + proxy.Path + "\n")
-			}
-			if proxy.Host != "" {
-				yaml.WriteString("    ws-headers:\n")
-				yaml.WriteString("      Host: " + proxy.Host + "\n")
-			}
+	result := link.String()
+	if proxy.Name != "" {
+		result += "#" + url.QueryEscape(proxy.Name)
+	}
+	return result
+}
 
-		case "trojan":
-			yaml.WriteString("    password: " + proxy.Password + "\n")
-			if proxy.SNI != "" {
-				yaml.WriteString("    sni: " + proxy.SNI + "\n")
-			}
-			if proxy.ALPN != "" {
-				yaml.WriteString("    alpn:\n")
-				for _, alpn := range strings.Split(proxy.ALPN, ",") {
-					yaml.WriteString("      - " + alpn + "\n")
-				}
-			}
-			if proxy.AllowInsecure {
-				yaml.WriteString("    skip-cert-verify: true\n")
-			}
-		case "tuic":
-			yaml.WriteString("    uuid: ")
-			yaml.WriteString(proxy.UUID)
-			yaml.WriteString("\n")
-			yaml.WriteString("    password: " + proxy.Password + "\n")
-			if proxy.SNI != "" {
-				yaml.WriteString("    sni: " + proxy.SNI + "\n")
-			}
-			if proxy.ALPN != "" {
-				yaml.WriteString("    alpn:\n")
-				for _, alpn := range strings.Split(proxy.ALPN, ",") {
-					yaml.WriteString("      - " + strings.TrimSpace(alpn) + "\n")
-				}
-			}
-			if proxy.AllowInsecure {
-				yaml.WriteString("    skip-cert-verify: true\n")
-			}
-		case "anytls", "hysteria2":
-			yaml.WriteString("    password: " + proxy.Password + "\n")
-			if proxy.SNI != "" {
-				yaml.WriteString("    sni: " + proxy.SNI + "\n")
-			}
-			if proxy.ALPN != "" {
-				yaml.WriteString("    alpn:\n")
-				for _, alpn := range strings.Split(proxy.ALPN, ",") {
-					yaml.WriteString("      - " + strings.TrimSpace(alpn) + "\n")
-				}
-			}
-			if proxy.AllowInsecure {
-				yaml.WriteString("    skip-cert-verify: true\n")
-			}
-		}
+// generateSSRURL 生成SSR链接
+// 格式：ssr://base64(server:port:protocol:method:obfs:base64(password)/?obfsparam=base64&protoparam=base64&remarks=base64)
+func generateSSRURL(proxy models.Proxy) string {
+	if proxy.Method == "" || proxy.Password == "" {
+		return ""
+	}
+	rawConfig := proxyRawConfig(proxy)
+	encode := base64.RawURLEncoding.EncodeToString
+
+	protocol := firstNonEmpty(rawConfigValue(rawConfig, "protocol"), "origin")
+	obfs := firstNonEmpty(rawConfigValue(rawConfig, "obfs"), "plain")
+	main := strings.Join([]string{proxy.Server, strconv.Itoa(proxy.Port), protocol, proxy.Method, obfs, encode([]byte(proxy.Password))}, ":")
 
-		yaml.WriteString("\n")
+	params := []string{
+		"obfsparam=" + encode([]byte(rawConfigValue(rawConfig, "obfsparam"))),
+		"protoparam=" + encode([]byte(rawConfigValue(rawConfig, "protoparam"))),
+		"remarks=" + encode([]byte(proxy.Name)),
 	}
+	return "ssr://" + encode([]byte(main+"/?"+strings.Join(params, "&")))
+}
 
-	return yaml.String()
+// generateHysteriaURL 生成Hysteria(v1)分享链接
+func generateHysteriaURL(proxy models.Proxy) string {
+	rawConfig := proxyRawConfig(proxy)
+	params := url.Values{}
+	params.Set("upmbps", strconv.Itoa(rawConfigInt(rawConfig, "up")))
+	params.Set("downmbps", strconv.Itoa(rawConfigInt(rawConfig, "down")))
+	if protocol := rawConfigValue(rawConfig, "protocol"); protocol != "" {
+		params.Set("protocol", protocol)
+	}
+	if proxy.Password != "" {
+		params.Set("auth", proxy.Password)
+	}
+	if proxy.SNI != "" {
+		params.Set("peer", proxy.SNI)
+	}
+	if proxy.ALPN != "" {
+		params.Set("alpn", proxy.ALPN)
+	}
+	if proxy.AllowInsecure {
+		params.Set("insecure", "1")
+	}
+	if obfs := rawConfigValue(rawConfig, "obfs"); obfs != "" {
+		params.Set("obfsParam", obfs)
+	}
+
+	result := "hysteria://" + net.JoinHostPort(proxy.Server, strconv.Itoa(proxy.Port)) + "?" + params.Encode()
+	if proxy.Name != "" {
+		result += "#" + url.QueryEscape(proxy.Name)
+	}
+	return result
 }
 
-// 生成JSON配置
-func generateJSONConfig(proxies []models.Proxy) (string, error) {
-	// 实现JSON配置生成逻辑
-	type jsonProxy struct {
-		Name          string `json:"name"`
-		Type          string `json:"type"`
-		Server        string `json:"server"`
-		Port          int    `json:"port"`
-		UUID          string `json:"uuid,omitempty"`
-		Password      string `json:"password,omitempty"`
-		Method        string `json:"method,omitempty"`
-		Network       string `json:"network,omitempty"`
-		Path          string `json:"path,omitempty"`
-		Host          string `json:"host,omitempty"`
-		TLS           bool   `json:"tls,omitempty"`
-		SNI           string `json:"sni,omitempty"`
-		ALPN          string `json:"alpn,omitempty"`
-		Plugin        string `json:"plugin,omitempty"`
-		PluginOpts    string `json:"plugin_opts,omitempty"`
-		AllowInsecure bool   `json:"allow_insecure,omitempty"`
+// generateWireGuardURL 生成WireGuard分享链接，私钥作为用户信息
+func generateWireGuardURL(proxy models.Proxy) string {
+	rawConfig := proxyRawConfig(proxy)
+	privateKey := rawConfigValue(rawConfig, "private_key")
+	if privateKey == "" {
+		return ""
 	}
 
-	var jsonProxies []jsonProxy
-	for _, proxy := range proxies {
-		jp := jsonProxy{
-			Name:          proxy.Name,
-			Type:          proxy.Type,
-			Server:        proxy.Server,
-			Port:          proxy.Port,
-			UUID:          proxy.UUID,
-			Password:      proxy.Password,
-			Method:        proxy.Method,
-			Network:       proxy.Network,
-			Path:          proxy.Path,
-			Host:          proxy.Host,
-			TLS:           proxy.TLS,
-			SNI:           proxy.SNI,
-			ALPN:          proxy.ALPN,
-			Plugin:        proxy.Plugin,
-			PluginOpts:    proxy.PluginOpts,
-			AllowInsecure: proxy.AllowInsecure,
-		}
-		jsonProxies = append(jsonProxies, jp)
-	}
-
-	jsonData, err := json.Marshal(jsonProxies)
-	if err != nil {
-		return "", err
+	params := url.Values{}
+	params.Set("publickey", rawConfigValue(rawConfig, "public_key"))
+	addresses := make([]string, 0, 2)
+	for _, key := range []string{"ip", "ipv6"} {
+		if addr := rawConfigValue(rawConfig, key); addr != "" {
+			addresses = append(addresses, addr)
+		}
+	}
+	params.Set("address", strings.Join(addresses, ","))
+	if preSharedKey := rawConfigValue(rawConfig, "pre_shared_key"); preSharedKey != "" {
+		params.Set("presharedkey", preSharedKey)
+	}
+	if mtu := rawConfigInt(rawConfig, "mtu"); mtu > 0 {
+		params.Set("mtu", strconv.Itoa(mtu))
+	}
+	if reserved := rawConfigValue(rawConfig, "reserved"); reserved != "" {
+		params.Set("reserved", reserved)
 	}
 
-	return string(jsonData), nil
+	result := "wireguard://" + url.QueryEscape(privateKey) + "@" + net.JoinHostPort(proxy.Server, strconv.Itoa(proxy.Port)) + "?" + params.Encode()
+	if proxy.Name != "" {
+		result += "#" + url.QueryEscape(proxy.Name)
+	}
+	return result
 }
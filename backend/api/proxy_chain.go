@@ -0,0 +1,111 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"proxy-subscription/models"
+	"proxy-subscription/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// proxyChainResponse 链式代理及两端节点的名称
+type proxyChainResponse struct {
+	models.ProxyChain
+	ProxyName string `json:"proxy_name"`
+	ViaName   string `json:"via_name"`
+}
+
+// GetProxyChains 获取所有链式代理
+func GetProxyChains(c *gin.Context) {
+	chains := make([]models.ProxyChain, 0)
+	if err := models.DB.Order("id").Find(&chains).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ids := make([]uint, 0, len(chains)*2)
+	for _, chain := range chains {
+		ids = append(ids, chain.ProxyID, chain.ViaProxyID)
+	}
+	var proxies []models.Proxy
+	if len(ids) > 0 {
+		if err := models.DB.Select("id", "name").Where("id IN ?", ids).Find(&proxies).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	names := make(map[uint]string, len(proxies))
+	for _, proxy := range proxies {
+		names[proxy.ID] = proxy.Name
+	}
+
+	result := make([]proxyChainResponse, 0, len(chains))
+	for _, chain := range chains {
+		result = append(result, proxyChainResponse{
+			ProxyChain: chain,
+			ProxyName:  names[chain.ProxyID],
+			ViaName:    names[chain.ViaProxyID],
+		})
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// AddProxyChain 为节点设置前置节点，每个节点只能有一个前置节点，不允许形成循环
+func AddProxyChain(c *gin.Context) {
+	var chain models.ProxyChain
+	if err := c.ShouldBindJSON(&chain); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	chain.ID = 0
+
+	for _, id := range []uint{chain.ProxyID, chain.ViaProxyID} {
+		var count int64
+		models.DB.Model(&models.Proxy{}).Where("id = ?", id).Count(&count)
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "代理节点不存在: " + strconv.FormatUint(uint64(id), 10)})
+			return
+		}
+	}
+
+	chains, err := services.LoadProxyChains()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if _, exists := chains[chain.ProxyID]; exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "该节点已设置前置节点"})
+		return
+	}
+	if err := services.ValidateProxyChain(chains, chain.ProxyID, chain.ViaProxyID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := models.DB.Create(&chain).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	services.InvalidateCache()
+	c.JSON(http.StatusCreated, chain)
+}
+
+// DeleteProxyChain 删除链式代理
+func DeleteProxyChain(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	if err := models.DB.Delete(&models.ProxyChain{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	services.InvalidateCache()
+	c.JSON(http.StatusOK, gin.H{"message": "链式代理已删除"})
+}
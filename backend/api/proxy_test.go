@@ -10,6 +10,9 @@ import (
 	"testing"
 
 	"proxy-subscription/models"
+	"proxy-subscription/services"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestGenerateVmessURLRoundTrip(t *testing.T) {
@@ -97,12 +100,12 @@ func TestGenerateSubscriptionContentBase64RoundTrip(t *testing.T) {
 		},
 	}
 
-	content, _, err := generateSubscriptionContent(proxies, "base64")
+	generated, err := generateSubscriptionContent(proxies, "base64", GenerateOptions{})
 	if err != nil {
 		t.Fatalf("generateSubscriptionContent() error = %v", err)
 	}
 
-	decoded, err := base64.StdEncoding.DecodeString(content)
+	decoded, err := base64.StdEncoding.DecodeString(generated.Content)
 	if err != nil {
 		t.Fatalf("base64 decode generated content error = %v", err)
 	}
@@ -116,6 +119,319 @@ func TestGenerateSubscriptionContentBase64RoundTrip(t *testing.T) {
 	assertProxyFieldsEqual(t, roundTrippedVless, proxies[1])
 }
 
+func TestGenerateSubscriptionContentSkipsUnsupportedTypes(t *testing.T) {
+	proxies := []models.Proxy{
+		{Type: "trojan", Name: "trojan-hk", Server: "hk.example.com", Port: 443, Password: "secret"},
+		{Type: "vless", Name: "vless-us", Server: "us.example.com", Port: 443, UUID: "10e25f65-d4a3-4e5a-98eb-e459f1899e55"},
+		{Type: "ssr", Name: "ssr-jp", Server: "jp.example.com", Port: 8388, Method: "aes-256-cfb", Password: "secret"},
+	}
+
+	generated, err := generateSubscriptionContent(proxies, "surge", GenerateOptions{})
+	if err != nil {
+		t.Fatalf("generateSubscriptionContent() error = %v", err)
+	}
+	if strings.Contains(generated.Content, "vless-us") || !strings.Contains(generated.Content, "trojan-hk") {
+		t.Fatalf("surge content = %q, want only the trojan proxy", generated.Content)
+	}
+	assertEqual(t, len(generated.Skipped), 2, "skipped count")
+	assertEqual(t, strings.Join(skippedTypes(generated.Skipped), ","), "ssr,vless", "skipped types")
+
+	generated, err = generateSubscriptionContent(proxies, "json", GenerateOptions{})
+	if err != nil {
+		t.Fatalf("generateSubscriptionContent() error = %v", err)
+	}
+	assertEqual(t, len(generated.Skipped), 0, "json skipped count")
+
+	if _, err := generateSubscriptionContent(proxies, "unknown", GenerateOptions{}); err == nil {
+		t.Fatal("generateSubscriptionContent() accepted an unknown format")
+	}
+}
+
+func TestGenerateSubscriptionContentSingbox(t *testing.T) {
+	rawConfig := `{"encryption":"none","flow":"xtls-rprx-vision","security":"reality","sni":"apple.com","fp":"chrome","pbk":"PR8JkbArJstRJb8y584SqRkjpMqbyHoZupc2L5sT_Gs","sid":"5f7aaec5","type":"tcp","headerType":"none"}`
+	proxies := []models.Proxy{
+		{Type: "vless", Name: "vl-reality", Server: "8.209.254.248", Port: 18543, UUID: "10e25f65-d4a3-4e5a-98eb-e459f1899e55", Network: "tcp", SNI: "apple.com", TLS: true, RawConfig: rawConfig},
+		{Type: "vmess", Name: "vm-ws", Server: "8.209.254.248", Port: 2082, UUID: "10e25f65-d4a3-4e5a-98eb-e459f1899e55", Network: "ws", Path: "/ws", Host: "www.bing.com"},
+	}
+
+	generated, err := generateSubscriptionContent(proxies, "singbox", GenerateOptions{})
+	if err != nil {
+		t.Fatalf("generateSubscriptionContent() error = %v", err)
+	}
+
+	var config struct {
+		Outbounds []map[string]interface{} `json:"outbounds"`
+	}
+	if err := json.Unmarshal([]byte(generated.Content), &config); err != nil {
+		t.Fatalf("json.Unmarshal(singbox) error = %v", err)
+	}
+	outbounds := make(map[string]map[string]interface{})
+	for _, outbound := range config.Outbounds {
+		outbounds[outbound["tag"].(string)] = outbound
+	}
+
+	vless := outbounds["vl-reality"]
+	assertEqual(t, vless["type"], "vless", "vless type")
+	assertEqual(t, vless["flow"], "xtls-rprx-vision", "vless flow")
+	tls := vless["tls"].(map[string]interface{})
+	assertEqual(t, tls["server_name"], "apple.com", "vless server_name")
+	reality := tls["reality"].(map[string]interface{})
+	assertEqual(t, reality["public_key"], "PR8JkbArJstRJb8y584SqRkjpMqbyHoZupc2L5sT_Gs", "reality public_key")
+	assertEqual(t, reality["short_id"], "5f7aaec5", "reality short_id")
+
+	vmess := outbounds["vm-ws"]
+	if _, exists := vmess["tls"]; exists {
+		t.Fatal("vmess outbound has tls, want none")
+	}
+	transport := vmess["transport"].(map[string]interface{})
+	assertEqual(t, transport["type"], "ws", "vmess transport")
+	assertEqual(t, transport["path"], "/ws", "vmess path")
+
+	if _, exists := outbounds["proxy"]; !exists {
+		t.Fatal("singbox config missing proxy selector")
+	}
+}
+
+func TestGenerateSingboxReservesTags(t *testing.T) {
+	proxies := []models.Proxy{
+		{Type: "trojan", Name: "proxy", Server: "a.example.com", Port: 443, Password: "secret"},
+		{Type: "trojan", Name: "direct", Server: "b.example.com", Port: 443, Password: "secret", DialerProxy: "proxy"},
+		{Type: "trojan", Name: "auto", Server: "c.example.com", Port: 443, Password: "secret"},
+		{Type: "trojan", Name: "Others", Server: "d.example.com", Port: 443, Password: "secret"},
+	}
+	options := GenerateOptions{RegionGroups: services.RegionGroupOptions{Enabled: true, MinSize: 1, Locale: models.DisplayLocaleEn}}
+
+	generated, err := generateSubscriptionContent(proxies, "singbox", options)
+	if err != nil {
+		t.Fatalf("generateSubscriptionContent() error = %v", err)
+	}
+	var config struct {
+		Outbounds []map[string]interface{} `json:"outbounds"`
+	}
+	if err := json.Unmarshal([]byte(generated.Content), &config); err != nil {
+		t.Fatalf("json.Unmarshal(singbox) error = %v", err)
+	}
+
+	outbounds := make(map[string]map[string]interface{})
+	for _, outbound := range config.Outbounds {
+		tag := outbound["tag"].(string)
+		if _, exists := outbounds[tag]; exists {
+			t.Fatalf("duplicate outbound tag %q", tag)
+		}
+		outbounds[tag] = outbound
+	}
+	assertEqual(t, outbounds["proxy"]["type"], "selector", "proxy tag type")
+	assertEqual(t, outbounds["auto"]["type"], "urltest", "auto tag type")
+	assertEqual(t, outbounds["direct"]["type"], "direct", "direct tag type")
+	assertEqual(t, outbounds["proxy 2"]["server"], "a.example.com", "renamed proxy node")
+	assertEqual(t, outbounds["auto 2"]["server"], "c.example.com", "renamed auto node")
+	assertEqual(t, outbounds["direct 2"]["detour"], "proxy 2", "detour follows renamed node")
+	assertEqual(t, outbounds["Others"]["server"], "d.example.com", "node keeps its tag")
+	assertEqual(t, outbounds["Others 2"]["type"], "urltest", "region group avoids node tag")
+	assertEqual(t, len(proxies), 4, "input proxies")
+	assertEqual(t, proxies[0].Name, "proxy", "input proxy name untouched")
+}
+
+func TestGenerateSubscriptionContentSurge(t *testing.T) {
+	proxies := []models.Proxy{
+		{Type: "ss", Name: "ss-hk, 01", Server: "hk.example.com", Port: 8388, Method: "aes-128-gcm", Password: "secret", Plugin: "obfs-local", PluginOpts: "obfs=http;obfs-host=bing.com"},
+		{Type: "trojan", Name: "trojan-jp", Server: "jp.example.com", Port: 443, Password: "secret", SNI: "jp.example.com", AllowInsecure: true},
+		{Type: "vless", Name: "vless-us", Server: "us.example.com", Port: 443, UUID: "10e25f65-d4a3-4e5a-98eb-e459f1899e55"},
+	}
+
+	generated, err := generateSubscriptionContent(proxies, "surge", GenerateOptions{})
+	if err != nil {
+		t.Fatalf("generateSubscriptionContent() error = %v", err)
+	}
+
+	wantLines := []string{
+		"ss-hk  01 = ss, hk.example.com, 8388, encrypt-method=aes-128-gcm, password=secret, obfs=http, obfs-host=bing.com, udp-relay=true",
+		"trojan-jp = trojan, jp.example.com, 443, password=secret, sni=jp.example.com, skip-cert-verify=true",
+		"Proxy = select, Auto, ss-hk  01, trojan-jp",
+	}
+	for _, line := range wantLines {
+		if !strings.Contains(generated.Content, line+"\n") {
+			t.Errorf("surge content missing line %q\ncontent:\n%s", line, generated.Content)
+		}
+	}
+	assertEqual(t, len(generated.Skipped), 1, "skipped count")
+	assertEqual(t, generated.Skipped[0].Type, "vless", "skipped type")
+}
+
+func TestGenerateSurgeUniqueNamesAndCredentials(t *testing.T) {
+	proxies := []models.Proxy{
+		{Type: "trojan", Name: "a,b", Server: "a.example.com", Port: 443, Password: "secret"},
+		{Type: "trojan", Name: "a b", Server: "b.example.com", Port: 443, Password: "secret"},
+		{Type: "trojan", Name: "Auto", Server: "c.example.com", Port: 443, Password: "secret", DialerProxy: "a b"},
+		{Type: "http", Name: "http-pass-only", Server: "d.example.com", Port: 8080, Password: "secret"},
+		{Type: "socks", Name: "socks-auth", Server: "e.example.com", Port: 1080, Password: "secret", RawConfig: `{"username":"user"}`},
+	}
+
+	generated, err := generateSubscriptionContent(proxies, "surge", GenerateOptions{})
+	if err != nil {
+		t.Fatalf("generateSubscriptionContent() error = %v", err)
+	}
+
+	names := map[string]int{}
+	section := ""
+	for _, line := range strings.Split(generated.Content, "\n") {
+		if strings.HasPrefix(line, "[") {
+			section = line
+			continue
+		}
+		if section == "[Proxy]" && line != "" {
+			names[strings.SplitN(line, " = ", 2)[0]]++
+		}
+	}
+	for name, count := range names {
+		if count > 1 {
+			t.Errorf("surge proxy name %q used %d times\ncontent:\n%s", name, count, generated.Content)
+		}
+	}
+
+	wantLines := []string{
+		"a b = trojan, a.example.com, 443, password=secret",
+		"a b 2 = trojan, b.example.com, 443, password=secret",
+		"Auto = trojan, c.example.com, 443, password=secret, underlying-proxy=a b 2",
+		"http-pass-only = http, d.example.com, 8080",
+		"socks-auth = socks5, e.example.com, 1080, user, secret",
+		"Proxy = select, Auto 2, a b, a b 2, Auto, http-pass-only, socks-auth",
+		"[Rule]\nFINAL,Proxy",
+	}
+	for _, line := range wantLines {
+		if !strings.Contains(generated.Content, line+"\n") {
+			t.Errorf("surge content missing line %q\ncontent:\n%s", line, generated.Content)
+		}
+	}
+	if strings.Contains(generated.Content, "GEOIP,CN") {
+		t.Errorf("surge content has a hardcoded GEOIP rule\ncontent:\n%s", generated.Content)
+	}
+	// 生成时不修改调用方的节点名称
+	assertEqual(t, proxies[0].Name, "a,b", "caller proxy name")
+}
+
+func TestNormalizeProxyFieldsExtendedTypes(t *testing.T) {
+	const key = "YNXtAzepDqRv9H52osJVDQnznT5AL11eVLtoWHhGfXM="
+	tests := []struct {
+		name    string
+		proxy   models.Proxy
+		wantErr bool
+	}{
+		{"http without auth", models.Proxy{Type: "http", Name: "h", Server: "10.0.0.1", Port: 8080}, false},
+		{"socks username without password", models.Proxy{Type: "socks", Name: "s", Server: "10.0.0.1", Port: 1080, RawConfig: `{"username":"u"}`}, true},
+		{"ssr", models.Proxy{Type: "ssr", Name: "r", Server: "10.0.0.1", Port: 8388, Method: "aes-256-cfb", Password: "p"}, false},
+		{"ssr without password", models.Proxy{Type: "ssr", Name: "r", Server: "10.0.0.1", Port: 8388, Method: "aes-256-cfb"}, true},
+		{"hysteria", models.Proxy{Type: "hysteria", Name: "hy", Server: "10.0.0.1", Port: 443, RawConfig: `{"up":"50 Mbps","down":100}`}, false},
+		{"hysteria without bandwidth", models.Proxy{Type: "hysteria", Name: "hy", Server: "10.0.0.1", Port: 443}, true},
+		{"wireguard", models.Proxy{Type: "wireguard", Name: "wg", Server: "10.0.0.1", Port: 51820, RawConfig: `{"private_key":"` + key + `","public_key":"` + key + `","ip":"172.16.0.2/32"}`}, false},
+		{"wireguard with bad key", models.Proxy{Type: "wireguard", Name: "wg", Server: "10.0.0.1", Port: 51820, RawConfig: `{"private_key":"short","public_key":"` + key + `","ip":"172.16.0.2"}`}, true},
+		{"wireguard without address", models.Proxy{Type: "wireguard", Name: "wg", Server: "10.0.0.1", Port: 51820, RawConfig: `{"private_key":"` + key + `","public_key":"` + key + `"}`}, true},
+		{"unknown type", models.Proxy{Type: "juicity", Name: "j", Server: "10.0.0.1", Port: 443}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := tt.proxy
+			err := normalizeProxyFields(&proxy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeProxyFields() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	ssr := models.Proxy{Type: "ssr", Name: "r", Server: "10.0.0.1", Port: 8388, Method: "aes-256-cfb", Password: "p"}
+	if err := normalizeProxyFields(&ssr); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, ssr.RawConfig, `{"obfs":"plain","protocol":"origin"}`, "ssr default RawConfig")
+}
+
+func TestGenerateHysteriaAndWireGuardURLRoundTrip(t *testing.T) {
+	originals := []models.Proxy{
+		{
+			Type: "hysteria", Name: "hy 01", Server: "hy.example.com", Port: 443, Password: "auth-secret",
+			TLS: true, SNI: "hy.example.com", ALPN: "h3", AllowInsecure: true,
+			RawConfig: `{"up":"50","down":"100","protocol":"udp","obfs":"obfs-secret"}`,
+		},
+		{
+			Type: "wireguard", Name: "wg-01", Server: "162.159.192.1", Port: 2408,
+			RawConfig: `{"private_key":"YNXtAzepDqRv9H52osJVDQnznT5AL11eVLtoWHhGfXM=","public_key":"bmXOC+F1FxEMF9dyiK2H5/1SUtzH0JuVo51h2wPfgyo=","ip":"172.16.0.2/32","ipv6":"2606:4700:110:8a36::2/128","mtu":"1280","reserved":"1,2,3"}`,
+		},
+	}
+
+	for _, original := range originals {
+		t.Run(original.Type, func(t *testing.T) {
+			link := shareLinkGenerators[original.Type](original)
+			result, err := services.ParseSubscription(link, "")
+			if err != nil || len(result.Proxies) != 1 {
+				t.Fatalf("ParseSubscription(%q) = %d proxies, %v, errors %v", link, len(result.Proxies), err, result.Errors)
+			}
+			roundTripped := result.Proxies[0]
+			assertProxyFieldsEqual(t, roundTripped, original)
+			assertEqual(t, roundTripped.Password, original.Password, "Password")
+			assertEqual(t, roundTripped.AllowInsecure, original.AllowInsecure, "AllowInsecure")
+
+			wantConfig, gotConfig := proxyRawConfig(original), proxyRawConfig(roundTripped)
+			for key, want := range wantConfig {
+				assertEqual(t, gotConfig[key], want, "RawConfig."+key)
+			}
+			if err := normalizeProxyFields(&roundTripped); err != nil {
+				t.Fatalf("normalizeProxyFields(round-tripped) error = %v", err)
+			}
+		})
+	}
+}
+
+func TestGenerateHTTPSOCKSAndSSRRoundTrip(t *testing.T) {
+	originals := []models.Proxy{
+		{Type: "http", Name: "http office", Server: "10.0.0.1", Port: 8080, Password: "p@ss:word", RawConfig: `{"username":"alice"}`},
+		{Type: "http", Name: "https-hk", Server: "hk.example.com", Port: 443, TLS: true, SNI: "cdn.example.com", AllowInsecure: true},
+		{Type: "socks", Name: "socks-jp", Server: "jp.example.com", Port: 1080, Password: "secret", RawConfig: `{"username":"bob"}`},
+		{Type: "socks", Name: "socks-anon", Server: "10.0.0.2", Port: 1080},
+		{
+			Type: "ssr", Name: "ssr 香港", Server: "hk.example.com", Port: 8388, Method: "aes-256-cfb", Password: "secret",
+			RawConfig: `{"protocol":"auth_aes128_md5","protoparam":"1234:abcd","obfs":"tls1.2_ticket_auth","obfsparam":"cdn.example.com"}`,
+		},
+	}
+
+	for _, format := range []string{"base64", "clash"} {
+		generated, err := generateSubscriptionContent(originals, format, GenerateOptions{})
+		if err != nil {
+			t.Fatalf("generateSubscriptionContent(%s) error = %v", format, err)
+		}
+		assertEqual(t, len(generated.Skipped), 0, format+" skipped count")
+
+		result, err := services.ParseSubscription(generated.Content, "")
+		if err != nil {
+			t.Fatalf("ParseSubscription(%s) error = %v", format, err)
+		}
+		if len(result.Proxies) != len(originals) {
+			t.Fatalf("%s round trip = %d proxies, want %d\n%s", format, len(result.Proxies), len(originals), generated.Content)
+		}
+
+		for i, original := range originals {
+			roundTripped := result.Proxies[i]
+			field := format + " " + original.Name
+			assertEqual(t, roundTripped.Type, original.Type, field+" Type")
+			assertEqual(t, roundTripped.Name, original.Name, field+" Name")
+			assertEqual(t, roundTripped.Server, original.Server, field+" Server")
+			assertEqual(t, roundTripped.Port, original.Port, field+" Port")
+			assertEqual(t, roundTripped.Method, original.Method, field+" Method")
+			assertEqual(t, roundTripped.Password, original.Password, field+" Password")
+			assertEqual(t, roundTripped.TLS, original.TLS, field+" TLS")
+			assertEqual(t, roundTripped.AllowInsecure, original.AllowInsecure, field+" AllowInsecure")
+			if original.SNI != "" {
+				assertEqual(t, roundTripped.SNI, original.SNI, field+" SNI")
+			}
+
+			gotConfig := proxyRawConfig(roundTripped)
+			for key, want := range proxyRawConfig(original) {
+				assertEqual(t, gotConfig[key], want, field+" RawConfig."+key)
+			}
+		}
+	}
+}
+
 func decodeVmessURLForTest(t *testing.T, link string) models.Proxy {
 	t.Helper()
 	if !strings.HasPrefix(link, "vmess://") {
@@ -217,3 +533,291 @@ func assertEqual[T comparable](t *testing.T, got, want T, field string) {
 		t.Fatalf("%s = %v, want %v", field, got, want)
 	}
 }
+
+func TestGenerateSubscriptionContentRegionGroups(t *testing.T) {
+	proxies := []models.Proxy{
+		{Type: "trojan", Name: "香港 01", Server: "hk.example.com", Port: 443, Password: "secret"},
+		{Type: "trojan", Name: "日本 01", Server: "jp.example.com", Port: 443, Password: "secret"},
+		{Type: "trojan", Name: "Node 01", Server: "node.example.com", Port: 443, Password: "secret"},
+	}
+	options := GenerateOptions{RegionGroups: services.RegionGroupOptions{
+		Enabled: true,
+		Order:   []string{"JP"},
+		MinSize: 1,
+		Locale:  models.DisplayLocaleEn,
+	}}
+
+	generated, err := generateSubscriptionContent(proxies, "clash", options)
+	if err != nil {
+		t.Fatalf("generateSubscriptionContent(clash) error = %v", err)
+	}
+	wantGroups := "proxy-groups:\n" +
+		"  - name: 🇯🇵 Japan\n    type: url-test\n    url: http://www.gstatic.com/generate_204\n    interval: 300\n    proxies:\n      - 日本 01\n\n" +
+		"  - name: 🇭🇰 Hong Kong\n    type: url-test\n    url: http://www.gstatic.com/generate_204\n    interval: 300\n    proxies:\n      - 香港 01\n\n" +
+		"  - name: Others\n    type: url-test\n    url: http://www.gstatic.com/generate_204\n    interval: 300\n    proxies:\n      - Node 01\n\n"
+	if !strings.HasSuffix(generated.Content, wantGroups) {
+		t.Errorf("clash content missing region groups\ncontent:\n%s", generated.Content)
+	}
+
+	generated, err = generateSubscriptionContent(proxies, "singbox", options)
+	if err != nil {
+		t.Fatalf("generateSubscriptionContent(singbox) error = %v", err)
+	}
+	var config struct {
+		Outbounds []map[string]interface{} `json:"outbounds"`
+	}
+	if err := json.Unmarshal([]byte(generated.Content), &config); err != nil {
+		t.Fatalf("json.Unmarshal(singbox) error = %v", err)
+	}
+	selector := config.Outbounds[0]["outbounds"].([]interface{})
+	assertEqual(t, len(selector), 7, "selector outbounds")
+	assertEqual(t, selector[1], "🇯🇵 Japan", "region group in selector")
+	region := config.Outbounds[2]
+	assertEqual(t, region["type"], "urltest", "region group type")
+	assertEqual(t, region["tag"], "🇯🇵 Japan", "region group tag")
+
+	generated, err = generateSubscriptionContent(proxies, "clash", GenerateOptions{})
+	if err != nil {
+		t.Fatalf("generateSubscriptionContent(clash) error = %v", err)
+	}
+	if strings.Contains(generated.Content, "proxy-groups:") {
+		t.Error("clash content has proxy-groups while region groups disabled")
+	}
+}
+
+func TestGenerateClashQuotesNames(t *testing.T) {
+	names := []string{"[HK] a: b #1", "true", "2024-01-01 node", "- dash", "'quoted'", "plain 01"}
+	proxies := make([]models.Proxy, 0, len(names))
+	for _, name := range names {
+		proxies = append(proxies, models.Proxy{Type: "trojan", Name: name, Server: "hk.example.com", Port: 443, Password: "secret"})
+	}
+	proxies[len(proxies)-1].DialerProxy = names[0]
+	options := GenerateOptions{
+		RegionGroups:   services.RegionGroupOptions{Enabled: true, MinSize: 1, Locale: models.DisplayLocaleEn},
+		RuleSets:       []models.RuleSet{{Name: "ads", Policy: models.RulePolicyReject}},
+		RuleSetBaseURL: "https://sub.example.com/api/rules/token",
+	}
+
+	generated, err := generateSubscriptionContent(proxies, "clash", options)
+	if err != nil {
+		t.Fatalf("generateSubscriptionContent(clash) error = %v", err)
+	}
+	if !strings.Contains(generated.Content, "  - name: plain 01\n") {
+		t.Errorf("plain name should stay unquoted\ncontent:\n%s", generated.Content)
+	}
+
+	var config struct {
+		Proxies []struct {
+			Name        string `yaml:"name"`
+			DialerProxy string `yaml:"dialer-proxy"`
+		} `yaml:"proxies"`
+		ProxyGroups []struct {
+			Name    string   `yaml:"name"`
+			Proxies []string `yaml:"proxies"`
+		} `yaml:"proxy-groups"`
+	}
+	if err := yaml.Unmarshal([]byte(generated.Content), &config); err != nil {
+		t.Fatalf("yaml.Unmarshal(clash) error = %v\ncontent:\n%s", err, generated.Content)
+	}
+	assertEqual(t, len(config.Proxies), len(names), "proxy count")
+	for i, name := range names {
+		assertEqual(t, config.Proxies[i].Name, name, "proxy name")
+	}
+	assertEqual(t, config.Proxies[len(names)-1].DialerProxy, names[0], "dialer-proxy")
+	members := map[string]bool{}
+	for _, group := range config.ProxyGroups {
+		for _, member := range group.Proxies {
+			members[member] = true
+		}
+	}
+	for _, name := range names {
+		assertEqual(t, members[name], true, "group member "+name)
+	}
+	assertEqual(t, config.ProxyGroups[0].Name, "Proxy", "main group name")
+}
+
+// parseClashProxies 以YAML解析Clash输出中的节点
+func parseClashProxies(t *testing.T, content string) []map[string]interface{} {
+	t.Helper()
+	var config struct {
+		Proxies []map[string]interface{} `yaml:"proxies"`
+	}
+	if err := yaml.Unmarshal([]byte(content), &config); err != nil {
+		t.Fatalf("yaml.Unmarshal(clash) error = %v\ncontent:\n%s", err, content)
+	}
+	return config.Proxies
+}
+
+func TestGenerateClashQuotesCredentials(t *testing.T) {
+	proxies := []models.Proxy{
+		{Type: "ss", Name: "ss", Server: "ss.example.com", Port: 8388, Method: "aes-128-gcm", Password: "a: b #c"},
+		{Type: "ssr", Name: "ssr", Server: "ssr.example.com", Port: 443, Method: "aes-256-cfb", Password: "*secret",
+			RawConfig: `{"protocol":"auth_aes128_md5","protoparam":"1: x","obfs":"tls1.2_ticket_auth","obfsparam":"[cdn] #1"}`},
+		{Type: "http", Name: "http", Server: "http.example.com", Port: 8080, Password: "'quoted'", RawConfig: `{"username":"&user"}`},
+		{Type: "socks", Name: "socks", Server: "socks.example.com", Port: 1080, Password: "123456", RawConfig: `{"username":"yes"}`},
+	}
+	generated, err := generateSubscriptionContent(proxies, "clash", GenerateOptions{})
+	if err != nil {
+		t.Fatalf("generateSubscriptionContent(clash) error = %v", err)
+	}
+
+	parsed := parseClashProxies(t, generated.Content)
+	assertEqual(t, len(parsed), len(proxies), "proxy count")
+	assertEqual(t, parsed[0]["password"], "a: b #c", "ss password")
+	assertEqual(t, parsed[1]["password"], "*secret", "ssr password")
+	assertEqual(t, parsed[1]["protocol-param"], "1: x", "ssr protocol-param")
+	assertEqual(t, parsed[1]["obfs-param"], "[cdn] #1", "ssr obfs-param")
+	assertEqual(t, parsed[2]["username"], "&user", "http username")
+	assertEqual(t, parsed[2]["password"], "'quoted'", "http password")
+	assertEqual(t, parsed[3]["username"], "yes", "socks username")
+	assertEqual(t, parsed[3]["password"], "123456", "socks password")
+
+	// Base64密钥可能以"+"、"/"开头并以"="结尾，混淆密码可为任意字符串
+	proxies = []models.Proxy{
+		{Type: "hysteria", Name: "hysteria", Server: "hy.example.com", Port: 443, Password: "[auth]: x",
+			RawConfig: `{"up":"100","down":"100","protocol":"udp","obfs":"#obfs: y"}`},
+		{Type: "hysteria2", Name: "hysteria2", Server: "hy2.example.com", Port: 443, Password: "@pass",
+			RawConfig: `{"obfs":"salamander","obfs-password":"on"}`},
+		{Type: "wireguard", Name: "wireguard", Server: "wg.example.com", Port: 51820,
+			RawConfig: `{"private_key":"+KsTJ0Ybk/6Hq1c6gJU5cwe3dyAC/8PeRXrhzSyq3Vs=","public_key":"/jd+dRQFg8W8MSkmYkvEp5l9/LTpeS6Dk6GZdEVzRUA=","pre_shared_key":"*psk: z=","ip":"10.0.0.2/32","ipv6":"fd00::2/128"}`},
+	}
+	generated, err = generateSubscriptionContent(proxies, "clash", GenerateOptions{})
+	if err != nil {
+		t.Fatalf("generateSubscriptionContent(clash) error = %v", err)
+	}
+	parsed = parseClashProxies(t, generated.Content)
+	assertEqual(t, len(parsed), len(proxies), "udp proxy count")
+	assertEqual(t, parsed[0]["auth-str"], "[auth]: x", "hysteria auth-str")
+	assertEqual(t, parsed[0]["obfs"], "#obfs: y", "hysteria obfs")
+	assertEqual(t, parsed[1]["password"], "@pass", "hysteria2 password")
+	assertEqual(t, parsed[1]["obfs"], "salamander", "hysteria2 obfs")
+	assertEqual(t, parsed[1]["obfs-password"], "on", "hysteria2 obfs-password")
+	assertEqual(t, parsed[2]["private-key"], "+KsTJ0Ybk/6Hq1c6gJU5cwe3dyAC/8PeRXrhzSyq3Vs=", "wireguard private-key")
+	assertEqual(t, parsed[2]["public-key"], "/jd+dRQFg8W8MSkmYkvEp5l9/LTpeS6Dk6GZdEVzRUA=", "wireguard public-key")
+	assertEqual(t, parsed[2]["pre-shared-key"], "*psk: z=", "wireguard pre-shared-key")
+	assertEqual(t, parsed[2]["ipv6"], "fd00::2", "wireguard ipv6")
+}
+
+func TestGenerateSubscriptionContentRuleSets(t *testing.T) {
+	proxies := []models.Proxy{
+		{Type: "trojan", Name: "Proxy", Server: "hk.example.com", Port: 443, Password: "secret"},
+	}
+	options := GenerateOptions{
+		RuleSets: []models.RuleSet{
+			{Name: "ads", Policy: models.RulePolicyReject, Rules: []models.RuleEntry{{Type: models.RuleDomainSuffix, Value: "ads.example.com"}}},
+			{Name: "cn", Policy: models.RulePolicyDirect, Rules: []models.RuleEntry{{Type: models.RuleGeoIP, Value: "CN"}}},
+			{Name: "media", Policy: models.RulePolicyProxy, Rules: []models.RuleEntry{{Type: models.RuleDomain, Value: "media.example.com"}}},
+		},
+		RuleSetBaseURL: "https://sub.example.com/api/rules/token",
+	}
+
+	generated, err := generateSubscriptionContent(proxies, "clash", options)
+	if err != nil {
+		t.Fatalf("generateSubscriptionContent(clash) error = %v", err)
+	}
+	wantParts := []string{
+		"proxy-groups:\n  - name: Proxy 2\n    type: select\n    proxies:\n      - Proxy\n",
+		"  ads:\n    type: http\n    behavior: classical\n    url: \"https://sub.example.com/api/rules/token/ads?format=clash\"\n",
+		"rules:\n  - RULE-SET,ads,REJECT\n  - RULE-SET,cn,DIRECT\n  - RULE-SET,media,Proxy 2\n  - MATCH,Proxy 2\n",
+	}
+	for _, part := range wantParts {
+		if !strings.Contains(generated.Content, part) {
+			t.Errorf("clash content missing %q\ncontent:\n%s", part, generated.Content)
+		}
+	}
+
+	generated, err = generateSubscriptionContent(proxies, "singbox", options)
+	if err != nil {
+		t.Fatalf("generateSubscriptionContent(singbox) error = %v", err)
+	}
+	var config struct {
+		Route struct {
+			RuleSet []map[string]interface{} `json:"rule_set"`
+			Rules   []map[string]interface{} `json:"rules"`
+			Final   string                   `json:"final"`
+		} `json:"route"`
+	}
+	if err := json.Unmarshal([]byte(generated.Content), &config); err != nil {
+		t.Fatalf("json.Unmarshal(singbox) error = %v", err)
+	}
+	assertEqual(t, len(config.Route.RuleSet), 3, "singbox rule sets")
+	assertEqual(t, config.Route.RuleSet[0]["url"], "https://sub.example.com/api/rules/token/ads?format=singbox", "hosted rule set url")
+	assertEqual(t, config.Route.RuleSet[1]["tag"], "geoip-cn", "geoip rule set tag")
+	assertEqual(t, len(config.Route.Rules), 3, "singbox route rules")
+	assertEqual(t, config.Route.Rules[0]["action"], "reject", "reject action")
+	assertEqual(t, config.Route.Rules[1]["outbound"], "direct", "direct outbound")
+	assertEqual(t, config.Route.Rules[2]["outbound"], "proxy", "proxy outbound")
+	assertEqual(t, config.Route.Final, "proxy", "final outbound")
+
+	generated, err = generateSubscriptionContent(proxies, "surge", options)
+	if err != nil {
+		t.Fatalf("generateSubscriptionContent(surge) error = %v", err)
+	}
+	wantRules := "[Rule]\n" +
+		"RULE-SET,https://sub.example.com/api/rules/token/ads?format=surge,REJECT\n" +
+		"RULE-SET,https://sub.example.com/api/rules/token/cn?format=surge,DIRECT\n" +
+		"RULE-SET,https://sub.example.com/api/rules/token/media?format=surge,Proxy 2\n" +
+		"FINAL,Proxy 2\n"
+	if !strings.HasSuffix(generated.Content, wantRules) {
+		t.Errorf("surge content missing rules %q\ncontent:\n%s", wantRules, generated.Content)
+	}
+}
+
+func TestGenerateSubscriptionContentProxyChains(t *testing.T) {
+	proxies := []models.Proxy{
+		{Type: "trojan", Name: "Front", Server: "front.example.com", Port: 443, Password: "secret"},
+		{Type: "trojan", Name: "Exit", Server: "exit.example.com", Port: 443, Password: "secret", DialerProxy: "Front"},
+		{Type: "ssr", Name: "SSR Front", Server: "ssr.example.com", Port: 443},
+		{Type: "trojan", Name: "Behind SSR", Server: "hidden.example.com", Port: 443, Password: "secret", DialerProxy: "SSR Front"},
+	}
+
+	generated, err := generateSubscriptionContent(proxies, "clash", GenerateOptions{})
+	if err != nil {
+		t.Fatalf("generateSubscriptionContent(clash) error = %v", err)
+	}
+	if !strings.Contains(generated.Content, "  - name: Exit\n    type: trojan\n    server: exit.example.com\n    port: 443\n    password: secret\n") ||
+		!strings.Contains(generated.Content, "    dialer-proxy: Front\n") {
+		t.Errorf("clash content missing dialer-proxy\ncontent:\n%s", generated.Content)
+	}
+	assertEqual(t, len(generated.SkippedChains), 0, "clash skipped chains")
+
+	generated, err = generateSubscriptionContent(proxies, "singbox", GenerateOptions{})
+	if err != nil {
+		t.Fatalf("generateSubscriptionContent(singbox) error = %v", err)
+	}
+	var config struct {
+		Outbounds []map[string]interface{} `json:"outbounds"`
+	}
+	if err := json.Unmarshal([]byte(generated.Content), &config); err != nil {
+		t.Fatalf("json.Unmarshal(singbox) error = %v", err)
+	}
+	detours := map[string]interface{}{}
+	for _, outbound := range config.Outbounds {
+		if detour, ok := outbound["detour"]; ok {
+			detours[outbound["tag"].(string)] = detour
+		}
+	}
+	assertEqual(t, len(detours), 1, "singbox detour count")
+	assertEqual(t, detours["Exit"], "Front", "singbox detour")
+	assertEqual(t, len(generated.Skipped), 1, "singbox skipped types")
+	assertEqual(t, len(generated.SkippedChains), 1, "front skipped with its type")
+	assertEqual(t, generated.SkippedChains[0].Name, "Behind SSR", "chain behind skipped type")
+
+	generated, err = generateSubscriptionContent(proxies, "base64", GenerateOptions{})
+	if err != nil {
+		t.Fatalf("generateSubscriptionContent(base64) error = %v", err)
+	}
+	assertEqual(t, len(generated.SkippedChains), 2, "base64 cannot express chains")
+	decoded, _ := base64.StdEncoding.DecodeString(generated.Content)
+	if strings.Contains(string(decoded), "exit.example.com") {
+		t.Errorf("base64 content contains chained node:\n%s", decoded)
+	}
+}
+
+func TestDefaultUAFormatRulesUseRegisteredFormats(t *testing.T) {
+	for _, rule := range services.DefaultUAFormatRules {
+		if !isSupportedFormat(rule.Format) {
+			t.Fatalf("default rule %q maps to unregistered format %q", rule.Keyword, rule.Format)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"proxy-subscription/models"
+	"proxy-subscription/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRuleSets 获取所有规则集
+func GetRuleSets(c *gin.Context) {
+	ruleSets := make([]models.RuleSet, 0)
+	if err := models.DB.Order("priority DESC, id").Find(&ruleSets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, ruleSets)
+}
+
+// GetRuleSet 获取单个规则集
+func GetRuleSet(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	var ruleSet models.RuleSet
+	if err := models.DB.First(&ruleSet, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "规则集不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, ruleSet)
+}
+
+// AddRuleSet 创建规则集
+func AddRuleSet(c *gin.Context) {
+	var ruleSet models.RuleSet
+	if err := c.ShouldBindJSON(&ruleSet); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.NormalizeRuleSet(&ruleSet); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if ruleSetNameExists(ruleSet.Name, 0) {
+		c.JSON(http.StatusConflict, gin.H{"error": "规则集名称已存在: " + ruleSet.Name})
+		return
+	}
+
+	if err := models.DB.Create(&ruleSet).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	services.InvalidateCache()
+	c.JSON(http.StatusCreated, ruleSet)
+}
+
+// UpdateRuleSet 更新规则集
+func UpdateRuleSet(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	var existing models.RuleSet
+	if err := models.DB.First(&existing, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "规则集不存在"})
+		return
+	}
+
+	var ruleSet models.RuleSet
+	if err := c.ShouldBindJSON(&ruleSet); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.NormalizeRuleSet(&ruleSet); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if ruleSetNameExists(ruleSet.Name, existing.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "规则集名称已存在: " + ruleSet.Name})
+		return
+	}
+
+	ruleSet.ID = existing.ID
+	ruleSet.CreatedAt = existing.CreatedAt
+	if err := models.DB.Save(&ruleSet).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	services.InvalidateCache()
+	c.JSON(http.StatusOK, ruleSet)
+}
+
+// DeleteRuleSet 删除规则集
+func DeleteRuleSet(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	if err := models.DB.Delete(&models.RuleSet{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	services.InvalidateCache()
+	c.JSON(http.StatusOK, gin.H{"message": "规则集已删除"})
+}
+
+// ruleSetNameExists 检查名称是否已被其他规则集使用
+func ruleSetNameExists(name string, excludeID uint) bool {
+	var count int64
+	models.DB.Model(&models.RuleSet{}).Where("name = ? AND id <> ?", name, excludeID).Count(&count)
+	return count > 0
+}
+
+// GetHostedRuleSet 通过合并订阅的访问令牌获取托管的规则集
+func GetHostedRuleSet(c *gin.Context) {
+	serveRuleSet(c)
+}
+
+// GetProfileRuleSet 通过输出配置的令牌获取托管的规则集
+func GetProfileRuleSet(c *gin.Context) {
+	var profile models.Profile
+	if err := models.DB.Where("token = ?", c.Param("token")).First(&profile).Error; err != nil || !profile.Enabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "订阅不存在"})
+		return
+	}
+	serveRuleSet(c)
+}
+
+// serveRuleSet 按format查询参数输出规则集：clash（默认）为rule-provider，singbox为源格式规则集，surge为规则列表
+func serveRuleSet(c *gin.Context) {
+	var ruleSet models.RuleSet
+	if err := models.DB.Where("name = ? AND enabled = ?", c.Param("name"), true).First(&ruleSet).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "规则集不存在"})
+		return
+	}
+
+	switch c.DefaultQuery("format", "clash") {
+	case "clash":
+		c.Header("Content-Type", "text/yaml;charset=utf-8")
+		c.String(http.StatusOK, services.GenerateClashRuleProvider(ruleSet.Rules))
+	case "surge":
+		c.Header("Content-Type", "text/plain;charset=utf-8")
+		c.String(http.StatusOK, services.GenerateSurgeRuleSet(ruleSet.Rules))
+	case "singbox":
+		content, err := services.GenerateSingboxRuleSet(ruleSet.Rules)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Content-Type", "application/json;charset=utf-8")
+		c.String(http.StatusOK, content)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "规则集只支持clash、singbox和surge格式"})
+	}
+}
+
+// externalURL 生成的配置中引用本服务的地址，由 ConfigureExternalURL 设置
+var externalURL string
+
+// ConfigureExternalURL 设置对外访问本服务的地址（如 https://sub.example.com），为空时按请求地址生成
+func ConfigureExternalURL(raw string) error {
+	raw = strings.TrimRight(strings.TrimSpace(raw), "/")
+	if raw != "" {
+		parsed, err := url.Parse(raw)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || parsed.RawQuery != "" || parsed.Fragment != "" {
+			return errors.New("无效的外部访问地址: " + raw)
+		}
+	}
+	externalURL = raw
+	return nil
+}
+
+// requestBaseURL 返回客户端访问本服务使用的地址，优先使用配置的外部访问地址；
+// 只有来自信任的反向代理的请求才采用X-Forwarded-Proto和X-Forwarded-Host
+func requestBaseURL(c *gin.Context) string {
+	if externalURL != "" {
+		return externalURL
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	host := c.Request.Host
+	if isTrustedProxy(c) {
+		if proto := strings.TrimSpace(strings.Split(c.GetHeader("X-Forwarded-Proto"), ",")[0]); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwardedHost := c.GetHeader("X-Forwarded-Host"); forwardedHost != "" {
+			host = strings.TrimSpace(strings.Split(forwardedHost, ",")[0])
+		}
+	}
+	return scheme + "://" + host
+}
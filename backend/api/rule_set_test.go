@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"proxy-subscription/models"
+	"proxy-subscription/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestBaseURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	baseURL := func(remoteAddr string, headers map[string]string) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "http://sub.example.com/api/merged/token", nil)
+		c.Request.RemoteAddr = remoteAddr
+		for key, value := range headers {
+			c.Request.Header.Set(key, value)
+		}
+		return requestBaseURL(c)
+	}
+	forwarded := map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.example.com"}
+	t.Cleanup(func() {
+		ConfigureTrustedProxies(gin.New(), "")
+		ConfigureExternalURL("")
+	})
+
+	if err := ConfigureTrustedProxies(gin.New(), ""); err != nil {
+		t.Fatalf("ConfigureTrustedProxies() error = %v", err)
+	}
+	assertEqual(t, baseURL("203.0.113.7:40000", forwarded), "http://sub.example.com", "untrusted forwarded headers")
+
+	if err := ConfigureTrustedProxies(gin.New(), "10.0.0.0/8"); err != nil {
+		t.Fatalf("ConfigureTrustedProxies() error = %v", err)
+	}
+	assertEqual(t, baseURL("10.0.0.2:40000", map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "public.example.com"}), "https://public.example.com", "trusted forwarded headers")
+	assertEqual(t, baseURL("203.0.113.7:40000", forwarded), "http://sub.example.com", "forwarded headers from other peers")
+
+	if err := ConfigureExternalURL("https://sub.example.com/"); err != nil {
+		t.Fatalf("ConfigureExternalURL() error = %v", err)
+	}
+	assertEqual(t, baseURL("10.0.0.2:40000", forwarded), "https://sub.example.com", "external url")
+
+	for _, invalid := range []string{"sub.example.com", "ftp://sub.example.com", "https://", "https://sub.example.com/?a=1"} {
+		assertEqual(t, ConfigureExternalURL(invalid) != nil, true, "invalid external url "+invalid)
+	}
+}
+
+func TestServeSubscriptionCacheIgnoresHost(t *testing.T) {
+	setupTestDB(t)
+	services.InvalidateCache()
+	gin.SetMode(gin.TestMode)
+	if err := models.DB.Create(&models.RuleSet{Name: "ads", Enabled: true, Policy: models.RulePolicyReject}).Error; err != nil {
+		t.Fatalf("create rule set error = %v", err)
+	}
+	proxies := []models.Proxy{{Type: "trojan", Name: "node", Server: "node.example.com", Port: 443, Password: "secret"}}
+
+	serve := func(host string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "http://"+host+"/api/merged/token", nil)
+		options := outputOptions{DeadNodes: deadNodeOptions{Policy: models.DeadNodeKeep}, BaseURL: requestBaseURL(c), RuleSetPath: "/api/rules/token"}
+		serveSubscription(c, "merged", "clash", options, func() ([]models.Proxy, error) {
+			result := make([]models.Proxy, len(proxies))
+			copy(result, proxies)
+			return result, nil
+		})
+		return w
+	}
+
+	w := serve("a.example.com")
+	assertEqual(t, w.Header().Get("X-Cache"), "MISS", "first request")
+	assertEqual(t, strings.Contains(w.Body.String(), "http://a.example.com/api/rules/token/ads"), true, "rule set url uses request host")
+	assertEqual(t, serve("a.example.com").Header().Get("X-Cache"), "HIT", "same host")
+
+	// 其他Host重新生成并覆盖同一缓存条目，不会新增条目
+	w = serve("b.example.com")
+	assertEqual(t, w.Header().Get("X-Cache"), "MISS", "other host")
+	assertEqual(t, strings.Contains(w.Body.String(), "http://b.example.com/api/rules/token/ads"), true, "rule set url uses other host")
+	assertEqual(t, serve("a.example.com").Header().Get("X-Cache"), "MISS", "entry replaced by other host")
+}
@@ -1,20 +1,53 @@
 package api
 
 import (
+	"encoding/json"
 	"net/http"
 	"strconv"
 
 	"proxy-subscription/models"
+	"proxy-subscription/services"
+	"proxy-subscription/utils"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 // SettingRequest 设置请求结构
+// 指针字段为可选设置，请求中未提供时保持原值不变
 type SettingRequest struct {
-	AutoRefresh     bool   `json:"autoRefresh"`
-	RefreshInterval int    `json:"refreshInterval"`
-	DefaultFormat   string `json:"defaultFormat"`
+	AutoRefresh     bool    `json:"autoRefresh"`
+	RefreshInterval int     `json:"refreshInterval"`
+	DefaultFormat   string  `json:"defaultFormat"`
+	DedupEnabled    *bool   `json:"dedupEnabled,omitempty"`
+	DedupStrategy   *string `json:"dedupStrategy,omitempty"`
+
+	UAFormatRules  *[]services.UAFormatRule `json:"uaFormatRules,omitempty"`
+	CountryAliases *[]models.CountryAlias   `json:"countryAliases,omitempty"` // 国家别名覆盖项，在默认别名表基础上增加、修改或禁用别名
+	DisplayLocale  *string                  `json:"displayLocale,omitempty"`  // 显示名称语言：zh、en、code
+	ExportFlag     *bool                    `json:"exportFlag,omitempty"`     // 输出订阅时在节点名称前添加国旗emoji
+
+	AccessLogRetentionDays *int `json:"accessLogRetentionDays,omitempty"`
+	AccessLogMaxRows       *int `json:"accessLogMaxRows,omitempty"`
+
+	HealthCheckEnabled     *bool `json:"healthCheckEnabled,omitempty"`
+	HealthCheckInterval    *int  `json:"healthCheckInterval,omitempty"`    // 分钟
+	HealthCheckConcurrency *int  `json:"healthCheckConcurrency,omitempty"` // 同时探测的地址数
+	HealthCheckTimeout     *int  `json:"healthCheckTimeout,omitempty"`     // 秒
+	HealthCheckTLS         *bool `json:"healthCheckTLS,omitempty"`         // 对TLS节点完成握手
+
+	ProtocolTestEnabled *bool   `json:"protocolTestEnabled,omitempty"` // 健康检查时对支持的节点进行协议测试
+	ProtocolTestURL     *string `json:"protocolTestURL,omitempty"`
+
+	DeadNodePolicy    *string `json:"deadNodePolicy,omitempty"`    // 合并订阅及未单独设置的输出配置对失效节点的处理方式
+	DeadNodeThreshold *int    `json:"deadNodeThreshold,omitempty"` // 连续探测失败多少次视为失效
+
+	OutputSort *string `json:"outputSort,omitempty"` // 合并订阅及未单独设置的输出配置的排序策略
+
+	RegionGroups       *bool     `json:"regionGroups,omitempty"`       // 在Clash和sing-box输出中按地区生成节点分组
+	RegionGroupType    *string   `json:"regionGroupType,omitempty"`    // url-test或select
+	RegionGroupOrder   *[]string `json:"regionGroupOrder,omitempty"`   // 优先排列的国家代码
+	RegionGroupMinSize *int      `json:"regionGroupMinSize,omitempty"` // 节点数少于该值的地区并入"其他"分组
 }
 
 // GetSettings 获取所有设置
@@ -27,10 +60,51 @@ func GetSettings(c *gin.Context) {
 	}
 
 	// 转换为前端友好的格式
+	dedupEnabled := true
+	dedupStrategy := services.DedupStrategyCustomFirst
+	accessLogRetentionDays := services.DefaultAccessLogRetentionDays
+	accessLogMaxRows := services.DefaultAccessLogMaxRows
+	uaFormatRules := services.LoadUAFormatRules()
+	countryAliases := models.LoadCountryAliases()
+	displayLocale := models.CurrentDisplayLocale()
+	exportFlag := false
+	regionGroups := services.LoadRegionGroupOptions()
+	healthCheckEnabled := true
+	healthCheckInterval := services.DefaultHealthCheckInterval
+	healthCheckConcurrency := services.DefaultHealthCheckConcurrency
+	healthCheckTimeout := services.DefaultHealthCheckTimeout
+	healthCheckTLS := true
+	protocolTestEnabled := false
+	protocolTestURL := services.DefaultProtocolTestURL
+	deadNodePolicy := models.DeadNodeKeep
+	deadNodeThreshold := services.DefaultDeadNodeThreshold
+	outputSort := services.SortStrategyNone
 	response := SettingRequest{
-		AutoRefresh:     false,
-		RefreshInterval: 6,
-		DefaultFormat:   "base64",
+		AutoRefresh:            false,
+		RefreshInterval:        6,
+		DefaultFormat:          "base64",
+		DedupEnabled:           &dedupEnabled,
+		DedupStrategy:          &dedupStrategy,
+		UAFormatRules:          &uaFormatRules,
+		CountryAliases:         &countryAliases,
+		DisplayLocale:          &displayLocale,
+		ExportFlag:             &exportFlag,
+		RegionGroups:           &regionGroups.Enabled,
+		RegionGroupType:        &regionGroups.Type,
+		RegionGroupOrder:       &regionGroups.Order,
+		RegionGroupMinSize:     &regionGroups.MinSize,
+		AccessLogRetentionDays: &accessLogRetentionDays,
+		AccessLogMaxRows:       &accessLogMaxRows,
+		HealthCheckEnabled:     &healthCheckEnabled,
+		HealthCheckInterval:    &healthCheckInterval,
+		HealthCheckConcurrency: &healthCheckConcurrency,
+		HealthCheckTimeout:     &healthCheckTimeout,
+		HealthCheckTLS:         &healthCheckTLS,
+		ProtocolTestEnabled:    &protocolTestEnabled,
+		ProtocolTestURL:        &protocolTestURL,
+		DeadNodePolicy:         &deadNodePolicy,
+		DeadNodeThreshold:      &deadNodeThreshold,
+		OutputSort:             &outputSort,
 	}
 
 	// 填充实际值
@@ -44,9 +118,59 @@ func GetSettings(c *gin.Context) {
 				response.RefreshInterval = interval
 			}
 		case models.SettingDefaultFormat:
-			if setting.Value != "" {
+			if isSupportedFormat(setting.Value) {
 				response.DefaultFormat = setting.Value
 			}
+		case models.SettingDedupEnabled:
+			dedupEnabled = setting.Value != "false"
+		case models.SettingDedupStrategy:
+			if setting.Value != "" {
+				dedupStrategy = setting.Value
+			}
+		case models.SettingAccessLogRetentionDays:
+			if days, err := strconv.Atoi(setting.Value); err == nil && days >= 0 {
+				accessLogRetentionDays = days
+			}
+		case models.SettingAccessLogMaxRows:
+			if rows, err := strconv.Atoi(setting.Value); err == nil && rows >= 0 {
+				accessLogMaxRows = rows
+			}
+		case models.SettingHealthCheckEnabled:
+			healthCheckEnabled = setting.Value != "false"
+		case models.SettingHealthCheckInterval:
+			if interval, err := strconv.Atoi(setting.Value); err == nil && interval > 0 {
+				healthCheckInterval = interval
+			}
+		case models.SettingHealthCheckConcurrency:
+			if concurrency, err := strconv.Atoi(setting.Value); err == nil && concurrency > 0 {
+				healthCheckConcurrency = concurrency
+			}
+		case models.SettingHealthCheckTimeout:
+			if timeout, err := strconv.Atoi(setting.Value); err == nil && timeout > 0 {
+				healthCheckTimeout = timeout
+			}
+		case models.SettingHealthCheckTLS:
+			healthCheckTLS = setting.Value != "false"
+		case models.SettingProtocolTestEnabled:
+			protocolTestEnabled = setting.Value == "true"
+		case models.SettingProtocolTestURL:
+			if setting.Value != "" {
+				protocolTestURL = setting.Value
+			}
+		case models.SettingDeadNodePolicy:
+			if models.IsValidDeadNodePolicy(setting.Value) {
+				deadNodePolicy = setting.Value
+			}
+		case models.SettingDeadNodeThreshold:
+			if threshold, err := strconv.Atoi(setting.Value); err == nil && threshold > 0 {
+				deadNodeThreshold = threshold
+			}
+		case models.SettingOutputSort:
+			if services.IsValidSortStrategy(setting.Value) {
+				outputSort = setting.Value
+			}
+		case models.SettingExportFlag:
+			exportFlag = setting.Value == "true"
 		}
 	}
 
@@ -61,36 +185,188 @@ func SaveSettings(c *gin.Context) {
 		return
 	}
 
-	// 开始事务
-	tx := models.DB.Begin()
-
-	// 保存自动刷新设置
-	if err := saveOrUpdateSetting(tx, models.SettingAutoRefresh, strconv.FormatBool(request.AutoRefresh)); err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if request.DefaultFormat == "" {
+		request.DefaultFormat = "base64"
+	}
+	if !isSupportedFormat(request.DefaultFormat) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的默认格式: " + request.DefaultFormat})
 		return
 	}
-
-	// 保存刷新间隔设置
-	if err := saveOrUpdateSetting(tx, models.SettingRefreshInterval, strconv.Itoa(request.RefreshInterval)); err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if request.DedupStrategy != nil && !services.IsValidDedupStrategy(*request.DedupStrategy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的去重策略: " + *request.DedupStrategy})
 		return
 	}
-
-	// 保存默认格式设置
-	if err := saveOrUpdateSetting(tx, models.SettingDefaultFormat, request.DefaultFormat); err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var uaFormatRules []byte
+	if request.UAFormatRules != nil {
+		// 与输出时识别客户端使用同一份格式注册表，避免保存永远不会生效的规则
+		if err := services.ValidateUAFormatRules(*request.UAFormatRules, isSupportedFormat); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		uaFormatRules, _ = json.Marshal(*request.UAFormatRules)
+	}
+	var countryAliases []byte
+	if request.CountryAliases != nil {
+		if err := models.ValidateCountryAliases(*request.CountryAliases); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		countryAliases, _ = json.Marshal(*request.CountryAliases)
+	}
+	if request.DisplayLocale != nil && !models.IsValidDisplayLocale(*request.DisplayLocale) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的显示名称语言: " + *request.DisplayLocale})
+		return
+	}
+	if request.RegionGroupType != nil && !services.IsValidRegionGroupType(*request.RegionGroupType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的地区分组类型: " + *request.RegionGroupType})
+		return
+	}
+	if request.RegionGroupMinSize != nil && *request.RegionGroupMinSize < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "地区分组最少节点数至少为1"})
+		return
+	}
+	var regionGroupOrder []byte
+	if request.RegionGroupOrder != nil {
+		codes, err := normalizeCountryCodes(*request.RegionGroupOrder)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		regionGroupOrder, _ = json.Marshal(codes)
+	}
+	if (request.AccessLogRetentionDays != nil && *request.AccessLogRetentionDays < 0) ||
+		(request.AccessLogMaxRows != nil && *request.AccessLogMaxRows < 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "访问日志保留设置不能为负数"})
+		return
+	}
+	if (request.HealthCheckInterval != nil && *request.HealthCheckInterval < 1) ||
+		(request.HealthCheckConcurrency != nil && (*request.HealthCheckConcurrency < 1 || *request.HealthCheckConcurrency > 256)) ||
+		(request.HealthCheckTimeout != nil && (*request.HealthCheckTimeout < 1 || *request.HealthCheckTimeout > 60)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "健康检查设置无效：间隔至少1分钟，并发数为1-256，超时为1-60秒"})
+		return
+	}
+	if request.ProtocolTestURL != nil {
+		if err := validateProtocolTestURL(*request.ProtocolTestURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if request.DeadNodePolicy != nil && !models.IsValidDeadNodePolicy(*request.DeadNodePolicy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的失效节点处理方式: " + *request.DeadNodePolicy})
+		return
+	}
+	if request.DeadNodeThreshold != nil && *request.DeadNodeThreshold < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "失效判定次数至少为1"})
+		return
+	}
+	if request.OutputSort != nil && !services.IsValidSortStrategy(*request.OutputSort) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的排序策略: " + *request.OutputSort})
 		return
 	}
 
+	// 收集要保存的设置，未提供的可选设置保持原值不变
+	settings := map[string]string{
+		models.SettingAutoRefresh:     strconv.FormatBool(request.AutoRefresh),
+		models.SettingRefreshInterval: strconv.Itoa(request.RefreshInterval),
+		models.SettingDefaultFormat:   request.DefaultFormat,
+	}
+	if request.DedupEnabled != nil {
+		settings[models.SettingDedupEnabled] = strconv.FormatBool(*request.DedupEnabled)
+	}
+	if request.DedupStrategy != nil {
+		settings[models.SettingDedupStrategy] = *request.DedupStrategy
+	}
+	if request.UAFormatRules != nil {
+		settings[models.SettingUAFormatRules] = string(uaFormatRules)
+	}
+	if request.CountryAliases != nil {
+		settings[models.SettingCountryAliases] = string(countryAliases)
+	}
+	// 访问日志保留设置，0表示不按该条件清理
+	if request.AccessLogRetentionDays != nil {
+		settings[models.SettingAccessLogRetentionDays] = strconv.Itoa(*request.AccessLogRetentionDays)
+	}
+	if request.AccessLogMaxRows != nil {
+		settings[models.SettingAccessLogMaxRows] = strconv.Itoa(*request.AccessLogMaxRows)
+	}
+	if request.HealthCheckEnabled != nil {
+		settings[models.SettingHealthCheckEnabled] = strconv.FormatBool(*request.HealthCheckEnabled)
+	}
+	if request.HealthCheckInterval != nil {
+		settings[models.SettingHealthCheckInterval] = strconv.Itoa(*request.HealthCheckInterval)
+	}
+	if request.HealthCheckConcurrency != nil {
+		settings[models.SettingHealthCheckConcurrency] = strconv.Itoa(*request.HealthCheckConcurrency)
+	}
+	if request.HealthCheckTimeout != nil {
+		settings[models.SettingHealthCheckTimeout] = strconv.Itoa(*request.HealthCheckTimeout)
+	}
+	if request.HealthCheckTLS != nil {
+		settings[models.SettingHealthCheckTLS] = strconv.FormatBool(*request.HealthCheckTLS)
+	}
+	if request.ProtocolTestEnabled != nil {
+		settings[models.SettingProtocolTestEnabled] = strconv.FormatBool(*request.ProtocolTestEnabled)
+	}
+	if request.ProtocolTestURL != nil {
+		settings[models.SettingProtocolTestURL] = *request.ProtocolTestURL
+	}
+	if request.DeadNodePolicy != nil {
+		settings[models.SettingDeadNodePolicy] = *request.DeadNodePolicy
+	}
+	if request.DeadNodeThreshold != nil {
+		settings[models.SettingDeadNodeThreshold] = strconv.Itoa(*request.DeadNodeThreshold)
+	}
+	if request.OutputSort != nil {
+		settings[models.SettingOutputSort] = *request.OutputSort
+	}
+	if request.DisplayLocale != nil {
+		settings[models.SettingDisplayLocale] = *request.DisplayLocale
+	}
+	if request.ExportFlag != nil {
+		settings[models.SettingExportFlag] = strconv.FormatBool(*request.ExportFlag)
+	}
+	if request.RegionGroups != nil {
+		settings[models.SettingRegionGroups] = strconv.FormatBool(*request.RegionGroups)
+	}
+	if request.RegionGroupType != nil {
+		settings[models.SettingRegionGroupType] = *request.RegionGroupType
+	}
+	if request.RegionGroupOrder != nil {
+		settings[models.SettingRegionGroupOrder] = string(regionGroupOrder)
+	}
+	if request.RegionGroupMinSize != nil {
+		settings[models.SettingRegionGroupMinSize] = strconv.Itoa(*request.RegionGroupMinSize)
+	}
+
+	// 在一个事务中保存全部设置
+	tx := models.DB.Begin()
+	for key, value := range settings {
+		if err := saveOrUpdateSetting(tx, key, value); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	// 输出相关设置变更后清除缓存
+	if request.CountryAliases != nil {
+		models.ReloadCountryMatcher()
+		// 名称推测的国家随别名变化，已保存的位置不一致标记需要重新判断
+		if err := services.RefreshGeoMismatch(); err != nil {
+			utils.Warn("更新节点位置不一致标记失败: %v", err)
+		}
+	}
+	if request.DisplayLocale != nil {
+		models.ReloadDisplayLocale()
+	}
+	services.InvalidateCache()
+
 	c.JSON(http.StatusOK, gin.H{"message": "设置保存成功"})
 }
 
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"proxy-subscription/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func saveTestSettings(t *testing.T, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/settings", bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	SaveSettings(c)
+	return w
+}
+
+func TestSaveSettingsValidatesUAFormatRules(t *testing.T) {
+	setupTestDB(t)
+
+	tests := []struct {
+		name     string
+		rules    string
+		wantCode int
+	}{
+		{"registered format", `[{"keyword":"mihomo","format":"clash"}]`, http.StatusOK},
+		{"unknown format", `[{"keyword":"mihomo","format":"clahs"}]`, http.StatusBadRequest},
+		{"empty keyword", `[{"keyword":"","format":"clash"}]`, http.StatusBadRequest},
+		{"empty format", `[{"keyword":"mihomo","format":""}]`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := saveTestSettings(t, `{"defaultFormat":"base64","uaFormatRules":`+tt.rules+`}`)
+			assertEqual(t, w.Code, tt.wantCode, "status code")
+		})
+	}
+
+	assertEqual(t, models.GetSetting(models.SettingUAFormatRules, ""), `[{"keyword":"mihomo","format":"clash"}]`, "saved rules")
+}
+
+func TestSaveSettingsKeepsOmittedSettings(t *testing.T) {
+	setupTestDB(t)
+
+	w := saveTestSettings(t, `{"autoRefresh":true,"refreshInterval":12,"defaultFormat":"clash","dedupStrategy":"first_seen","accessLogMaxRows":500,"healthCheckInterval":15,"regionGroupOrder":["jp"]}`)
+	assertEqual(t, w.Code, http.StatusOK, "first save status")
+	w = saveTestSettings(t, `{"refreshInterval":6,"defaultFormat":"base64","accessLogMaxRows":0}`)
+	assertEqual(t, w.Code, http.StatusOK, "second save status")
+
+	want := map[string]string{
+		models.SettingAutoRefresh:         "false",
+		models.SettingRefreshInterval:     "6",
+		models.SettingDefaultFormat:       "base64",
+		models.SettingDedupStrategy:       "first_seen",
+		models.SettingAccessLogMaxRows:    "0",
+		models.SettingHealthCheckInterval: "15",
+		models.SettingRegionGroupOrder:    `["JP"]`,
+	}
+	for key, value := range want {
+		assertEqual(t, models.GetSetting(key, ""), value, key)
+	}
+	var count int64
+	models.DB.Model(&models.Setting{}).Where("key = ?", models.SettingRefreshInterval).Count(&count)
+	assertEqual(t, count, int64(1), "refresh interval rows")
+}
+
+func TestSaveSettingsRefreshesGeoMismatch(t *testing.T) {
+	t.Cleanup(models.ReloadCountryMatcher)
+	setupTestDB(t)
+	models.ReloadCountryMatcher()
+
+	located := createTestProxy(t, models.Proxy{Name: "Foo 01", Type: "trojan", Server: "a.example.com", Port: 443, GeoLocation: models.GeoLocation{GeoCountry: "JP"}})
+	unlocated := createTestProxy(t, models.Proxy{Name: "Foo 02", Type: "trojan", Server: "b.example.com", Port: 443})
+
+	w := saveTestSettings(t, `{"defaultFormat":"base64","countryAliases":[{"alias":"Foo","code":"US"}]}`)
+	assertEqual(t, w.Code, http.StatusOK, "save aliases status")
+	geoMismatch := func(id uint) bool {
+		var proxy models.Proxy
+		if err := models.DB.First(&proxy, id).Error; err != nil {
+			t.Fatalf("load proxy error = %v", err)
+		}
+		return proxy.GeoMismatch
+	}
+	assertEqual(t, geoMismatch(located.ID), true, "mismatch after alias added")
+	assertEqual(t, geoMismatch(unlocated.ID), false, "unlocated proxy")
+
+	w = saveTestSettings(t, `{"defaultFormat":"base64","countryAliases":[{"alias":"Foo","code":"JP"}]}`)
+	assertEqual(t, w.Code, http.StatusOK, "update aliases status")
+	assertEqual(t, geoMismatch(located.ID), false, "mismatch after alias changed")
+}
@@ -1,7 +1,9 @@
 package api
 
 import (
+	"errors"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -43,8 +45,8 @@ func GetSubscriptions(c *gin.Context) {
 					Or("type = 'trojan' AND password != ''").
 					Or("type = 'tuic' AND uuid != '' AND password != ''").
 					Or("(type = 'anytls' OR type = 'hysteria2') AND password != ''").
-					Or("type = 'http'").
-					Or("type = 'socks'"),
+					Or("type = 'ssr' AND method != '' AND password != ''").
+					Or("type IN ('http', 'socks', 'hysteria', 'wireguard')"),
 			)
 
 		if err := countQuery.Count(&validCount).Error; err != nil {
@@ -69,12 +71,9 @@ func AddSubscription(c *gin.Context) {
 		return
 	}
 
-	// 处理URL空格
-	subscription.URL = strings.TrimSpace(subscription.URL)
-
-	// 验证订阅URL
-	if subscription.URL == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "订阅URL不能为空"})
+	// 验证订阅来源
+	if err := normalizeSubscriptionSource(&subscription); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -122,8 +121,10 @@ func UpdateSubscription(c *gin.Context) {
 		return
 	}
 
-	// 新增URL空格处理
-	subscription.URL = strings.TrimSpace(subscription.URL)
+	if err := normalizeSubscriptionSource(&subscription); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// 更新数据库
 	if err := models.DB.Save(&subscription).Error; err != nil {
@@ -137,6 +138,47 @@ func UpdateSubscription(c *gin.Context) {
 	c.JSON(http.StatusOK, subscription)
 }
 
+// normalizeSubscriptionSource 规范化并校验订阅来源，未指定来源时根据URL和内容推断
+func normalizeSubscriptionSource(subscription *models.Subscription) error {
+	subscription.URL = strings.TrimSpace(subscription.URL)
+	subscription.SourceType = strings.ToLower(strings.TrimSpace(subscription.SourceType))
+	if subscription.SourceType == "" {
+		switch {
+		case strings.HasPrefix(subscription.URL, "file://"):
+			subscription.SourceType = models.SubscriptionSourceFile
+		case subscription.URL == "" && strings.TrimSpace(subscription.Content) != "":
+			subscription.SourceType = models.SubscriptionSourceInline
+		default:
+			subscription.SourceType = models.SubscriptionSourceURL
+		}
+	}
+
+	switch subscription.SourceType {
+	case models.SubscriptionSourceURL:
+		if subscription.URL == "" {
+			return errors.New("订阅URL不能为空")
+		}
+		parsedURL, err := url.Parse(subscription.URL)
+		if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+			return errors.New("订阅URL必须是有效的http或https地址")
+		}
+		subscription.Content = ""
+	case models.SubscriptionSourceFile:
+		if _, err := services.ResolveSubscriptionFile(subscription.URL); err != nil {
+			return err
+		}
+		subscription.Content = ""
+	case models.SubscriptionSourceInline:
+		if strings.TrimSpace(subscription.Content) == "" {
+			return errors.New("订阅内容不能为空")
+		}
+		subscription.URL = ""
+	default:
+		return errors.New("不支持的订阅来源: " + subscription.SourceType)
+	}
+	return nil
+}
+
 // DeleteSubscription 删除订阅
 func DeleteSubscription(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -145,21 +187,42 @@ func DeleteSubscription(c *gin.Context) {
 		return
 	}
 
-	// 删除订阅及其关联的代理节点
+	// 删除订阅及其关联的代理节点、链式关系和刷新记录
 	tx := models.DB.Begin()
+	var proxyIDs []uint
+	if err := tx.Model(&models.Proxy{}).Where("subscription_id = ?", id).Pluck("id", &proxyIDs).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.DeleteProxyChains(tx, proxyIDs); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	if err := tx.Where("subscription_id = ?", id).Delete(&models.Proxy{}).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if err := tx.Where("subscription_id = ?", id).Delete(&models.RefreshLog{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	if err := tx.Delete(&models.Subscription{}, id).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	tx.Commit()
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	// 清除缓存
 	services.InvalidateCache()
@@ -176,8 +239,6 @@ func RefreshSubscription(c *gin.Context) {
 		return
 	}
 
-	utils.Info("开始刷新订阅 ID=%d", id)
-
 	var subscription models.Subscription
 	if err := models.DB.First(&subscription, id).Error; err != nil {
 		utils.Warn("刷新订阅失败: 订阅不存在 ID=%d, 错误: %v", id, err)
@@ -185,12 +246,14 @@ func RefreshSubscription(c *gin.Context) {
 		return
 	}
 
-	utils.Info("找到订阅 ID=%d, URL=%s, Type=%s", subscription.ID, subscription.URL, subscription.Type)
-
 	// 刷新订阅
 	if err := services.RefreshSubscription(&subscription); err != nil {
 		utils.Error("刷新订阅失败 ID=%d, URL=%s, 错误: %v", subscription.ID, subscription.URL, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response := gin.H{"error": err.Error()}
+		if report, ok := services.LatestRefreshLog(subscription.ID); ok {
+			response["report"] = report
+		}
+		c.JSON(http.StatusInternalServerError, response)
 		return
 	}
 
@@ -220,9 +283,79 @@ func RefreshSubscription(c *gin.Context) {
 
 	utils.Info("订阅刷新成功 ID=%d, 有效节点数=%d", subscription.ID, validCount)
 
-	// 返回刷新成功信息及有效节点数量
-	c.JSON(http.StatusOK, gin.H{
+	// 返回刷新成功信息、有效节点数量及解析报告
+	response := gin.H{
 		"message":      "订阅刷新成功",
 		"subscription": subscription,
-	})
+	}
+	if report, ok := services.LatestRefreshLog(subscription.ID); ok {
+		response["report"] = report
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetRefreshLogs 获取订阅最近的刷新记录及解析报告
+func GetRefreshLogs(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	logs := make([]models.RefreshLog, 0)
+	if err := models.DB.Where("subscription_id = ?", id).Order("id DESC").Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, logs)
+}
+
+// SubscriptionPreviewRequest 订阅预览请求，URL和Content二选一
+type SubscriptionPreviewRequest struct {
+	URL     string                `json:"url"`
+	Content string                `json:"content"`
+	Type    string                `json:"type"`
+	Fetch   services.FetchOptions `json:"fetch"`
+}
+
+// PreviewSubscription 获取并解析订阅但不保存，返回识别格式、节点、流量信息和解析报告
+func PreviewSubscription(c *gin.Context) {
+	var req SubscriptionPreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+	req.URL = strings.TrimSpace(req.URL)
+	if req.URL == "" && strings.TrimSpace(req.Content) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请提供订阅URL或订阅内容"})
+		return
+	}
+
+	content := req.Content
+	response := gin.H{}
+	if req.URL != "" {
+		fetched, err := services.FetchSubscription(req.URL, req.Fetch)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "status_code": fetched.StatusCode})
+			return
+		}
+		content = fetched.Content
+		response["status_code"] = fetched.StatusCode
+		response["content_type"] = fetched.ContentType
+		response["userinfo"] = fetched.UserInfo
+	}
+
+	result, err := services.ParseSubscription(content, req.Type)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "report": result})
+		return
+	}
+
+	response["format"] = result.Format
+	response["scores"] = services.DetectScores(content)
+	response["content_length"] = len(content)
+	response["report"] = result
+	response["proxy_count"] = len(result.Proxies)
+	response["proxies"] = result.Proxies
+	c.JSON(http.StatusOK, response)
 }
@@ -47,6 +47,8 @@ func main() {
 	// 定义命令行参数
 	host := flag.String("host", "localhost", "服务器主机地址")
 	port := flag.String("port", "8080", "服务器端口")
+	trustedProxies := flag.String("trusted-proxies", "", "信任的反向代理IP或CIDR，逗号分隔；为空时不信任X-Forwarded-For等转发头")
+	externalURL := flag.String("external-url", "", "对外访问本服务的地址，如 https://sub.example.com；为空时按请求地址生成规则集链接")
 	flag.Parse()
 
 	// 构建服务器地址
@@ -70,6 +72,14 @@ func main() {
 	// 使用gin.New()代替gin.Default()
 	r := gin.New()
 
+	// 只信任指定反向代理传入的转发头，避免客户端伪造IP绕过令牌白名单
+	if err := api.ConfigureTrustedProxies(r, *trustedProxies); err != nil {
+		utils.Fatal("信任代理配置无效: %v", err)
+	}
+	if err := api.ConfigureExternalURL(*externalURL); err != nil {
+		utils.Fatal("外部访问地址配置无效: %v", err)
+	}
+
 	// 添加自定义恢复中间件和精简的日志中间件
 	r.Use(gin.Recovery())
 	r.Use(customLogger())
@@ -86,8 +96,13 @@ func main() {
 	// API路由
 	apiGroup := r.Group("/api")
 	{
-		// 公开API
-		apiGroup.GET("/merged", api.GetMergedSubscription) // 无需认证的合并订阅
+		// 合并订阅，需要携带访问令牌（路径参数或token查询参数）
+		apiGroup.GET("/merged", api.SubscriptionTokenRequired(), api.GetMergedSubscription)
+		apiGroup.GET("/merged/:token", api.SubscriptionTokenRequired(), api.GetMergedSubscription)
+		// 托管的规则集，使用合并订阅的访问令牌
+		apiGroup.GET("/rules/:token/:name", api.SubscriptionTokenRequired(), api.GetHostedRuleSet)
+		apiGroup.GET("/convert", api.SubscriptionTokenRequired(), api.ConvertSubscription)
+		apiGroup.POST("/convert", api.SubscriptionTokenRequired(), api.ConvertSubscriptionContent)
 
 		// 登录认证
 		apiGroup.POST("/auth/login", api.Login)
@@ -103,16 +118,65 @@ func main() {
 			// 订阅相关API
 			authGroup.GET("/subscriptions", api.GetSubscriptions)
 			authGroup.POST("/subscriptions", api.AddSubscription)
+			authGroup.POST("/subscriptions/preview", api.PreviewSubscription)
 			authGroup.PUT("/subscriptions/:id", api.UpdateSubscription)
 			authGroup.DELETE("/subscriptions/:id", api.DeleteSubscription)
 			authGroup.POST("/subscriptions/:id/refresh", api.RefreshSubscription)
+			authGroup.GET("/subscriptions/:id/refresh-logs", api.GetRefreshLogs)
 
 			// 代理节点相关API
 			authGroup.GET("/proxies", api.GetProxies)
 			authGroup.POST("/proxies", api.AddCustomProxy)
+			authGroup.POST("/proxies/import", api.ImportCustomProxies)
+			authGroup.POST("/proxies/health-check", api.RunHealthChecks)
+			authGroup.PUT("/proxies/order", api.ReorderProxies)
 			authGroup.GET("/proxies/:id", api.GetProxy)
 			authGroup.PUT("/proxies/:id", api.UpdateProxy)
 			authGroup.DELETE("/proxies/:id", api.DeleteCustomProxy)
+			authGroup.GET("/proxies/:id/health", api.GetProxyHealth)
+			authGroup.POST("/proxies/:id/health-check", api.CheckProxyHealth)
+			authGroup.POST("/proxies/:id/protocol-test", api.TestProxyProtocol)
+			authGroup.GET("/proxy-chains", api.GetProxyChains)
+			authGroup.POST("/proxy-chains", api.AddProxyChain)
+			authGroup.DELETE("/proxy-chains/:id", api.DeleteProxyChain)
+
+			// 国家识别别名表
+			authGroup.GET("/country-aliases", api.GetCountryAliases)
+			authGroup.POST("/country-aliases/match", api.MatchCountryName)
+
+			// GeoIP数据库
+			authGroup.GET("/geoip", api.GetGeoIPStatus)
+			authGroup.POST("/geoip/refresh", api.RefreshGeoLocations)
+
+			// 规则集相关API
+			authGroup.GET("/rule-sets", api.GetRuleSets)
+			authGroup.POST("/rule-sets", api.AddRuleSet)
+			authGroup.GET("/rule-sets/:id", api.GetRuleSet)
+			authGroup.PUT("/rule-sets/:id", api.UpdateRuleSet)
+			authGroup.DELETE("/rule-sets/:id", api.DeleteRuleSet)
+
+			// 输出配置相关API
+			authGroup.GET("/profiles", api.GetProfiles)
+			authGroup.POST("/profiles", api.AddProfile)
+			authGroup.GET("/profiles/:id", api.GetProfile)
+			authGroup.PUT("/profiles/:id", api.UpdateProfile)
+			authGroup.DELETE("/profiles/:id", api.DeleteProfile)
+			authGroup.POST("/profiles/:id/rotate-token", api.RotateProfileToken)
+
+			// 访问令牌相关API
+			authGroup.GET("/tokens", api.GetAccessTokens)
+			authGroup.POST("/tokens", api.AddAccessToken)
+			authGroup.PUT("/tokens/:id", api.UpdateAccessToken)
+			authGroup.DELETE("/tokens/:id", api.DeleteAccessToken)
+			authGroup.POST("/tokens/:id/rotate", api.RotateAccessToken)
+			authGroup.POST("/tokens/:id/revoke", api.RevokeAccessToken)
+
+			// 访问日志
+			authGroup.GET("/access-logs", api.GetAccessLogs)
+			authGroup.GET("/access-logs/stats", api.GetAccessStats)
+
+			// 输出格式
+			authGroup.GET("/formats", api.GetOutputFormats)
 
 			// 设置相关API
 			authGroup.GET("/settings", api.GetSettings)
@@ -120,6 +184,10 @@ func main() {
 		}
 	}
 
+	// 输出配置订阅，通过令牌访问，无需登录
+	r.GET("/sub/:token", api.GetProfileSubscription)
+	r.GET("/sub/:token/rules/:name", api.GetProfileRuleSet)
+
 	subFS, _ := fs.Sub(staticFS, "dist")
 
 	// 静态文件服务
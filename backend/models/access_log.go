@@ -0,0 +1,14 @@
+package models
+
+// AccessLog 订阅下载访问记录
+type AccessLog struct {
+	BaseModel
+	TokenID    uint   `json:"token_id" gorm:"index"`
+	TokenLabel string `json:"token_label"`
+	ProfileID  uint   `json:"profile_id" gorm:"index"`
+	ClientIP   string `json:"client_ip" gorm:"index"`
+	UserAgent  string `json:"user_agent"`
+	Format     string `json:"format"`
+	CacheHit   bool   `json:"cache_hit"`
+	Bytes      int    `json:"bytes"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"net"
+	"strings"
+	"time"
+)
+
+// AccessToken 合并订阅链接的访问令牌
+type AccessToken struct {
+	BaseModel
+	Label         string     `json:"label"`
+	Token         string     `json:"token" gorm:"uniqueIndex;not null"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+	Revoked       bool       `json:"revoked" gorm:"default:false"`
+	AllowedIPs    []string   `json:"allowed_ips" gorm:"serializer:json"` // IP或CIDR白名单，为空表示不限制
+	LastUsedAt    *time.Time `json:"last_used_at"`
+	LastUsedIP    string     `json:"last_used_ip"`
+	LastUserAgent string     `json:"last_user_agent"`
+}
+
+// IsActive 检查令牌是否未吊销且未过期
+func (t *AccessToken) IsActive(now time.Time) bool {
+	if t.Revoked {
+		return false
+	}
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
+
+// AllowsIP 检查客户端IP是否在白名单内
+func (t *AccessToken) AllowsIP(clientIP string) bool {
+	if len(t.AllowedIPs) == 0 {
+		return true
+	}
+
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, allowed := range t.AllowedIPs {
+		if strings.Contains(allowed, "/") {
+			if _, network, err := net.ParseCIDR(allowed); err == nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if allowedIP := net.ParseIP(allowed); allowedIP != nil && allowedIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
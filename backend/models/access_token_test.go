@@ -0,0 +1,58 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccessTokenIsActive(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		token AccessToken
+		want  bool
+	}{
+		{"no expiry", AccessToken{}, true},
+		{"not expired", AccessToken{ExpiresAt: &future}, true},
+		{"expired", AccessToken{ExpiresAt: &past}, false},
+		{"expires now", AccessToken{ExpiresAt: &now}, false},
+		{"revoked", AccessToken{Revoked: true}, false},
+		{"revoked before expiry", AccessToken{Revoked: true, ExpiresAt: &future}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.token.IsActive(now); got != tt.want {
+			t.Fatalf("%s: IsActive() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAccessTokenAllowsIP(t *testing.T) {
+	open := AccessToken{}
+	if !open.AllowsIP("203.0.113.7") {
+		t.Fatal("empty allowlist should allow any IP")
+	}
+
+	token := AccessToken{AllowedIPs: []string{"198.51.100.9", "10.0.0.0/8", "2001:db8::/32", "bad/cidr"}}
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"198.51.100.9", true},
+		{"198.51.100.10", false},
+		{"10.1.2.3", true},
+		{"11.0.0.1", false},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+		{"::ffff:10.0.0.1", true},
+		{"", false},
+		{"not-an-ip", false},
+	}
+	for _, tt := range tests {
+		if got := token.AllowsIP(tt.ip); got != tt.want {
+			t.Fatalf("AllowsIP(%q) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
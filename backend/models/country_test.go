@@ -0,0 +1,152 @@
+package models
+
+import "testing"
+
+func TestCountryMatcherAmbiguousNames(t *testing.T) {
+	matcher := NewCountryMatcher(CountryAliasTable(nil))
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"新加坡-美国中转 01", "SG"},
+		{"美国 → 新加坡", "SG"},
+		{"印度尼西亚 01", "ID"},
+		{"印度 孟买", "IN"},
+		{"新西兰 奥克兰", "NZ"},
+		{"马来西亚 吉隆坡", "MY"},
+		{"加拿大 多伦多", "CA"},
+		{"澳大利亚 悉尼", "AU"},
+		{"澳门 01", "MO"},
+		{"尼日利亚 拉各斯", "NG"},
+		{"尼日尔", "NE"},
+		{"阿尔及利亚", "DZ"},
+		{"斯洛文尼亚", "SI"},
+		{"最新节点 加速 01", ""},
+		{"马上到期", ""},
+		{"每日重置", ""},
+		{"18日到期", ""},
+		{"英文客服", ""},
+		{"美化线路", ""},
+		{"法定节假日", ""},
+		{"德国 法兰克福", "DE"},
+		{"日 01", "JP"},
+		{"美-洛杉矶", "US"},
+		{"港01", "HK"},
+		{"[俄] 莫斯科", "RU"},
+		{"韩|首尔", "KR"},
+		{"Node 01", ""},
+		{"Russia Moscow", "RU"},
+		{"Australia 02", "AU"},
+		{"USA Los Angeles", "US"},
+		{"hk01", "HK"},
+		{"HK-BGP", "HK"},
+		{"Seattle 01", ""},
+		{"Nigeria", "NG"},
+		{"Niger", "NE"},
+		{"🇭🇰 美国落地", "HK"},
+		{"🇯🇵 Tokyo", "JP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 多次匹配结果必须一致
+			for i := 0; i < 20; i++ {
+				if _, got := matcher.Match(tt.name); got != tt.want {
+					t.Fatalf("Match(%q) = %q, want %q", tt.name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCountryAliasOverrides(t *testing.T) {
+	matcher := NewCountryMatcher(CountryAliasTable([]CountryAlias{
+		{Alias: "狮城", Code: "SG"},
+		{Alias: "港", Code: ""},
+		{Alias: "US", Code: "GB"},
+		{Alias: "hk", Code: ""},
+		{Alias: "jp", Code: "KR"},
+	}))
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"狮城 01", "SG"},
+		{"港 01", ""},
+		{"香港 01", "HK"},
+		{"US 01", "GB"},
+		{"HK 01", ""},
+		{"JP 01", "KR"},
+	}
+	for _, tt := range tests {
+		if _, got := matcher.Match(tt.name); got != tt.want {
+			t.Errorf("Match(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateCountryAliases(t *testing.T) {
+	tests := []struct {
+		aliases []CountryAlias
+		wantErr bool
+	}{
+		{[]CountryAlias{{Alias: "狮城", Code: "SG"}}, false},
+		{[]CountryAlias{{Alias: "港", Code: ""}}, false},
+		{[]CountryAlias{{Alias: " ", Code: "SG"}}, true},
+		{[]CountryAlias{{Alias: "狮城", Code: "sg"}}, true},
+		{[]CountryAlias{{Alias: "狮城", Code: "SGP"}}, true},
+	}
+	for i, tt := range tests {
+		if err := ValidateCountryAliases(tt.aliases); (err != nil) != tt.wantErr {
+			t.Errorf("case %d: ValidateCountryAliases() error = %v, wantErr %v", i, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCountryFlag(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"JP", "🇯🇵"},
+		{"hk", "🇭🇰"},
+		{"GB", "🇬🇧"},
+		{"", ""},
+		{"USA", ""},
+		{"1A", ""},
+	}
+	for _, tt := range tests {
+		if got := CountryFlag(tt.code); got != tt.want {
+			t.Errorf("CountryFlag(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+	if !HasFlagEmoji("🇯🇵 Tokyo") || HasFlagEmoji("Tokyo 01") {
+		t.Error("HasFlagEmoji() mismatch")
+	}
+}
+
+func TestFormatCountryDisplay(t *testing.T) {
+	tests := []struct {
+		code   string
+		locale string
+		want   string
+	}{
+		{"JP", DisplayLocaleZh, "日本 (JP)"},
+		{"JP", DisplayLocaleEn, "Japan (JP)"},
+		{"JP", DisplayLocaleCode, "JP"},
+		{"", DisplayLocaleZh, "未知"},
+		{"", DisplayLocaleEn, "Unknown"},
+		{"", DisplayLocaleCode, "Unknown"},
+		{"ZZ", DisplayLocaleEn, "Unknown"},
+	}
+	for _, tt := range tests {
+		if got := FormatCountryDisplay(tt.code, tt.locale); got != tt.want {
+			t.Errorf("FormatCountryDisplay(%q, %q) = %q, want %q", tt.code, tt.locale, got, tt.want)
+		}
+	}
+	for code := range countryChineseNames {
+		if countryEnglishNames[code] == "" {
+			t.Errorf("missing english name for %s", code)
+		}
+	}
+}
@@ -75,26 +75,32 @@ const (
 	DefaultAdminPassword = "admin0505"
 )
 
+// DataDir 返回数据目录，优先使用DATA_DIR环境变量，默认为可执行文件所在目录下的data
+func DataDir() (string, error) {
+	if dataDir := os.Getenv("DATA_DIR"); dataDir != "" {
+		return dataDir, nil
+	}
+
+	// 获取可执行文件所在目录
+	execPath, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(execPath), "data"), nil
+}
+
 // InitDB 初始化数据库连接
 func InitDB() error {
 	// 确保数据目录存在
-	dbDir := os.Getenv("DATA_DIR")
-	if dbDir == "" {
-		// 获取可执行文件所在目录
-		execPath, err := os.Executable()
-		if err != nil {
-			return err
-		}
-		execDir := filepath.Dir(execPath)
-		dbDir = filepath.Join(execDir, "data")
+	dbDir, err := DataDir()
+	if err != nil {
+		return err
 	}
-
 	if err := os.MkdirAll(dbDir, 0755); err != nil {
 		return err
 	}
 
 	dbPath := filepath.Join(dbDir, "nekoray-config.db")
-	var err error
 
 	// 配置GORM
 	logLevel := logger.Info
@@ -137,7 +143,7 @@ func InitDB() error {
 	}
 
 	// 自动迁移表结构
-	if err := DB.AutoMigrate(&Subscription{}, &Proxy{}, &Setting{}, &User{}); err != nil {
+	if err := DB.AutoMigrate(&Subscription{}, &Proxy{}, &Setting{}, &User{}, &Profile{}, &AccessToken{}, &AccessLog{}, &RefreshLog{}, &HealthCheck{}, &RuleSet{}, &ProxyChain{}); err != nil {
 		return err
 	}
 
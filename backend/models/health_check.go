@@ -0,0 +1,27 @@
+package models
+
+import (
+	"net"
+	"strconv"
+)
+
+// HealthCheck 节点可达性探测记录
+// 订阅刷新会重建节点，因此TCP探测按探测地址（Server:Port）而不是节点ID保存历史；
+// 协议测试结果取决于节点凭据，同一地址上的节点需分别记录，刷新时迁移到新的节点ID
+type HealthCheck struct {
+	BaseModel
+	Endpoint   string `json:"endpoint" gorm:"index"`
+	ProxyID    uint   `json:"proxy_id" gorm:"index;default:0"` // 协议测试的节点ID，TCP探测为0
+	Mode       string `json:"mode"`                            // tcp：连接端口；http：通过节点协议访问测试地址
+	Success    bool   `json:"success"`
+	LatencyMs  int    `json:"latency_ms"` // tcp为连接耗时（含TLS握手），http为测试地址的响应耗时
+	TLS        bool   `json:"tls"`        // 是否完成了TLS握手探测
+	StatusCode int    `json:"status_code"`
+	EgressIP   string `json:"egress_ip"` // 测试地址返回的出口IP
+	Error      string `json:"error"`
+}
+
+// HealthEndpoint 返回节点的探测地址
+func (p *Proxy) HealthEndpoint() string {
+	return net.JoinHostPort(p.Server, strconv.Itoa(p.Port))
+}
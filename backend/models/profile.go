@@ -0,0 +1,39 @@
+package models
+
+// Profile 命名输出配置，每个配置拥有独立的节点范围、过滤规则和访问令牌
+type Profile struct {
+	BaseModel
+	Name             string       `json:"name" gorm:"not null"`
+	Token            string       `json:"token" gorm:"uniqueIndex;not null"`
+	Enabled          bool         `json:"enabled" gorm:"default:true"`
+	SubscriptionIDs  []uint       `json:"subscription_ids" gorm:"serializer:json"`  // 包含的订阅
+	ProxyIDs         []uint       `json:"proxy_ids" gorm:"serializer:json"`         // 额外包含的节点（通常为自定义节点）
+	IncludeFilter    string       `json:"include_filter"`                           // 节点名称包含规则（正则）
+	ExcludeFilter    string       `json:"exclude_filter"`                           // 节点名称排除规则（正则）
+	IncludeCountries []string     `json:"include_countries" gorm:"serializer:json"` // 只包含位于这些国家的节点（国家代码）
+	ExcludeCountries []string     `json:"exclude_countries" gorm:"serializer:json"` // 排除位于这些国家的节点（国家代码）
+	RenameRules      []RenameRule `json:"rename_rules" gorm:"serializer:json"`
+	DefaultFormat    string       `json:"default_format"`
+
+	DeadNodePolicy    string `json:"dead_node_policy"`    // 失效节点处理方式，为空时使用全局设置
+	DeadNodeThreshold int    `json:"dead_node_threshold"` // 连续探测失败多少次视为失效，0表示使用全局设置
+	SortStrategy      string `json:"sort_strategy"`       // 输出排序策略，为空时使用全局设置
+}
+
+// 失效节点处理方式
+const (
+	DeadNodeKeep    = "keep"    // 保留失效节点
+	DeadNodeExclude = "exclude" // 从输出中移除失效节点
+	DeadNodeDemote  = "demote"  // 将失效节点移到末尾
+)
+
+// IsValidDeadNodePolicy 判断失效节点处理方式是否有效
+func IsValidDeadNodePolicy(policy string) bool {
+	return policy == DeadNodeKeep || policy == DeadNodeExclude || policy == DeadNodeDemote
+}
+
+// RenameRule 节点重命名规则，按顺序对节点名称执行正则替换
+type RenameRule struct {
+	Pattern string `json:"pattern"`
+	Replace string `json:"replace"`
+}
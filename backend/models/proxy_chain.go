@@ -0,0 +1,8 @@
+package models
+
+// ProxyChain 链式代理：目标节点通过前置节点连接，每个节点最多有一个前置节点
+type ProxyChain struct {
+	BaseModel
+	ProxyID    uint `json:"proxy_id" gorm:"uniqueIndex;not null"` // 目标节点
+	ViaProxyID uint `json:"via_proxy_id" gorm:"index;not null"`   // 前置节点
+}
@@ -0,0 +1,23 @@
+package models
+
+// RefreshLog 订阅刷新记录，保存每次刷新的结果和解析报告
+type RefreshLog struct {
+	BaseModel
+	SubscriptionID  uint           `json:"subscription_id" gorm:"index"`
+	Success         bool           `json:"success"`
+	Error           string         `json:"error"`
+	ContentLength   int            `json:"content_length"`
+	Format          string         `json:"format"`           // 识别出的订阅格式
+	TotalLines      int            `json:"total_lines"`      // 非空行数
+	RecognizedLines int            `json:"recognized_lines"` // 成功解析出节点的行数，结构化格式为节点条目数
+	ProxyCount      int            `json:"proxy_count"`
+	ErrorCount      int            `json:"error_count"`                            // 解析失败的行数
+	LineErrors      []LineError    `json:"line_errors" gorm:"serializer:json"`     // 解析失败的行，最多保存前100条
+	SkippedSchemes  map[string]int `json:"skipped_schemes" gorm:"serializer:json"` // 不支持的链接类型及行数
+}
+
+// LineError 单行解析错误
+type LineError struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
@@ -0,0 +1,50 @@
+package models
+
+// RuleSet 命名路由规则集，托管为Clash rule-provider和sing-box rule_set，并在生成的配置中引用
+type RuleSet struct {
+	BaseModel
+	Name        string      `json:"name" gorm:"uniqueIndex;not null"` // 规则集名称，用于托管地址和配置中的引用，只能包含字母、数字、-和_
+	Description string      `json:"description"`
+	Policy      string      `json:"policy" gorm:"not null"`      // 匹配后使用的策略
+	Enabled     bool        `json:"enabled" gorm:"default:true"` // 是否在生成的配置中引用
+	Priority    int         `json:"priority" gorm:"default:0"`   // 数值越大在规则中越靠前
+	Rules       []RuleEntry `json:"rules" gorm:"serializer:json"`
+}
+
+// RuleEntry 规则集中的单条规则
+type RuleEntry struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// 规则类型
+const (
+	RuleDomain        = "domain"         // 完整域名
+	RuleDomainSuffix  = "domain-suffix"  // 域名后缀
+	RuleDomainKeyword = "domain-keyword" // 域名关键字
+	RuleIPCIDR        = "ip-cidr"        // IP段，支持IPv4和IPv6
+	RuleGeoIP         = "geoip"          // IP所属国家代码
+	RuleProcess       = "process"        // 进程名称
+)
+
+// 规则集策略
+const (
+	RulePolicyProxy  = "proxy"  // 通过代理节点
+	RulePolicyDirect = "direct" // 直连
+	RulePolicyReject = "reject" // 拒绝连接
+)
+
+// IsValidRuleType 判断规则类型是否有效
+func IsValidRuleType(ruleType string) bool {
+	switch ruleType {
+	case RuleDomain, RuleDomainSuffix, RuleDomainKeyword, RuleIPCIDR, RuleGeoIP, RuleProcess:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsValidRulePolicy 判断规则集策略是否有效
+func IsValidRulePolicy(policy string) bool {
+	return policy == RulePolicyProxy || policy == RulePolicyDirect || policy == RulePolicyReject
+}
@@ -12,4 +12,43 @@ const (
 	SettingAutoRefresh     = "auto_refresh"
 	SettingRefreshInterval = "refresh_interval"
 	SettingDefaultFormat   = "default_format"
+	SettingDedupEnabled    = "dedup_enabled"
+	SettingDedupStrategy   = "dedup_strategy"
+	SettingUAFormatRules   = "ua_format_rules"
+
+	SettingAccessLogRetentionDays = "access_log_retention_days"
+	SettingAccessLogMaxRows       = "access_log_max_rows"
+
+	SettingHealthCheckEnabled     = "health_check_enabled"
+	SettingHealthCheckInterval    = "health_check_interval"
+	SettingHealthCheckConcurrency = "health_check_concurrency"
+	SettingHealthCheckTimeout     = "health_check_timeout"
+	SettingHealthCheckTLS         = "health_check_tls"
+	SettingProtocolTestEnabled    = "protocol_test_enabled"
+	SettingProtocolTestURL        = "protocol_test_url"
+
+	SettingDeadNodePolicy    = "dead_node_policy"
+	SettingDeadNodeThreshold = "dead_node_threshold"
+
+	SettingOutputSort     = "output_sort"
+	SettingCountryAliases = "country_aliases"
+	SettingDisplayLocale  = "display_locale"
+	SettingExportFlag     = "export_flag"
+
+	SettingRegionGroups       = "region_groups"
+	SettingRegionGroupType    = "region_group_type"
+	SettingRegionGroupOrder   = "region_group_order"
+	SettingRegionGroupMinSize = "region_group_min_size"
 )
+
+// GetSetting 读取设置值，不存在或为空时返回默认值
+func GetSetting(key string, defaultValue string) string {
+	var setting Setting
+	if err := DB.Where("key = ?", key).First(&setting).Error; err != nil {
+		return defaultValue
+	}
+	if setting.Value == "" {
+		return defaultValue
+	}
+	return setting.Value
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"proxy-subscription/models"
+	"proxy-subscription/utils"
+
+	"gorm.io/gorm"
+)
+
+// 访问日志默认保留策略
+const (
+	DefaultAccessLogRetentionDays = 30
+	DefaultAccessLogMaxRows       = 100000
+)
+
+// AccessStats 订阅访问统计
+type AccessStats struct {
+	Since    time.Time        `json:"since"`
+	Total    int              `json:"total"`
+	Clients  []ClientAccess   `json:"clients"`
+	Formats  []FormatAccess   `json:"formats"`
+	Timeline []TimelineBucket `json:"timeline"`
+}
+
+// ClientAccess 按客户端（令牌/IP/User-Agent）汇总的访问次数
+type ClientAccess struct {
+	TokenID    uint      `json:"token_id"`
+	TokenLabel string    `json:"token_label"`
+	ClientIP   string    `json:"client_ip"`
+	UserAgent  string    `json:"user_agent"`
+	Count      int       `json:"count"`
+	Bytes      int       `json:"bytes"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// FormatAccess 按输出格式汇总的访问次数
+type FormatAccess struct {
+	Format string `json:"format"`
+	Count  int    `json:"count"`
+	Bytes  int    `json:"bytes"`
+}
+
+// TimelineBucket 按天汇总的访问次数，Formats为各格式的次数
+type TimelineBucket struct {
+	Date    string         `json:"date"`
+	Count   int            `json:"count"`
+	Clients int            `json:"clients"`
+	Formats map[string]int `json:"formats"`
+}
+
+// RecordAccess 写入一条访问日志，失败只记录警告
+func RecordAccess(entry models.AccessLog) {
+	if err := models.DB.Create(&entry).Error; err != nil {
+		utils.Warn("写入访问日志失败: %v", err)
+	}
+}
+
+// PruneAccessLogs 按保留天数和最大条数清理访问日志
+func PruneAccessLogs() {
+	retentionDays := settingInt(models.SettingAccessLogRetentionDays, DefaultAccessLogRetentionDays)
+	maxRows := settingInt(models.SettingAccessLogMaxRows, DefaultAccessLogMaxRows)
+
+	if retentionDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -retentionDays)
+		result := models.DB.Where("created_at < ?", cutoff).Delete(&models.AccessLog{})
+		if result.Error != nil {
+			utils.Error("清理过期访问日志失败: %v", result.Error)
+		} else if result.RowsAffected > 0 {
+			utils.Info("已清理 %d 条过期访问日志", result.RowsAffected)
+		}
+	}
+
+	if maxRows > 0 {
+		// 找到需要保留的最早一条记录，删除比它更早的记录
+		var boundary models.AccessLog
+		err := models.DB.Select("id").Order("id DESC").Offset(maxRows - 1).Limit(1).Find(&boundary).Error
+		if err != nil {
+			utils.Error("查询访问日志失败: %v", err)
+			return
+		}
+		if boundary.ID == 0 {
+			return
+		}
+		result := models.DB.Where("id < ?", boundary.ID).Delete(&models.AccessLog{})
+		if result.Error != nil {
+			utils.Error("清理超量访问日志失败: %v", result.Error)
+		} else if result.RowsAffected > 0 {
+			utils.Info("访问日志超过 %d 条，已清理 %d 条", maxRows, result.RowsAffected)
+		}
+	}
+}
+
+// SummarizeAccessLogs 汇总since之后的访问日志，计数在数据库中按分组聚合完成
+func SummarizeAccessLogs(since time.Time) (AccessStats, error) {
+	stats := AccessStats{
+		Since:    since,
+		Clients:  []ClientAccess{},
+		Formats:  []FormatAccess{},
+		Timeline: []TimelineBucket{},
+	}
+
+	base := func() *gorm.DB {
+		return models.DB.Model(&models.AccessLog{}).Where("created_at >= ?", since)
+	}
+
+	var total int64
+	if err := base().Count(&total).Error; err != nil {
+		return stats, err
+	}
+	stats.Total = int(total)
+	if total == 0 {
+		return stats, nil
+	}
+
+	// 按令牌、IP和User-Agent分组，首次和最近访问时间通过对应记录的ID回查
+	var clientRows []struct {
+		TokenID    uint
+		TokenLabel string
+		ClientIP   string
+		UserAgent  string
+		Count      int
+		Bytes      int
+		FirstID    uint
+		LastID     uint
+	}
+	if err := base().
+		Select("token_id, MAX(token_label) AS token_label, client_ip, user_agent, COUNT(*) AS count, COALESCE(SUM(bytes), 0) AS bytes, MIN(id) AS first_id, MAX(id) AS last_id").
+		Group("token_id, client_ip, user_agent").
+		Order("count DESC, first_id").
+		Scan(&clientRows).Error; err != nil {
+		return stats, err
+	}
+	ids := make([]uint, 0, len(clientRows)*2)
+	for _, row := range clientRows {
+		ids = append(ids, row.FirstID, row.LastID)
+	}
+	var seen []models.AccessLog
+	if err := models.DB.Select("id, created_at").Where("id IN ?", ids).Find(&seen).Error; err != nil {
+		return stats, err
+	}
+	seenAt := make(map[uint]time.Time, len(seen))
+	for _, entry := range seen {
+		seenAt[entry.ID] = entry.CreatedAt
+	}
+	for _, row := range clientRows {
+		stats.Clients = append(stats.Clients, ClientAccess{
+			TokenID:    row.TokenID,
+			TokenLabel: row.TokenLabel,
+			ClientIP:   row.ClientIP,
+			UserAgent:  row.UserAgent,
+			Count:      row.Count,
+			Bytes:      row.Bytes,
+			FirstSeen:  seenAt[row.FirstID],
+			LastSeen:   seenAt[row.LastID],
+		})
+	}
+
+	if err := base().
+		Select("format, COUNT(*) AS count, COALESCE(SUM(bytes), 0) AS bytes").
+		Group("format").
+		Order("count DESC, MIN(id)").
+		Scan(&stats.Formats).Error; err != nil {
+		return stats, err
+	}
+
+	// 按本地日期分组在Go中完成：SQLite只能加固定的时区偏移，统计范围跨越夏令时切换时会错分日期
+	type clientKey struct {
+		tokenID   uint
+		clientIP  string
+		userAgent string
+	}
+	buckets := make(map[string]*TimelineBucket)
+	clients := make(map[string]map[clientKey]struct{})
+	var batch []models.AccessLog
+	if err := base().Select("id, created_at, token_id, client_ip, user_agent, format").
+		FindInBatches(&batch, 1000, func(tx *gorm.DB, _ int) error {
+			for _, entry := range batch {
+				day := entry.CreatedAt.In(time.Local).Format("2006-01-02")
+				bucket, exists := buckets[day]
+				if !exists {
+					bucket = &TimelineBucket{Date: day, Formats: map[string]int{}}
+					buckets[day] = bucket
+					clients[day] = make(map[clientKey]struct{})
+				}
+				bucket.Count++
+				bucket.Formats[entry.Format]++
+				clients[day][clientKey{entry.TokenID, entry.ClientIP, entry.UserAgent}] = struct{}{}
+			}
+			return nil
+		}).Error; err != nil {
+		return stats, err
+	}
+
+	for day, bucket := range buckets {
+		bucket.Clients = len(clients[day])
+		stats.Timeline = append(stats.Timeline, *bucket)
+	}
+	sort.Slice(stats.Timeline, func(i, j int) bool {
+		return stats.Timeline[i].Date < stats.Timeline[j].Date
+	})
+	return stats, nil
+}
+
+// settingInt 读取整数设置，无效时返回默认值
+func settingInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(models.GetSetting(key, strconv.Itoa(defaultValue)))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"proxy-subscription/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB 使用临时目录下的SQLite数据库替换全局连接，测试结束后恢复
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open() error = %v", err)
+	}
+	if err := db.AutoMigrate(&models.Subscription{}, &models.Proxy{}, &models.Setting{}, &models.AccessLog{}, &models.HealthCheck{}, &models.ProxyChain{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+
+	original := models.DB
+	models.DB = db
+	t.Cleanup(func() {
+		models.DB = original
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+func TestRecordAccess(t *testing.T) {
+	setupTestDB(t)
+
+	RecordAccess(models.AccessLog{TokenID: 3, TokenLabel: "phone", ClientIP: "198.51.100.9", UserAgent: "clash-verge", Format: "clash", CacheHit: true, Bytes: 512})
+
+	var logs []models.AccessLog
+	if err := models.DB.Find(&logs).Error; err != nil {
+		t.Fatalf("load access logs error = %v", err)
+	}
+	assertEqual(t, len(logs), 1, "log count")
+	assertEqual(t, logs[0].TokenLabel, "phone", "token label")
+	assertEqual(t, logs[0].CacheHit, true, "cache hit")
+	assertEqual(t, logs[0].CreatedAt.IsZero(), false, "created at")
+}
+
+func TestSummarizeAccessLogs(t *testing.T) {
+	setupTestDB(t)
+
+	day1 := time.Date(2024, 6, 1, 23, 30, 0, 0, time.Local)
+	day2 := day1.Add(time.Hour) // 次日00:30
+	entries := []models.AccessLog{
+		{TokenID: 1, TokenLabel: "phone", ClientIP: "198.51.100.9", UserAgent: "clash-verge", Format: "clash", Bytes: 100},
+		{TokenID: 1, TokenLabel: "phone", ClientIP: "198.51.100.9", UserAgent: "clash-verge", Format: "clash", Bytes: 100},
+		{TokenID: 2, TokenLabel: "laptop", ClientIP: "203.0.113.7", UserAgent: "v2rayN", Format: "base64", Bytes: 50},
+		{TokenID: 1, TokenLabel: "phone", ClientIP: "198.51.100.9", UserAgent: "clash-verge", Format: "clash", Bytes: 100},
+		{TokenID: 2, TokenLabel: "laptop", ClientIP: "203.0.113.7", UserAgent: "sing-box", Format: "singbox", Bytes: 70},
+	}
+	times := []time.Time{day1, day1.Add(time.Minute), day1.Add(2 * time.Minute), day2, day2.Add(time.Minute)}
+	for i := range entries {
+		entries[i].CreatedAt = times[i]
+		if err := models.DB.Create(&entries[i]).Error; err != nil {
+			t.Fatalf("create access log error = %v", err)
+		}
+	}
+	// since之前的记录不参与统计
+	old := models.AccessLog{TokenID: 9, Format: "clash", BaseModel: models.BaseModel{CreatedAt: day1.AddDate(0, 0, -10)}}
+	if err := models.DB.Create(&old).Error; err != nil {
+		t.Fatalf("create access log error = %v", err)
+	}
+
+	stats, err := SummarizeAccessLogs(day1.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("SummarizeAccessLogs() error = %v", err)
+	}
+	assertEqual(t, stats.Total, 5, "total")
+
+	assertEqual(t, len(stats.Clients), 3, "client count")
+	assertEqual(t, stats.Clients[0].TokenLabel, "phone", "top client")
+	assertEqual(t, stats.Clients[0].Count, 3, "top client count")
+	assertEqual(t, stats.Clients[0].Bytes, 300, "top client bytes")
+	assertEqual(t, stats.Clients[0].FirstSeen.Equal(day1), true, "first seen")
+	assertEqual(t, stats.Clients[0].LastSeen.Equal(day2), true, "last seen")
+	assertEqual(t, stats.Clients[1].UserAgent, "v2rayN", "tie keeps first appearance")
+
+	assertEqual(t, len(stats.Formats), 3, "format count")
+	assertEqual(t, stats.Formats[0].Format, "clash", "top format")
+	assertEqual(t, stats.Formats[0].Count, 3, "clash count")
+	assertEqual(t, stats.Formats[1].Format, "base64", "format tie order")
+
+	assertEqual(t, len(stats.Timeline), 2, "timeline days")
+	assertEqual(t, stats.Timeline[0].Date, "2024-06-01", "first day")
+	assertEqual(t, stats.Timeline[0].Count, 3, "first day count")
+	assertEqual(t, stats.Timeline[0].Clients, 2, "first day clients")
+	assertEqual(t, stats.Timeline[0].Formats["clash"], 2, "first day clash")
+	assertEqual(t, stats.Timeline[1].Date, "2024-06-02", "second day")
+	assertEqual(t, stats.Timeline[1].Count, 2, "second day count")
+	assertEqual(t, stats.Timeline[1].Clients, 2, "second day clients")
+	assertEqual(t, stats.Timeline[1].Formats["singbox"], 1, "second day singbox")
+
+	empty, err := SummarizeAccessLogs(day2.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("SummarizeAccessLogs() error = %v", err)
+	}
+	assertEqual(t, empty.Total, 0, "empty total")
+	assertEqual(t, len(empty.Clients), 0, "empty clients")
+}
+
+func TestSummarizeAccessLogsAcrossDST(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("LoadLocation() error = %v", err)
+	}
+	original := time.Local
+	time.Local = location
+	defer func() { time.Local = original }()
+	setupTestDB(t)
+
+	// 冬令时(UTC-5)和夏令时(UTC-4)的23:30都应计入当地当天
+	times := []time.Time{
+		time.Date(2024, 1, 15, 23, 30, 0, 0, location),
+		time.Date(2024, 7, 15, 23, 30, 0, 0, location),
+		time.Date(2024, 7, 16, 0, 30, 0, 0, location),
+	}
+	for _, createdAt := range times {
+		entry := models.AccessLog{TokenID: 1, Format: "clash", BaseModel: models.BaseModel{CreatedAt: createdAt.UTC()}}
+		if err := models.DB.Create(&entry).Error; err != nil {
+			t.Fatalf("create access log error = %v", err)
+		}
+	}
+
+	stats, err := SummarizeAccessLogs(times[0].Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("SummarizeAccessLogs() error = %v", err)
+	}
+	assertEqual(t, len(stats.Timeline), 3, "timeline days")
+	assertEqual(t, stats.Timeline[0].Date, "2024-01-15", "winter day")
+	assertEqual(t, stats.Timeline[1].Date, "2024-07-15", "summer day")
+	assertEqual(t, stats.Timeline[2].Date, "2024-07-16", "summer next day")
+	assertEqual(t, stats.Timeline[2].Clients, 1, "summer next day clients")
+}
+
+func TestPruneAccessLogs(t *testing.T) {
+	setupTestDB(t)
+	models.DB.Create(&models.Setting{Key: models.SettingAccessLogMaxRows, Value: "2"})
+
+	now := time.Now()
+	for i, createdAt := range []time.Time{now.AddDate(0, 0, -40), now.Add(-3 * time.Hour), now.Add(-2 * time.Hour), now.Add(-time.Hour)} {
+		entry := models.AccessLog{Format: "clash", Bytes: i, BaseModel: models.BaseModel{CreatedAt: createdAt}}
+		if err := models.DB.Create(&entry).Error; err != nil {
+			t.Fatalf("create access log error = %v", err)
+		}
+	}
+
+	PruneAccessLogs()
+
+	var logs []models.AccessLog
+	if err := models.DB.Order("id").Find(&logs).Error; err != nil {
+		t.Fatalf("load access logs error = %v", err)
+	}
+	assertEqual(t, len(logs), 2, "remaining logs")
+	assertEqual(t, logs[0].Bytes, 2, "oldest kept")
+	assertEqual(t, logs[1].Bytes, 3, "newest kept")
+}
@@ -9,9 +9,11 @@ import (
 
 // 缓存结构体
 type CacheItem struct {
-	Content     string    // 缓存的内容
-	ContentType string    // 内容类型
-	Timestamp   time.Time // 缓存时间
+	Content     string            // 缓存的内容
+	ContentType string            // 内容类型
+	Headers     map[string]string // 需要随内容返回的额外响应头
+	Variant     string            // 内容依赖的请求信息（如访问地址），由调用方比较，不一致时应重新生成
+	Timestamp   time.Time         // 缓存时间
 }
 
 // 全局缓存
@@ -20,33 +22,29 @@ var (
 	cacheDuration     = 5 * time.Minute // 缓存有效期
 )
 
-// GetSubscriptionCache 从缓存获取订阅内容
-func GetSubscriptionCache(format string) (string, string, bool) {
-	key := getCacheKey(format)
+// GetSubscriptionCache 从缓存获取订阅内容，scope 由输出范围和格式组成
+func GetSubscriptionCache(scope string) (CacheItem, bool) {
+	key := getCacheKey(scope)
 	if item, exists := subscriptionCache.Load(key); exists {
 		cacheItem := item.(CacheItem)
 		// 检查缓存是否过期
 		if time.Since(cacheItem.Timestamp) < cacheDuration {
-			return cacheItem.Content, cacheItem.ContentType, true
+			return cacheItem, true
 		}
 	}
-	return "", "", false
+	return CacheItem{}, false
 }
 
 // SetSubscriptionCache 设置订阅缓存
-func SetSubscriptionCache(format, content, contentType string) {
-	key := getCacheKey(format)
-	cacheItem := CacheItem{
-		Content:     content,
-		ContentType: contentType,
-		Timestamp:   time.Now(),
-	}
+func SetSubscriptionCache(scope string, cacheItem CacheItem) {
+	key := getCacheKey(scope)
+	cacheItem.Timestamp = time.Now()
 	subscriptionCache.Store(key, cacheItem)
 }
 
 // getCacheKey 生成缓存键
-func getCacheKey(format string) string {
-	hash := md5.Sum([]byte(format))
+func getCacheKey(scope string) string {
+	hash := md5.Sum([]byte(scope))
 	return "subscription_" + hex.EncodeToString(hash[:])
 }
 
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+
+	"proxy-subscription/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrProxyChainCycle 设置的前置节点会形成循环
+var ErrProxyChainCycle = errors.New("前置节点形成循环")
+
+// SkippedChain 输出时被跳过的链式节点及原因
+type SkippedChain struct {
+	Name   string `json:"name"`
+	Via    string `json:"via"`
+	Reason string `json:"reason"`
+}
+
+// LoadProxyChains 读取节点ID到前置节点ID的映射
+func LoadProxyChains() (map[uint]uint, error) {
+	var chains []models.ProxyChain
+	if err := models.DB.Find(&chains).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[uint]uint, len(chains))
+	for _, chain := range chains {
+		result[chain.ProxyID] = chain.ViaProxyID
+	}
+	return result, nil
+}
+
+// ValidateProxyChain 检查为proxyID设置前置节点viaID后是否形成循环，chains为现有的链式关系
+func ValidateProxyChain(chains map[uint]uint, proxyID, viaID uint) error {
+	if proxyID == viaID {
+		return errors.New("前置节点不能是节点自身")
+	}
+	visited := map[uint]struct{}{proxyID: {}}
+	for current := viaID; ; {
+		if _, exists := visited[current]; exists {
+			return ErrProxyChainCycle
+		}
+		visited[current] = struct{}{}
+		next, exists := chains[current]
+		if !exists {
+			return nil
+		}
+		current = next
+	}
+}
+
+// ResolveProxyChains 按节点ID设置输出时的前置节点名称，名称需在去重、重命名之后确定。
+// 前置节点不在输出中的节点被跳过，以其为前置节点的节点也随之跳过
+func ResolveProxyChains(proxies []models.Proxy, chains map[uint]uint) ([]models.Proxy, []SkippedChain) {
+	names := make(map[uint]string, len(proxies))
+	for _, proxy := range proxies {
+		if proxy.ID != 0 {
+			names[proxy.ID] = proxy.Name
+		}
+	}
+
+	result := make([]models.Proxy, 0, len(proxies))
+	skipped := make([]SkippedChain, 0)
+	for _, proxy := range proxies {
+		proxy.DialerProxy = ""
+		if viaID, exists := chains[proxy.ID]; exists && proxy.ID != 0 {
+			viaName, inOutput := names[viaID]
+			if !inOutput {
+				skipped = append(skipped, SkippedChain{Name: proxy.Name, Via: viaLabel(viaID), Reason: "前置节点不在输出中"})
+				continue
+			}
+			proxy.DialerProxy = viaName
+		}
+		result = append(result, proxy)
+	}
+
+	result, dropped := DropBrokenChains(result)
+	return result, append(skipped, dropped...)
+}
+
+// viaLabel 前置节点不在输出中时无法得知其输出名称，以节点ID标识
+func viaLabel(viaID uint) string {
+	return "#" + strconv.FormatUint(uint64(viaID), 10)
+}
+
+// DropBrokenChains 跳过前置节点已不在列表中的节点，直到所有链式节点的前置节点都存在
+func DropBrokenChains(proxies []models.Proxy) ([]models.Proxy, []SkippedChain) {
+	skipped := make([]SkippedChain, 0)
+	for {
+		names := make(map[string]struct{}, len(proxies))
+		for _, proxy := range proxies {
+			names[proxy.Name] = struct{}{}
+		}
+
+		kept := make([]models.Proxy, 0, len(proxies))
+		for _, proxy := range proxies {
+			if proxy.DialerProxy != "" {
+				if _, exists := names[proxy.DialerProxy]; !exists {
+					skipped = append(skipped, SkippedChain{Name: proxy.Name, Via: proxy.DialerProxy, Reason: "前置节点已被跳过"})
+					continue
+				}
+			}
+			kept = append(kept, proxy)
+		}
+		if len(kept) == len(proxies) {
+			return kept, skipped
+		}
+		proxies = kept
+	}
+}
+
+// RemapProxyChains 订阅刷新重建节点后，按节点标识将链式关系迁移到新的节点ID，
+// 标识不再存在的节点相关的链式关系被删除。oldKeys为被删除节点的ID到标识，newIDs为新节点的标识到ID。
+// 多个旧节点对应同一个新节点时只保留最早的链式关系，迁移后指向自身的关系同样删除
+func RemapProxyChains(tx *gorm.DB, oldKeys map[uint]string, newIDs map[string]uint) error {
+	if len(oldKeys) == 0 {
+		return nil
+	}
+	oldIDs := make([]uint, 0, len(oldKeys))
+	for id := range oldKeys {
+		oldIDs = append(oldIDs, id)
+	}
+
+	var chains []models.ProxyChain
+	if err := tx.Where("proxy_id IN ? OR via_proxy_id IN ?", oldIDs, oldIDs).Order("id").Find(&chains).Error; err != nil {
+		return err
+	}
+	if len(chains) == 0 {
+		return nil
+	}
+	chainIDs := make([]uint, 0, len(chains))
+	for _, chain := range chains {
+		chainIDs = append(chainIDs, chain.ID)
+	}
+
+	// 其余链式关系占用的节点ID不能再被迁移过来的关系使用
+	var claimedIDs []uint
+	if err := tx.Model(&models.ProxyChain{}).Where("id NOT IN ?", chainIDs).Pluck("proxy_id", &claimedIDs).Error; err != nil {
+		return err
+	}
+	claimed := make(map[uint]struct{}, len(claimedIDs)+len(chains))
+	for _, id := range claimedIDs {
+		claimed[id] = struct{}{}
+	}
+
+	remapped := make([]models.ProxyChain, 0, len(chains))
+	for _, chain := range chains {
+		proxyID, proxyOK := remapProxyID(chain.ProxyID, oldKeys, newIDs)
+		viaID, viaOK := remapProxyID(chain.ViaProxyID, oldKeys, newIDs)
+		if !proxyOK || !viaOK || proxyID == viaID {
+			continue
+		}
+		if _, exists := claimed[proxyID]; exists {
+			continue
+		}
+		claimed[proxyID] = struct{}{}
+		chain.ID = 0
+		chain.ProxyID = proxyID
+		chain.ViaProxyID = viaID
+		remapped = append(remapped, chain)
+	}
+
+	// 先删除再重建，新旧ID重叠时逐条更新会违反proxy_id的唯一约束
+	if err := tx.Where("id IN ?", chainIDs).Delete(&models.ProxyChain{}).Error; err != nil {
+		return err
+	}
+	if len(remapped) == 0 {
+		return nil
+	}
+	return tx.Create(&remapped).Error
+}
+
+// remapProxyID 返回节点的新ID，未被重建的节点ID不变
+func remapProxyID(id uint, oldKeys map[uint]string, newIDs map[string]uint) (uint, bool) {
+	sourceKey, rebuilt := oldKeys[id]
+	if !rebuilt {
+		return id, true
+	}
+	newID, exists := newIDs[sourceKey]
+	return newID, exists
+}
+
+// DeleteProxyChains 删除与节点相关的链式关系
+func DeleteProxyChains(tx *gorm.DB, proxyIDs []uint) error {
+	if len(proxyIDs) == 0 {
+		return nil
+	}
+	return tx.Where("proxy_id IN ? OR via_proxy_id IN ?", proxyIDs, proxyIDs).Delete(&models.ProxyChain{}).Error
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"testing"
+
+	"proxy-subscription/models"
+)
+
+func TestValidateProxyChain(t *testing.T) {
+	// 3 通过 2 连接，2 通过 1 连接
+	chains := map[uint]uint{3: 2, 2: 1}
+
+	tests := []struct {
+		name    string
+		proxyID uint
+		viaID   uint
+		wantErr bool
+	}{
+		{name: "new chain", proxyID: 1, viaID: 4},
+		{name: "extends existing chain", proxyID: 4, viaID: 3},
+		{name: "self", proxyID: 4, viaID: 4, wantErr: true},
+		{name: "direct cycle", proxyID: 1, viaID: 2, wantErr: true},
+		{name: "indirect cycle", proxyID: 1, viaID: 3, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateProxyChain(chains, tt.proxyID, tt.viaID)
+			assertEqual(t, err != nil, tt.wantErr, "ValidateProxyChain error")
+		})
+	}
+}
+
+func TestResolveProxyChains(t *testing.T) {
+	proxies := []models.Proxy{
+		{BaseModel: models.BaseModel{ID: 1}, Name: "Front"},
+		{BaseModel: models.BaseModel{ID: 2}, Name: "Relay"},
+		{BaseModel: models.BaseModel{ID: 3}, Name: "Exit"},
+		{BaseModel: models.BaseModel{ID: 4}, Name: "Orphan"},
+		{BaseModel: models.BaseModel{ID: 5}, Name: "Orphan Child"},
+	}
+	// Exit -> Relay -> Front，Orphan的前置节点不在输出中
+	chains := map[uint]uint{3: 2, 2: 1, 4: 9, 5: 4}
+
+	resolved, skipped := ResolveProxyChains(proxies, chains)
+	assertNames(t, resolved, []string{"Front", "Relay", "Exit"}, "resolved")
+	assertEqual(t, resolved[0].DialerProxy, "", "front has no dialer proxy")
+	assertEqual(t, resolved[1].DialerProxy, "Front", "relay dialer proxy")
+	assertEqual(t, resolved[2].DialerProxy, "Relay", "exit dialer proxy")
+	assertEqual(t, len(skipped), 2, "skipped chains")
+	assertEqual(t, skipped[0].Name, "Orphan", "missing front skipped")
+	assertEqual(t, skipped[0].Via, "#9", "missing front via")
+	assertEqual(t, skipped[1].Name, "Orphan Child", "chain through skipped node skipped")
+	assertEqual(t, skipped[1].Via, "Orphan", "skipped front via")
+}
+
+func TestDropBrokenChains(t *testing.T) {
+	proxies := []models.Proxy{
+		{Name: "A"},
+		{Name: "B", DialerProxy: "Missing"},
+		{Name: "C", DialerProxy: "B"},
+		{Name: "D", DialerProxy: "A"},
+	}
+
+	kept, skipped := DropBrokenChains(proxies)
+	assertNames(t, kept, []string{"A", "D"}, "kept")
+	assertEqual(t, len(skipped), 2, "skipped chains")
+	assertEqual(t, skipped[1].Via, "B", "transitively broken chain")
+}
+
+func TestRemapProxyChains(t *testing.T) {
+	setupTestDB(t)
+	chains := []models.ProxyChain{
+		{ProxyID: 5, ViaProxyID: 100}, // 5和6标识相同，迁移到同一个新节点
+		{ProxyID: 6, ViaProxyID: 101},
+		{ProxyID: 7, ViaProxyID: 5},  // 新旧ID互换
+		{ProxyID: 8, ViaProxyID: 9},  // 迁移后指向自身
+		{ProxyID: 10, ViaProxyID: 6}, // 节点已移除
+		{ProxyID: 200, ViaProxyID: 201},
+	}
+	if err := models.DB.Create(&chains).Error; err != nil {
+		t.Fatalf("create proxy chains error = %v", err)
+	}
+
+	oldKeys := map[uint]string{5: "a", 6: "a", 7: "b", 8: "c", 9: "c", 10: "d"}
+	newIDs := map[string]uint{"a": 7, "b": 5, "c": 11}
+	if err := RemapProxyChains(models.DB, oldKeys, newIDs); err != nil {
+		t.Fatalf("RemapProxyChains() error = %v", err)
+	}
+
+	result, err := LoadProxyChains()
+	if err != nil {
+		t.Fatalf("LoadProxyChains() error = %v", err)
+	}
+	assertEqual(t, len(result), 3, "remaining chains")
+	assertEqual(t, result[7], uint(100), "earliest chain kept for merged node")
+	assertEqual(t, result[5], uint(7), "swapped IDs remapped")
+	assertEqual(t, result[200], uint(201), "unrelated chain untouched")
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"proxy-subscription/models"
+)
+
+// 去重优先级策略
+const (
+	DedupStrategyCustomFirst       = "custom_first"       // 自定义节点优先，其次按订阅优先级
+	DedupStrategySubscriptionFirst = "subscription_first" // 订阅节点优先，其次按订阅优先级
+	DedupStrategyFirstSeen         = "first_seen"         // 保留最先出现的节点
+)
+
+// IsValidDedupStrategy 检查去重策略是否受支持
+func IsValidDedupStrategy(strategy string) bool {
+	switch strategy {
+	case DedupStrategyCustomFirst, DedupStrategySubscriptionFirst, DedupStrategyFirstSeen:
+		return true
+	default:
+		return false
+	}
+}
+
+// DedupOptions 合并输出时的去重选项
+type DedupOptions struct {
+	Strategy   string       // 重复节点的保留策略
+	Priorities map[uint]int // 订阅ID到优先级的映射
+}
+
+// ConnectionKey 返回节点的连接标识（类型/服务器/端口/认证信息/传输层），
+// 名称不同但连接参数完全一致的节点视为同一个节点
+func ConnectionKey(proxy models.Proxy) string {
+	rawConfig := parseRawConfig(proxy.RawConfig)
+	parts := []string{
+		strings.ToLower(strings.TrimSpace(proxy.Type)),
+		strings.ToLower(strings.TrimSpace(proxy.Server)),
+		strconv.Itoa(proxy.Port),
+		strings.TrimSpace(proxy.UUID),
+		strings.TrimSpace(proxy.Password),
+		strings.ToLower(strings.TrimSpace(proxy.Method)),
+		rawConfigText(rawConfig, "username"),
+		strings.ToLower(strings.TrimSpace(proxy.Network)),
+		strings.TrimSpace(proxy.Path),
+		strings.ToLower(strings.TrimSpace(proxy.Host)),
+		strconv.FormatBool(proxy.TLS),
+		strings.ToLower(strings.TrimSpace(proxy.SNI)),
+		strings.TrimSpace(proxy.Plugin),
+		strings.TrimSpace(proxy.PluginOpts),
+	}
+	parts = append(parts, rawCredentialParts(strings.ToLower(strings.TrimSpace(proxy.Type)), rawConfig)...)
+	return strings.Join(parts, "\x00")
+}
+
+// rawCredentialParts 返回只保存在RawConfig中的协议专属认证参数，
+// 这些字段不同的节点无法互相替代，不能被当作重复节点合并
+func rawCredentialParts(proxyType string, rawConfig map[string]interface{}) []string {
+	switch proxyType {
+	case "wireguard":
+		return []string{
+			rawConfigText(rawConfig, "private_key"),
+			rawConfigText(rawConfig, "public_key"),
+			rawConfigText(rawConfig, "pre_shared_key"),
+			rawConfigText(rawConfig, "reserved"),
+			rawConfigText(rawConfig, "ip"),
+			rawConfigText(rawConfig, "ipv6"),
+		}
+	case "ssr":
+		return []string{
+			rawConfigText(rawConfig, "protocol"),
+			rawConfigText(rawConfig, "protoparam"),
+			rawConfigText(rawConfig, "obfs"),
+			rawConfigText(rawConfig, "obfsparam"),
+		}
+	case "vless":
+		return []string{
+			rawConfigText(rawConfig, "flow"),
+			rawConfigText(rawConfig, "security"),
+			rawConfigText(rawConfig, "pbk", "public-key"),
+			rawConfigText(rawConfig, "sid", "short-id"),
+		}
+	case "hysteria", "hysteria2":
+		return []string{
+			rawConfigText(rawConfig, "obfs"),
+			rawConfigText(rawConfig, "obfs-password", "obfs_password"),
+			rawConfigText(rawConfig, "protocol"),
+		}
+	default:
+		return nil
+	}
+}
+
+// DeduplicateProxies 按连接标识去除重复节点，保留优先级最高的一个，
+// 输出顺序与被保留节点第一次出现的位置一致
+func DeduplicateProxies(proxies []models.Proxy, options DedupOptions) []models.Proxy {
+	if !IsValidDedupStrategy(options.Strategy) {
+		options.Strategy = DedupStrategyCustomFirst
+	}
+
+	winners := make(map[string]int, len(proxies))
+	positions := make(map[string]int, len(proxies))
+	order := make([]string, 0, len(proxies))
+
+	for i, proxy := range proxies {
+		key := ConnectionKey(proxy)
+		current, exists := winners[key]
+		if !exists {
+			winners[key] = i
+			positions[key] = len(order)
+			order = append(order, key)
+			continue
+		}
+		if dedupPrecedes(proxy, proxies[current], options) {
+			winners[key] = i
+		}
+	}
+
+	result := make([]models.Proxy, 0, len(order))
+	for _, key := range order {
+		result = append(result, proxies[winners[key]])
+	}
+	return result
+}
+
+// dedupPrecedes 判断候选节点是否应替换当前保留的节点
+func dedupPrecedes(candidate, current models.Proxy, options DedupOptions) bool {
+	switch options.Strategy {
+	case DedupStrategyFirstSeen:
+		return false
+	case DedupStrategyCustomFirst:
+		if candidate.IsCustom != current.IsCustom {
+			return candidate.IsCustom
+		}
+	case DedupStrategySubscriptionFirst:
+		if candidate.IsCustom != current.IsCustom {
+			return !candidate.IsCustom
+		}
+	}
+
+	if candidate.IsCustom || current.IsCustom {
+		return false
+	}
+	return options.Priorities[candidate.SubscriptionID] > options.Priorities[current.SubscriptionID]
+}
+
+// EnsureUniqueNames 为重名节点追加序号，Clash 等客户端不允许节点重名。
+// reserved 为配置中已被分组等占用的名称，与其同名的节点同样追加序号
+func EnsureUniqueNames(proxies []models.Proxy, reserved ...string) []models.Proxy {
+	used := make(map[string]struct{}, len(proxies)+len(reserved))
+	for _, proxy := range proxies {
+		used[proxy.Name] = struct{}{}
+	}
+
+	seen := make(map[string]struct{}, len(proxies)+len(reserved))
+	for _, name := range reserved {
+		used[name] = struct{}{}
+		seen[name] = struct{}{}
+	}
+	for i := range proxies {
+		name := proxies[i].Name
+		if _, duplicated := seen[name]; !duplicated {
+			seen[name] = struct{}{}
+			continue
+		}
+
+		for suffix := 2; ; suffix++ {
+			candidate := name + " " + strconv.Itoa(suffix)
+			if _, exists := used[candidate]; exists {
+				continue
+			}
+			proxies[i].Name = candidate
+			used[candidate] = struct{}{}
+			seen[candidate] = struct{}{}
+			break
+		}
+	}
+	return proxies
+}
+
+// parseRawConfig 解析节点的RawConfig，无效时返回空配置
+func parseRawConfig(rawConfig string) map[string]interface{} {
+	config := map[string]interface{}{}
+	if rawConfig != "" {
+		json.Unmarshal([]byte(rawConfig), &config)
+	}
+	return config
+}
+
+// rawConfigText 读取RawConfig中第一个非空的字符串字段
+func rawConfigText(config map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := config[key].(string); ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// rawConfigNumber 读取RawConfig中的整数字段，兼容数字和字符串形式
+func rawConfigNumber(config map[string]interface{}, keys ...string) int {
+	for _, key := range keys {
+		switch value := config[key].(type) {
+		case float64:
+			return int(value)
+		case string:
+			if number, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+				return number
+			}
+		}
+	}
+	return 0
+}
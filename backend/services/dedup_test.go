@@ -0,0 +1,104 @@
+package services
+
+import (
+	"testing"
+
+	"proxy-subscription/models"
+)
+
+func TestDeduplicateProxiesStrategies(t *testing.T) {
+	proxies := []models.Proxy{
+		{BaseModel: models.BaseModel{ID: 1}, SubscriptionID: 1, Name: "low", Type: "trojan", Server: "a.example.com", Port: 443, Password: "secret"},
+		{BaseModel: models.BaseModel{ID: 2}, SubscriptionID: 2, Name: "high", Type: "trojan", Server: "A.example.com", Port: 443, Password: "secret"},
+		{BaseModel: models.BaseModel{ID: 3}, IsCustom: true, Name: "custom", Type: "trojan", Server: "a.example.com", Port: 443, Password: "secret"},
+		{BaseModel: models.BaseModel{ID: 4}, SubscriptionID: 1, Name: "other", Type: "trojan", Server: "a.example.com", Port: 8443, Password: "secret"},
+	}
+	priorities := map[uint]int{1: 0, 2: 10}
+
+	tests := []struct {
+		strategy string
+		wantIDs  []uint
+	}{
+		{DedupStrategyCustomFirst, []uint{3, 4}},
+		{DedupStrategySubscriptionFirst, []uint{2, 4}},
+		{DedupStrategyFirstSeen, []uint{1, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			result := DeduplicateProxies(proxies, DedupOptions{Strategy: tt.strategy, Priorities: priorities})
+			if len(result) != len(tt.wantIDs) {
+				t.Fatalf("DeduplicateProxies() returned %d proxies, want %d", len(result), len(tt.wantIDs))
+			}
+			for i, id := range tt.wantIDs {
+				assertEqual(t, result[i].ID, id, "proxy ID")
+			}
+		})
+	}
+}
+
+func TestEnsureUniqueNames(t *testing.T) {
+	proxies := []models.Proxy{{Name: "HK"}, {Name: "HK"}, {Name: "HK 2"}, {Name: "HK"}}
+
+	result := EnsureUniqueNames(proxies)
+
+	assertEqual(t, result[0].Name, "HK", "first name")
+	assertEqual(t, result[1].Name, "HK 3", "second name")
+	assertEqual(t, result[2].Name, "HK 2", "third name")
+	assertEqual(t, result[3].Name, "HK 4", "fourth name")
+}
+
+func TestConnectionKeyRawCredentials(t *testing.T) {
+	tests := []struct {
+		name  string
+		first models.Proxy
+		other models.Proxy
+	}{
+		{
+			name:  "wireguard private key",
+			first: models.Proxy{Type: "wireguard", Server: "wg.example.com", Port: 51820, RawConfig: `{"private_key":"key-a","public_key":"peer","ip":"10.0.0.2"}`},
+			other: models.Proxy{Type: "wireguard", Server: "wg.example.com", Port: 51820, RawConfig: `{"private_key":"key-b","public_key":"peer","ip":"10.0.0.2"}`},
+		},
+		{
+			name:  "wireguard public key",
+			first: models.Proxy{Type: "wireguard", Server: "wg.example.com", Port: 51820, RawConfig: `{"private_key":"key","public_key":"peer-a"}`},
+			other: models.Proxy{Type: "wireguard", Server: "wg.example.com", Port: 51820, RawConfig: `{"private_key":"key","public_key":"peer-b"}`},
+		},
+		{
+			name:  "ssr protocol",
+			first: models.Proxy{Type: "ssr", Server: "ssr.example.com", Port: 8388, Password: "secret", Method: "aes-256-cfb", RawConfig: `{"protocol":"origin","obfs":"plain"}`},
+			other: models.Proxy{Type: "ssr", Server: "ssr.example.com", Port: 8388, Password: "secret", Method: "aes-256-cfb", RawConfig: `{"protocol":"auth_aes128_md5","obfs":"plain"}`},
+		},
+		{
+			name:  "ssr obfs param",
+			first: models.Proxy{Type: "ssr", Server: "ssr.example.com", Port: 8388, Password: "secret", Method: "aes-256-cfb", RawConfig: `{"protocol":"origin","obfs":"http_simple","obfsparam":"a.example.com"}`},
+			other: models.Proxy{Type: "ssr", Server: "ssr.example.com", Port: 8388, Password: "secret", Method: "aes-256-cfb", RawConfig: `{"protocol":"origin","obfs":"http_simple","obfsparam":"b.example.com"}`},
+		},
+		{
+			name:  "vless reality short id",
+			first: models.Proxy{Type: "vless", Server: "v.example.com", Port: 443, UUID: "id", RawConfig: `{"security":"reality","pbk":"pub","sid":"01"}`},
+			other: models.Proxy{Type: "vless", Server: "v.example.com", Port: 443, UUID: "id", RawConfig: `{"security":"reality","pbk":"pub","sid":"02"}`},
+		},
+		{
+			name:  "hysteria2 obfs password",
+			first: models.Proxy{Type: "hysteria2", Server: "h.example.com", Port: 443, Password: "auth", RawConfig: `{"obfs":"salamander","obfs-password":"a"}`},
+			other: models.Proxy{Type: "hysteria2", Server: "h.example.com", Port: 443, Password: "auth", RawConfig: `{"obfs":"salamander","obfs-password":"b"}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ConnectionKey(tt.first) == ConnectionKey(tt.other) {
+				t.Fatalf("ConnectionKey() should differ when only RawConfig credentials differ")
+			}
+			result := DeduplicateProxies([]models.Proxy{tt.first, tt.other}, DedupOptions{Strategy: DedupStrategyFirstSeen})
+			assertEqual(t, len(result), 2, "deduplicated proxies")
+		})
+	}
+
+	same := models.Proxy{Type: "wireguard", Server: "wg.example.com", Port: 51820, RawConfig: `{"private_key":"key","public_key":"peer","mtu":1280}`}
+	sameOther := same
+	sameOther.Name = "renamed"
+	sameOther.RawConfig = `{"private_key":"key","public_key":"peer","mtu":1420}`
+	assertEqual(t, ConnectionKey(same), ConnectionKey(sameOther), "key ignoring non-credential fields")
+}
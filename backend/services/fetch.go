@@ -0,0 +1,151 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// 获取订阅的超时时间（秒）
+const (
+	defaultFetchTimeout = 30
+	maxFetchTimeout     = 120
+)
+
+// maxFetchBodySize 订阅响应内容（解压后）的最大长度
+const maxFetchBodySize = 10 << 20
+
+// FetchOptions 获取订阅时的请求选项，零值表示使用默认设置
+type FetchOptions struct {
+	UserAgent string            `json:"user_agent"` // 覆盖默认的浏览器User-Agent
+	Headers   map[string]string `json:"headers"`    // 额外的请求头
+	Timeout   int               `json:"timeout"`    // 超时时间（秒），最大120
+
+	// PublicOnly 只允许连接公网地址，用于无需登录即可触发的拉取，防止借服务器访问内网。
+	// 检查在建立连接时进行，重定向后的地址同样受限
+	PublicOnly bool `json:"-"`
+}
+
+// timeout 返回有效的超时时间
+func (o FetchOptions) timeout() time.Duration {
+	seconds := o.Timeout
+	if seconds <= 0 {
+		seconds = defaultFetchTimeout
+	}
+	if seconds > maxFetchTimeout {
+		seconds = maxFetchTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// cgnatNetwork 运营商级NAT地址段，同样视为内网
+var cgnatNetwork = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPublicIP 判断地址是否为公网单播地址，回环、私有、链路本地（含云服务元数据地址）等均不是
+func isPublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil && (ip4[0] == 0 || ip4[0] >= 240 || cgnatNetwork.Contains(ip4)) {
+		return false
+	}
+	return true
+}
+
+// publicOnlyTransport 返回只能连接公网地址的Transport，在DNS解析后的实际连接地址上检查，
+// 不使用环境变量中的代理，避免绕过检查
+func publicOnlyTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublicIP(net.ParseIP(host)) {
+				return fmt.Errorf("不允许访问内网地址 %s", host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+// readLimitedBody 读取响应内容，超过maxFetchBodySize时返回错误
+func readLimitedBody(r io.Reader) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, maxFetchBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxFetchBodySize {
+		return nil, errors.New("订阅内容超过10MB")
+	}
+	return body, nil
+}
+
+// FetchResult 订阅获取结果
+type FetchResult struct {
+	Content     string
+	StatusCode  int
+	ContentType string
+	UserInfo    *SubscriptionUserInfo
+}
+
+// SubscriptionUserInfo 订阅响应头subscription-userinfo中的流量信息，单位为字节
+type SubscriptionUserInfo struct {
+	Upload   int64      `json:"upload"`
+	Download int64      `json:"download"`
+	Total    int64      `json:"total"`
+	Expire   *time.Time `json:"expire,omitempty"`
+}
+
+// ParseSubscriptionUserInfo 解析形如 "upload=1; download=2; total=3; expire=1700000000" 的流量信息，
+// 为空或没有可识别字段时返回nil
+func ParseSubscriptionUserInfo(header string) *SubscriptionUserInfo {
+	if strings.TrimSpace(header) == "" {
+		return nil
+	}
+
+	info := &SubscriptionUserInfo{}
+	recognized := false
+	for _, part := range strings.Split(header, ";") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(kv[0])) {
+		case "upload":
+			info.Upload = int64(value)
+		case "download":
+			info.Download = int64(value)
+		case "total":
+			info.Total = int64(value)
+		case "expire":
+			if value > 0 {
+				expire := time.Unix(int64(value), 0)
+				info.Expire = &expire
+			}
+		default:
+			continue
+		}
+		recognized = true
+	}
+	if !recognized {
+		return nil
+	}
+	return info
+}
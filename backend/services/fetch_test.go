@@ -0,0 +1,124 @@
+package services
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseSubscriptionUserInfo(t *testing.T) {
+	info := ParseSubscriptionUserInfo("upload=1024; download=2048; total=10737418240; expire=1735689600")
+	if info == nil {
+		t.Fatal("ParseSubscriptionUserInfo() = nil, want info")
+	}
+	assertEqual(t, info.Upload, int64(1024), "upload")
+	assertEqual(t, info.Download, int64(2048), "download")
+	assertEqual(t, info.Total, int64(10737418240), "total")
+	if info.Expire == nil || info.Expire.Unix() != 1735689600 {
+		t.Fatalf("expire = %v, want 1735689600", info.Expire)
+	}
+
+	if ParseSubscriptionUserInfo("") != nil || ParseSubscriptionUserInfo("foo=bar") != nil {
+		t.Fatal("ParseSubscriptionUserInfo() returned info for an empty or unknown header")
+	}
+}
+
+func TestFetchSubscriptionOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.UserAgent() != "clash-verge/v1.7.7" || r.Header.Get("X-Token") != "abc" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Subscription-Userinfo", "upload=1; download=2; total=3")
+		w.Write([]byte(sampleTuicLink))
+	}))
+	defer server.Close()
+
+	result, err := FetchSubscription(server.URL, FetchOptions{
+		UserAgent: "clash-verge/v1.7.7",
+		Headers:   map[string]string{"X-Token": "abc"},
+		Timeout:   5,
+	})
+	if err != nil {
+		t.Fatalf("FetchSubscription() error = %v", err)
+	}
+	assertEqual(t, result.Content, sampleTuicLink, "content")
+	if result.UserInfo == nil || result.UserInfo.Total != 3 {
+		t.Fatalf("userinfo = %+v, want total 3", result.UserInfo)
+	}
+
+	if _, err := FetchSubscription(server.URL, FetchOptions{}); err == nil {
+		t.Fatal("FetchSubscription() without the expected headers succeeded")
+	}
+}
+
+func TestFetchSubscriptionErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(strings.Repeat("blocked ", 1000)))
+	}))
+	defer server.Close()
+
+	result, err := FetchSubscription(server.URL, FetchOptions{Timeout: 5})
+	if err == nil {
+		t.Fatal("FetchSubscription() succeeded on a 403 response")
+	}
+	assertEqual(t, result.StatusCode, http.StatusForbidden, "status code")
+	assertEqual(t, result.ContentType, "text/html", "content type")
+	assertEqual(t, result.Content, "", "content")
+}
+
+func TestFetchSubscriptionBodyLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, maxFetchBodySize+1))
+	}))
+	defer server.Close()
+
+	result, err := FetchSubscription(server.URL, FetchOptions{Timeout: 5})
+	if err == nil {
+		t.Fatal("FetchSubscription() accepted a body over the size limit")
+	}
+	assertEqual(t, result.StatusCode, http.StatusOK, "status code")
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := map[string]bool{
+		"8.8.8.8":         true,
+		"203.0.113.7":     true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"255.255.255.255": false,
+		"::1":             false,
+		"fc00::1":         false,
+		"fe80::1":         false,
+		"::ffff:10.0.0.1": false,
+	}
+	for ip, want := range tests {
+		assertEqual(t, isPublicIP(net.ParseIP(ip)), want, ip)
+	}
+	assertEqual(t, isPublicIP(nil), false, "nil")
+}
+
+func TestFetchSubscriptionPublicOnly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(sampleTuicLink))
+	}))
+	defer server.Close()
+
+	if _, err := FetchSubscription(server.URL, FetchOptions{Timeout: 5}); err != nil {
+		t.Fatalf("FetchSubscription() error = %v", err)
+	}
+	_, err := FetchSubscription(server.URL, FetchOptions{Timeout: 5, PublicOnly: true})
+	if err == nil || !strings.Contains(err.Error(), "不允许访问内网地址") {
+		t.Fatalf("FetchSubscription() with PublicOnly error = %v, want loopback rejected", err)
+	}
+}
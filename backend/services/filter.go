@@ -0,0 +1,126 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"proxy-subscription/models"
+)
+
+// ValidateNameFilters 校验节点名称过滤和重命名规则中的正则表达式
+func ValidateNameFilters(include, exclude string, rules []models.RenameRule) error {
+	if _, err := compileOptionalRegexp(include); err != nil {
+		return fmt.Errorf("包含规则无效: %w", err)
+	}
+	if _, err := compileOptionalRegexp(exclude); err != nil {
+		return fmt.Errorf("排除规则无效: %w", err)
+	}
+	for i, rule := range rules {
+		if rule.Pattern == "" {
+			return fmt.Errorf("第 %d 条重命名规则缺少匹配表达式", i+1)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("第 %d 条重命名规则无效: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// FilterProxiesByName 按名称正则过滤节点，include为空表示全部包含
+func FilterProxiesByName(proxies []models.Proxy, include, exclude string) ([]models.Proxy, error) {
+	includeRe, err := compileOptionalRegexp(include)
+	if err != nil {
+		return nil, err
+	}
+	excludeRe, err := compileOptionalRegexp(exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.Proxy, 0, len(proxies))
+	for _, proxy := range proxies {
+		if includeRe != nil && !includeRe.MatchString(proxy.Name) {
+			continue
+		}
+		if excludeRe != nil && excludeRe.MatchString(proxy.Name) {
+			continue
+		}
+		result = append(result, proxy)
+	}
+	return result, nil
+}
+
+// RenameProxies 依次应用重命名规则
+func RenameProxies(proxies []models.Proxy, rules []models.RenameRule) ([]models.Proxy, error) {
+	if len(rules) == 0 {
+		return proxies, nil
+	}
+
+	compiled := make([]*regexp.Regexp, 0, len(rules))
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+
+	for i := range proxies {
+		for j, re := range compiled {
+			proxies[i].Name = re.ReplaceAllString(proxies[i].Name, expandLocationTemplate(rules[j].Replace, proxies[i]))
+		}
+	}
+	return proxies, nil
+}
+
+// expandLocationTemplate 替换重命名模板中的位置占位符：
+// {country} 国家代码、{country_name} 按显示名称语言的国家名、{flag} 国旗emoji、
+// {asn} ASN编号、{as_org} ASN组织、{ip} 服务器IP。
+// 国家优先使用GeoIP查询结果，其次使用名称推测
+func expandLocationTemplate(replace string, proxy models.Proxy) string {
+	if !strings.Contains(replace, "{") {
+		return replace
+	}
+	country := proxy.LocationCountry()
+	asn := ""
+	if proxy.GeoASN != 0 {
+		asn = strconv.FormatUint(uint64(proxy.GeoASN), 10)
+	}
+	replacer := strings.NewReplacer(
+		"{country}", escapeReplacement(country),
+		"{country_name}", escapeReplacement(models.CountryName(country, models.CurrentDisplayLocale())),
+		"{flag}", models.CountryFlag(country),
+		"{asn}", asn,
+		"{as_org}", escapeReplacement(proxy.GeoASOrg),
+		"{ip}", escapeReplacement(proxy.GeoIP),
+	)
+	return replacer.Replace(replace)
+}
+
+// AddFlagEmoji 在节点名称前添加所在国家的国旗emoji，国家优先使用GeoIP查询结果。
+// 名称中已有国旗或无法确定国家的节点保持不变
+func AddFlagEmoji(proxies []models.Proxy) []models.Proxy {
+	for i := range proxies {
+		if models.HasFlagEmoji(proxies[i].Name) {
+			continue
+		}
+		if flag := models.CountryFlag(proxies[i].LocationCountry()); flag != "" {
+			proxies[i].Name = flag + " " + proxies[i].Name
+		}
+	}
+	return proxies
+}
+
+// escapeReplacement 转义替换值中的$，避免被当作正则分组引用
+func escapeReplacement(value string) string {
+	return strings.ReplaceAll(value, "$", "$$")
+}
+
+func compileOptionalRegexp(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}
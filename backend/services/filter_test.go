@@ -0,0 +1,102 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"proxy-subscription/models"
+)
+
+func TestValidateNameFilters(t *testing.T) {
+	if err := ValidateNameFilters("", "", nil); err != nil {
+		t.Fatalf("empty filters error = %v", err)
+	}
+	if err := ValidateNameFilters("香港|HK", "过期", []models.RenameRule{{Pattern: `^\[.*?\]\s*`}}); err != nil {
+		t.Fatalf("valid filters error = %v", err)
+	}
+
+	cases := []struct {
+		include string
+		exclude string
+		rules   []models.RenameRule
+		want    string
+	}{
+		{include: "(", want: "包含规则无效"},
+		{exclude: "[a-", want: "排除规则无效"},
+		{rules: []models.RenameRule{{Pattern: "ok"}, {Replace: "x"}}, want: "第 2 条重命名规则缺少匹配表达式"},
+		{rules: []models.RenameRule{{Pattern: "(?P<"}}, want: "第 1 条重命名规则无效"},
+	}
+	for _, tc := range cases {
+		err := ValidateNameFilters(tc.include, tc.exclude, tc.rules)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("ValidateNameFilters(%q, %q, %v) error = %v, want %q", tc.include, tc.exclude, tc.rules, err, tc.want)
+		}
+	}
+}
+
+func TestFilterProxiesByName(t *testing.T) {
+	proxies := []models.Proxy{
+		{Name: "香港 01"},
+		{Name: "香港 02 过期"},
+		{Name: "日本 01"},
+		{Name: "HK 03"},
+	}
+
+	filtered, err := FilterProxiesByName(proxies, "", "")
+	if err != nil {
+		t.Fatalf("FilterProxiesByName() error = %v", err)
+	}
+	assertEqual(t, len(filtered), 4, "no filter")
+
+	filtered, err = FilterProxiesByName(proxies, "香港|HK", "过期")
+	if err != nil {
+		t.Fatalf("FilterProxiesByName() error = %v", err)
+	}
+	assertNames(t, filtered, []string{"香港 01", "HK 03"}, "include and exclude")
+
+	filtered, err = FilterProxiesByName(proxies, "", "香港")
+	if err != nil {
+		t.Fatalf("FilterProxiesByName() error = %v", err)
+	}
+	assertNames(t, filtered, []string{"日本 01", "HK 03"}, "exclude only")
+
+	filtered, err = FilterProxiesByName(proxies, "美国", "")
+	if err != nil {
+		t.Fatalf("FilterProxiesByName() error = %v", err)
+	}
+	assertEqual(t, len(filtered), 0, "empty result")
+
+	if _, err := FilterProxiesByName(proxies, "(", ""); err == nil {
+		t.Fatal("FilterProxiesByName() with invalid include should fail")
+	}
+	if _, err := FilterProxiesByName(proxies, "", "("); err == nil {
+		t.Fatal("FilterProxiesByName() with invalid exclude should fail")
+	}
+}
+
+func TestRenameProxies(t *testing.T) {
+	proxies := []models.Proxy{
+		{Name: "[机场A] 香港 01"},
+		{Name: "[机场A] 日本 02"},
+		{Name: "美国 03"},
+	}
+
+	renamed, err := RenameProxies(proxies, nil)
+	if err != nil {
+		t.Fatalf("RenameProxies() error = %v", err)
+	}
+	assertNames(t, renamed, []string{"[机场A] 香港 01", "[机场A] 日本 02", "美国 03"}, "no rules")
+
+	renamed, err = RenameProxies(proxies, []models.RenameRule{
+		{Pattern: `^\[.*?\]\s*`, Replace: ""},
+		{Pattern: `(\S+) (\d+)`, Replace: "$2-$1"},
+	})
+	if err != nil {
+		t.Fatalf("RenameProxies() error = %v", err)
+	}
+	assertNames(t, renamed, []string{"01-香港", "02-日本", "03-美国"}, "rules applied in order")
+
+	if _, err := RenameProxies(proxies, []models.RenameRule{{Pattern: "("}}); err == nil {
+		t.Fatal("RenameProxies() with invalid pattern should fail")
+	}
+}
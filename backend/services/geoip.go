@@ -0,0 +1,351 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"proxy-subscription/models"
+	"proxy-subscription/utils"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoIP数据库文件名（MaxMind MMDB格式），放在数据目录下，按顺序使用第一个存在的文件
+var (
+	geoCountryDatabaseFiles = []string{"GeoLite2-Country.mmdb", "GeoLite2-City.mmdb", "dbip-country-lite.mmdb", "Country.mmdb"}
+	geoASNDatabaseFiles     = []string{"GeoLite2-ASN.mmdb", "dbip-asn-lite.mmdb", "ASN.mmdb"}
+)
+
+const (
+	geoResolveTimeout     = 3 * time.Second // 单个域名解析超时
+	geoResolveConcurrency = 16              // 同时解析的服务器数量
+)
+
+// ErrGeoIPUnavailable 数据目录下没有可用的GeoIP数据库
+var ErrGeoIPUnavailable = errors.New("数据目录下没有GeoIP数据库文件")
+
+// geoLookupHost 解析服务器域名，测试中可替换
+var geoLookupHost = func(ctx context.Context, host string) ([]net.IP, error) {
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
+
+// geoCountryRecord 国家数据库记录，兼容Country和City数据库
+type geoCountryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+// geoASNRecord ASN数据库记录
+type geoASNRecord struct {
+	Number       uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// geoDatabase 已加载的数据库文件，文件修改后重新加载
+type geoDatabase struct {
+	path    string
+	modTime time.Time
+	reader  *maxminddb.Reader
+}
+
+var geoDatabases struct {
+	mu      sync.Mutex
+	country geoDatabase
+	asn     geoDatabase
+}
+
+// GeoIPDatabaseStatus 数据库文件信息
+type GeoIPDatabaseStatus struct {
+	File    string    `json:"file"`
+	Type    string    `json:"type"`
+	BuildAt time.Time `json:"build_at"`
+}
+
+// GeoIPStatus 当前使用的国家和ASN数据库，未找到时为空
+type GeoIPStatus struct {
+	Country *GeoIPDatabaseStatus `json:"country"`
+	ASN     *GeoIPDatabaseStatus `json:"asn"`
+}
+
+// geoReaders 一次查询使用的数据库快照
+type geoReaders struct {
+	country *maxminddb.Reader
+	asn     *maxminddb.Reader
+}
+
+// loadGeoReaders 加载数据目录下的数据库文件，文件未变化时复用已加载的数据
+func loadGeoReaders() (geoReaders, error) {
+	dataDir, err := models.DataDir()
+	if err != nil {
+		return geoReaders{}, err
+	}
+
+	geoDatabases.mu.Lock()
+	defer geoDatabases.mu.Unlock()
+	if err := reloadGeoDatabase(&geoDatabases.country, dataDir, geoCountryDatabaseFiles); err != nil {
+		return geoReaders{}, err
+	}
+	if err := reloadGeoDatabase(&geoDatabases.asn, dataDir, geoASNDatabaseFiles); err != nil {
+		return geoReaders{}, err
+	}
+	return geoReaders{country: geoDatabases.country.reader, asn: geoDatabases.asn.reader}, nil
+}
+
+// reloadGeoDatabase 查找第一个存在的数据库文件并在需要时重新加载。
+// 数据读入内存而不是映射文件，替换后旧的读取器可被仍在使用它的查询安全地继续使用
+func reloadGeoDatabase(db *geoDatabase, dataDir string, candidates []string) error {
+	for _, name := range candidates {
+		path := filepath.Join(dataDir, name)
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if db.reader != nil && db.path == path && db.modTime.Equal(info.ModTime()) {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		reader, err := maxminddb.FromBytes(content)
+		if err != nil {
+			return errors.New("GeoIP数据库文件无效 " + name + ": " + err.Error())
+		}
+		*db = geoDatabase{path: path, modTime: info.ModTime(), reader: reader}
+		utils.Info("已加载GeoIP数据库 %s (%s)", name, reader.Metadata.DatabaseType)
+		return nil
+	}
+	*db = geoDatabase{}
+	return nil
+}
+
+// GetGeoIPStatus 返回当前使用的数据库文件
+func GetGeoIPStatus() (GeoIPStatus, error) {
+	readers, err := loadGeoReaders()
+	if err != nil {
+		return GeoIPStatus{}, err
+	}
+	geoDatabases.mu.Lock()
+	defer geoDatabases.mu.Unlock()
+	return GeoIPStatus{
+		Country: geoDatabaseStatus(geoDatabases.country.path, readers.country),
+		ASN:     geoDatabaseStatus(geoDatabases.asn.path, readers.asn),
+	}, nil
+}
+
+func geoDatabaseStatus(path string, reader *maxminddb.Reader) *GeoIPDatabaseStatus {
+	if reader == nil {
+		return nil
+	}
+	return &GeoIPDatabaseStatus{
+		File:    filepath.Base(path),
+		Type:    reader.Metadata.DatabaseType,
+		BuildAt: time.Unix(int64(reader.Metadata.BuildEpoch), 0),
+	}
+}
+
+// lookup 查询IP的国家和ASN，数据库中没有记录的字段保持为空
+func (r geoReaders) lookup(ip net.IP) models.GeoLocation {
+	location := models.GeoLocation{GeoIP: ip.String()}
+	if r.country != nil {
+		var record geoCountryRecord
+		if err := r.country.Lookup(ip, &record); err == nil {
+			location.GeoCountry = record.Country.ISOCode
+			if location.GeoCountry == "" {
+				location.GeoCountry = record.RegisteredCountry.ISOCode
+			}
+		}
+	}
+	if r.asn != nil {
+		var record geoASNRecord
+		if err := r.asn.Lookup(ip, &record); err == nil {
+			location.GeoASN = record.Number
+			location.GeoASOrg = record.Organization
+		}
+	}
+	return location
+}
+
+// ApplyGeoLocations 查询节点服务器的实际位置并写入节点，域名按解析结果查询。
+// 没有可用数据库时只根据已有位置重新判断名称是否一致，返回ErrGeoIPUnavailable
+func ApplyGeoLocations(ctx context.Context, proxies []models.Proxy) error {
+	readers, err := loadGeoReaders()
+	if err != nil {
+		return err
+	}
+	if readers.country == nil && readers.asn == nil {
+		for i := range proxies {
+			proxies[i].GeoMismatch = geoMismatch(proxies[i])
+		}
+		return ErrGeoIPUnavailable
+	}
+	locateProxies(ctx, proxies, readers.lookup)
+	return nil
+}
+
+// locateProxies 以有限并发解析服务器地址并查询位置，相同服务器只查询一次
+func locateProxies(ctx context.Context, proxies []models.Proxy, lookup func(net.IP) models.GeoLocation) {
+	servers := make(map[string]models.GeoLocation)
+	for _, proxy := range proxies {
+		servers[strings.ToLower(strings.TrimSpace(proxy.Server))] = models.GeoLocation{}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, geoResolveConcurrency)
+	for server := range servers {
+		wg.Add(1)
+		go func(server string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			var location models.GeoLocation
+			if ip := resolveServerIP(ctx, server); ip != nil {
+				location = lookup(ip)
+			}
+			mu.Lock()
+			servers[server] = location
+			mu.Unlock()
+		}(server)
+	}
+	wg.Wait()
+
+	for i := range proxies {
+		proxies[i].GeoLocation = servers[strings.ToLower(strings.TrimSpace(proxies[i].Server))]
+		proxies[i].GeoMismatch = geoMismatch(proxies[i])
+	}
+}
+
+// resolveServerIP 返回服务器的IP，域名优先取IPv4解析结果，解析失败返回nil
+func resolveServerIP(ctx context.Context, server string) net.IP {
+	host := strings.Trim(server, "[]")
+	if host == "" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, geoResolveTimeout)
+	defer cancel()
+	ips, err := geoLookupHost(ctx, host)
+	if err != nil || len(ips) == 0 {
+		return nil
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip
+		}
+	}
+	return ips[0]
+}
+
+// geoMismatch 判断名称推测的国家与实际位置是否不一致，任一方未知时视为一致
+func geoMismatch(proxy models.Proxy) bool {
+	if proxy.GeoCountry == "" {
+		return false
+	}
+	nameCode := proxy.NameCountryCode()
+	return nameCode != "" && nameCode != proxy.GeoCountry
+}
+
+// RefreshGeoLocations 重新查询所有节点的实际位置，返回查到国家或ASN的节点数和节点总数
+func RefreshGeoLocations(ctx context.Context) (int, int, error) {
+	var proxies []models.Proxy
+	if err := models.DB.Find(&proxies).Error; err != nil {
+		return 0, 0, err
+	}
+	if err := ApplyGeoLocations(ctx, proxies); err != nil {
+		return 0, len(proxies), err
+	}
+	if err := SaveGeoLocations(proxies); err != nil {
+		return 0, len(proxies), err
+	}
+	InvalidateCache()
+
+	located := 0
+	for _, proxy := range proxies {
+		if proxy.GeoCountry != "" || proxy.GeoASN != 0 {
+			located++
+		}
+	}
+	return located, len(proxies), nil
+}
+
+// RefreshGeoMismatch 按当前的国家别名重新判断已定位节点的名称是否与实际位置一致，
+// 别名设置变更后调用，只更新结果发生变化的节点
+func RefreshGeoMismatch() error {
+	var proxies []models.Proxy
+	if err := models.DB.Where("geo_country <> ''").Find(&proxies).Error; err != nil {
+		return err
+	}
+	for _, proxy := range proxies {
+		mismatch := geoMismatch(proxy)
+		if mismatch == proxy.GeoMismatch {
+			continue
+		}
+		if err := models.DB.Model(&models.Proxy{}).Where("id = ?", proxy.ID).Update("geo_mismatch", mismatch).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveGeoLocations 只更新节点的位置字段
+func SaveGeoLocations(proxies []models.Proxy) error {
+	for _, proxy := range proxies {
+		if err := models.DB.Model(&models.Proxy{}).Where("id = ?", proxy.ID).Updates(map[string]interface{}{
+			"geo_ip":       proxy.GeoIP,
+			"geo_country":  proxy.GeoCountry,
+			"geo_asn":      proxy.GeoASN,
+			"geo_as_org":   proxy.GeoASOrg,
+			"geo_mismatch": proxy.GeoMismatch,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FilterProxiesByCountry 按节点所在国家过滤，优先使用GeoIP查询结果，include为空表示全部包含
+func FilterProxiesByCountry(proxies []models.Proxy, include, exclude []string) []models.Proxy {
+	if len(include) == 0 && len(exclude) == 0 {
+		return proxies
+	}
+	includeSet := countrySet(include)
+	excludeSet := countrySet(exclude)
+
+	result := make([]models.Proxy, 0, len(proxies))
+	for _, proxy := range proxies {
+		country := proxy.LocationCountry()
+		if len(includeSet) > 0 {
+			if _, ok := includeSet[country]; !ok {
+				continue
+			}
+		}
+		if _, ok := excludeSet[country]; ok && country != "" {
+			continue
+		}
+		result = append(result, proxy)
+	}
+	return result
+}
+
+func countrySet(codes []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(codes))
+	for _, code := range codes {
+		set[strings.ToUpper(strings.TrimSpace(code))] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"proxy-subscription/models"
+)
+
+func TestLocateProxies(t *testing.T) {
+	var mu sync.Mutex
+	lookups := map[string]int{}
+	originalLookup := geoLookupHost
+	geoLookupHost = func(ctx context.Context, host string) ([]net.IP, error) {
+		mu.Lock()
+		lookups[host]++
+		mu.Unlock()
+		switch host {
+		case "hk.example.com":
+			return []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("203.0.113.7")}, nil
+		case "v6.example.com":
+			return []net.IP{net.ParseIP("2001:db8::2")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	defer func() { geoLookupHost = originalLookup }()
+
+	locations := map[string]models.GeoLocation{
+		"203.0.113.7":  {GeoCountry: "JP", GeoASN: 64500, GeoASOrg: "Example Net"},
+		"198.51.100.9": {GeoCountry: "US"},
+		"2001:db8::2":  {GeoASN: 64501},
+	}
+	lookup := func(ip net.IP) models.GeoLocation {
+		location := locations[ip.String()]
+		location.GeoIP = ip.String()
+		return location
+	}
+
+	proxies := []models.Proxy{
+		{Name: "香港 01", Server: "hk.example.com"},
+		{Name: "香港 02", Server: "HK.example.com"}, // 相同服务器只解析一次
+		{Name: "美国 01", Server: "198.51.100.9"},
+		{Name: "Node 01", Server: "v6.example.com"},
+		{Name: "日本 01", Server: "missing.example.com"},
+	}
+	locateProxies(context.Background(), proxies, lookup)
+
+	assertEqual(t, lookups["hk.example.com"], 1, "hostname resolved once")
+	assertEqual(t, proxies[0].GeoIP, "203.0.113.7", "ipv4 preferred")
+	assertEqual(t, proxies[0].GeoCountry, "JP", "hk country")
+	assertEqual(t, proxies[0].GeoASN, uint(64500), "hk asn")
+	assertEqual(t, proxies[0].GeoMismatch, true, "hk name mismatch")
+	assertEqual(t, proxies[1].GeoCountry, "JP", "same server country")
+	assertEqual(t, proxies[2].GeoCountry, "US", "ip server country")
+	assertEqual(t, proxies[2].GeoMismatch, false, "us name matches")
+	assertEqual(t, proxies[3].GeoIP, "2001:db8::2", "ipv6 fallback")
+	assertEqual(t, proxies[3].GeoMismatch, false, "unknown country not mismatch")
+	assertEqual(t, proxies[4].GeoIP, "", "unresolved ip")
+	assertEqual(t, proxies[4].GeoMismatch, false, "unresolved not mismatch")
+}
+
+func TestFilterProxiesByCountry(t *testing.T) {
+	proxies := []models.Proxy{
+		{Name: "Node 01", GeoLocation: models.GeoLocation{GeoCountry: "JP"}},
+		{Name: "香港 01", GeoLocation: models.GeoLocation{GeoCountry: "US"}},
+		{Name: "香港 02"},
+		{Name: "Node 02"},
+	}
+
+	filtered := FilterProxiesByCountry(proxies, []string{"jp", "HK"}, nil)
+	assertNames(t, filtered, []string{"Node 01", "香港 02"}, "include real location")
+
+	filtered = FilterProxiesByCountry(proxies, nil, []string{"US"})
+	assertNames(t, filtered, []string{"Node 01", "香港 02", "Node 02"}, "exclude real location")
+
+	filtered = FilterProxiesByCountry(proxies, nil, nil)
+	assertEqual(t, len(filtered), 4, "no country filter")
+}
+
+func TestRenameProxiesLocationTemplate(t *testing.T) {
+	proxies := []models.Proxy{
+		{Name: "Node 01", GeoLocation: models.GeoLocation{GeoIP: "203.0.113.7", GeoCountry: "JP", GeoASN: 64500, GeoASOrg: "Cheap$Host"}},
+		{Name: "香港 01"},
+	}
+
+	renamed, err := RenameProxies(proxies, []models.RenameRule{
+		{Pattern: `^Node (\d+)$`, Replace: "{country_name} $1 AS{asn} {as_org} {ip}"},
+		{Pattern: `^香港 (\d+)$`, Replace: "{country}-$1"},
+	})
+	if err != nil {
+		t.Fatalf("RenameProxies() error = %v", err)
+	}
+	assertEqual(t, renamed[0].Name, "日本 01 AS64500 Cheap$Host 203.0.113.7", "geo template")
+	assertEqual(t, renamed[1].Name, "HK-01", "name based fallback")
+}
+
+func TestAddFlagEmoji(t *testing.T) {
+	proxies := []models.Proxy{
+		{Name: "Node 01", GeoLocation: models.GeoLocation{GeoCountry: "JP"}},
+		{Name: "香港 01"},
+		{Name: "🇺🇸 美国 01"},
+		{Name: "Node 02"},
+	}
+
+	flagged := AddFlagEmoji(proxies)
+	assertNames(t, flagged, []string{"🇯🇵 Node 01", "🇭🇰 香港 01", "🇺🇸 美国 01", "Node 02"}, "flag prefix")
+
+	renamed, err := RenameProxies([]models.Proxy{{Name: "香港 01"}}, []models.RenameRule{
+		{Pattern: `^`, Replace: "{flag} "},
+	})
+	if err != nil {
+		t.Fatalf("RenameProxies() error = %v", err)
+	}
+	assertEqual(t, renamed[0].Name, "🇭🇰 香港 01", "flag template")
+}
@@ -0,0 +1,473 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"proxy-subscription/models"
+	"proxy-subscription/utils"
+
+	"gorm.io/gorm"
+)
+
+// 健康检查默认设置
+const (
+	DefaultHealthCheckInterval    = 30 // 探测间隔（分钟）
+	DefaultHealthCheckConcurrency = 16 // 同时探测的地址数
+	DefaultHealthCheckTimeout     = 5  // 单次探测超时（秒）
+	DefaultDeadNodeThreshold      = 3  // 连续失败多少次视为失效，避免偶发失败的节点被移除
+
+	healthCheckRetentionDays = 7  // 探测记录保留天数
+	healthSummaryWindowHours = 24 // 成功率统计的时间窗口
+)
+
+// ErrHealthCheckRunning 已有健康检查正在执行
+var ErrHealthCheckRunning = errors.New("健康检查正在进行中")
+
+// udpOnlyProxyTypes 基于UDP的协议无法通过TCP连接探测
+var udpOnlyProxyTypes = map[string]bool{
+	"tuic":      true,
+	"hysteria":  true,
+	"hysteria2": true,
+	"wireguard": true,
+}
+
+// probeDial 建立TCP连接，测试中可替换
+var probeDial = func(ctx context.Context, address string) (net.Conn, error) {
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", address)
+}
+
+var healthCheckMutex sync.Mutex
+
+// ProbeOptions 探测参数
+type ProbeOptions struct {
+	Timeout         time.Duration
+	TLS             bool   // 对使用TLS的节点完成TLS握手
+	ProtocolTestURL string // 非空时对支持的节点进行协议测试
+}
+
+// ProbeResult 单个地址的探测结果
+type ProbeResult struct {
+	Endpoint   string `json:"endpoint"`
+	ProxyID    uint   `json:"proxy_id,omitempty"` // 协议测试的节点ID，TCP探测为0
+	Mode       string `json:"mode"`
+	Success    bool   `json:"success"`
+	LatencyMs  int    `json:"latency_ms"`
+	TLS        bool   `json:"tls"`
+	StatusCode int    `json:"status_code,omitempty"`
+	EgressIP   string `json:"egress_ip,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// HealthSummary 节点最近一次探测结果及统计窗口内的成功率
+type HealthSummary struct {
+	CheckedAt   time.Time `json:"checked_at"`
+	Mode        string    `json:"mode"`
+	Success     bool      `json:"success"`
+	LatencyMs   int       `json:"latency_ms"`
+	TLS         bool      `json:"tls"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	EgressIP    string    `json:"egress_ip,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Checks      int       `json:"checks"`       // 统计窗口内的探测次数
+	SuccessRate float64   `json:"success_rate"` // 统计窗口内的成功率，0-1
+}
+
+// HealthKey 返回查找节点探测结果使用的键，为nil时按探测地址查找
+type HealthKey func(proxy models.Proxy) string
+
+// of 返回节点的键
+func (key HealthKey) of(proxy models.Proxy) string {
+	if key == nil {
+		return ProbeEndpoint(proxy)
+	}
+	return key(proxy)
+}
+
+// ProbeEndpoint 返回TCP/TLS探测结果的键。同一地址上SNI不同的TLS节点握手结果可能不同
+// （证书只覆盖部分域名、REALITY只接受指定的SNI），SNI与服务器地址不同时附加在地址之后分别探测
+func ProbeEndpoint(proxy models.Proxy) string {
+	endpoint := proxy.HealthEndpoint()
+	if !proxyUsesTLS(proxy) || proxy.SNI == "" || strings.EqualFold(proxy.SNI, proxy.Server) {
+		return endpoint
+	}
+	return endpoint + "/" + strings.ToLower(proxy.SNI)
+}
+
+// healthKeyExpr 探测记录的汇总键：协议测试结果与节点的凭据相关，按节点ID汇总；TCP探测按地址汇总
+const healthKeyExpr = "CASE WHEN proxy_id > 0 THEN 'proxy:' || proxy_id ELSE endpoint END"
+
+// protocolHealthKey 协议测试结果的键，与 healthKeyExpr 一致
+func protocolHealthKey(proxyID uint) string {
+	return "proxy:" + strconv.FormatUint(uint64(proxyID), 10)
+}
+
+// CurrentHealthKey 按当前设置返回节点探测结果的键：启用协议测试时支持的节点按节点ID，其余按探测地址
+func CurrentHealthKey() HealthKey {
+	protocolTest := models.GetSetting(models.SettingProtocolTestEnabled, "false") == "true"
+	return func(proxy models.Proxy) string {
+		if protocolTest && CheckProtocolSupport(proxy) == nil {
+			return protocolHealthKey(proxy.ID)
+		}
+		return ProbeEndpoint(proxy)
+	}
+}
+
+// IsProbeSupported 判断节点能否通过TCP连接探测
+func IsProbeSupported(proxy models.Proxy) bool {
+	return !udpOnlyProxyTypes[strings.ToLower(proxy.Type)] && proxy.Server != "" && proxy.Port > 0
+}
+
+// proxyUsesTLS 判断节点是否在TCP连接上使用TLS
+func proxyUsesTLS(proxy models.Proxy) bool {
+	switch strings.ToLower(proxy.Type) {
+	case "trojan", "anytls":
+		return true
+	}
+	return proxy.TLS
+}
+
+// ProbeProxy 连接节点地址并按需完成TLS握手，SNI优先使用节点设置的SNI
+func ProbeProxy(ctx context.Context, proxy models.Proxy, options ProbeOptions) ProbeResult {
+	result := ProbeResult{Endpoint: ProbeEndpoint(proxy), Mode: ProbeModeTCP}
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	conn, err := probeDial(ctx, proxy.HealthEndpoint())
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer conn.Close()
+
+	if options.TLS && proxyUsesTLS(proxy) {
+		result.TLS = true
+		serverName := proxy.SNI
+		if serverName == "" {
+			serverName = proxy.Server
+		}
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: proxy.AllowInsecure,
+		})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			result.Error = "TLS握手失败: " + err.Error()
+			return result
+		}
+	}
+
+	result.Success = true
+	result.LatencyMs = int(time.Since(start).Milliseconds())
+	return result
+}
+
+// ProbeProxies 以有限并发探测节点，相同地址和SNI只探测一次，不支持探测的节点会被忽略
+// 设置了协议测试地址时，支持协议测试的节点改为通过节点协议访问该地址；
+// 同一地址上的节点可能使用不同的凭据，协议测试按节点分别进行
+func ProbeProxies(ctx context.Context, proxies []models.Proxy, options ProbeOptions, concurrency int) []ProbeResult {
+	if concurrency <= 0 {
+		concurrency = DefaultHealthCheckConcurrency
+	}
+
+	targets := make([]models.Proxy, 0, len(proxies))
+	seen := make(map[string]bool, len(proxies))
+	for _, proxy := range proxies {
+		if !IsProbeSupported(proxy) {
+			continue
+		}
+		key := ProbeEndpoint(proxy)
+		if options.ProtocolTestURL != "" && CheckProtocolSupport(proxy) == nil {
+			key = protocolHealthKey(proxy.ID)
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		targets = append(targets, proxy)
+	}
+
+	results := make([]ProbeResult, len(targets))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, proxy := range targets {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int, proxy models.Proxy) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			if options.ProtocolTestURL != "" && CheckProtocolSupport(proxy) == nil {
+				results[i] = ProtocolTest(ctx, proxy, options.ProtocolTestURL, options.Timeout)
+			} else {
+				results[i] = ProbeProxy(ctx, proxy, options)
+			}
+		}(i, proxy)
+	}
+	wg.Wait()
+	return results
+}
+
+// loadProbeOptions 从设置读取探测参数
+func loadProbeOptions() (ProbeOptions, int) {
+	options := ProbeOptions{
+		Timeout: time.Duration(settingInt(models.SettingHealthCheckTimeout, DefaultHealthCheckTimeout)) * time.Second,
+		TLS:     models.GetSetting(models.SettingHealthCheckTLS, "true") != "false",
+	}
+	if models.GetSetting(models.SettingProtocolTestEnabled, "false") == "true" {
+		options.ProtocolTestURL = models.GetSetting(models.SettingProtocolTestURL, DefaultProtocolTestURL)
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultHealthCheckTimeout * time.Second
+	}
+	return options, settingInt(models.SettingHealthCheckConcurrency, DefaultHealthCheckConcurrency)
+}
+
+// RunHealthChecks 探测所有节点并保存结果，同一时间只允许一次全量探测
+func RunHealthChecks() ([]ProbeResult, error) {
+	if !healthCheckMutex.TryLock() {
+		return nil, ErrHealthCheckRunning
+	}
+	defer healthCheckMutex.Unlock()
+	return runHealthChecks()
+}
+
+// StartHealthChecks 在后台执行全量探测，已有探测进行中时返回 ErrHealthCheckRunning
+func StartHealthChecks() error {
+	if !healthCheckMutex.TryLock() {
+		return ErrHealthCheckRunning
+	}
+	go func() {
+		defer healthCheckMutex.Unlock()
+		if _, err := runHealthChecks(); err != nil {
+			utils.Error("健康检查失败: %v", err)
+		}
+	}()
+	return nil
+}
+
+// runHealthChecks 执行全量探测，调用方需持有 healthCheckMutex
+func runHealthChecks() ([]ProbeResult, error) {
+	var proxies []models.Proxy
+	if err := models.DB.Find(&proxies).Error; err != nil {
+		return nil, err
+	}
+
+	options, concurrency := loadProbeOptions()
+	start := time.Now()
+	results := ProbeProxies(context.Background(), proxies, options, concurrency)
+	if err := recordProbeResults(results); err != nil {
+		return results, err
+	}
+	PruneHealthChecks()
+	// 节点可用性变化会影响失效节点的处理结果
+	InvalidateCache()
+
+	failed := 0
+	for _, result := range results {
+		if !result.Success {
+			failed++
+		}
+	}
+	utils.Info("健康检查完成，探测 %d 个地址，失败 %d 个，耗时 %v", len(results), failed, time.Since(start).Round(time.Millisecond))
+	return results, nil
+}
+
+// CheckProxyHealth 立即探测单个节点并保存结果
+func CheckProxyHealth(proxy models.Proxy) (ProbeResult, error) {
+	if !IsProbeSupported(proxy) {
+		return ProbeResult{}, errors.New("不支持通过TCP探测的节点类型: " + proxy.Type)
+	}
+	options, _ := loadProbeOptions()
+	var result ProbeResult
+	if options.ProtocolTestURL != "" && CheckProtocolSupport(proxy) == nil {
+		result = ProtocolTest(context.Background(), proxy, options.ProtocolTestURL, options.Timeout)
+	} else {
+		result = ProbeProxy(context.Background(), proxy, options)
+	}
+	if err := recordProbeResults([]ProbeResult{result}); err != nil {
+		return result, err
+	}
+	InvalidateCache()
+	return result, nil
+}
+
+// TestProxyProtocol 立即对单个节点进行协议测试并保存结果
+func TestProxyProtocol(proxy models.Proxy, testURL string) (ProbeResult, error) {
+	options, _ := loadProbeOptions()
+	result := ProtocolTest(context.Background(), proxy, testURL, options.Timeout)
+	if err := recordProbeResults([]ProbeResult{result}); err != nil {
+		return result, err
+	}
+	InvalidateCache()
+	return result, nil
+}
+
+// recordProbeResults 保存探测记录
+func recordProbeResults(results []ProbeResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	records := make([]models.HealthCheck, 0, len(results))
+	for _, result := range results {
+		records = append(records, models.HealthCheck{
+			Endpoint:   result.Endpoint,
+			ProxyID:    result.ProxyID,
+			Mode:       result.Mode,
+			Success:    result.Success,
+			LatencyMs:  result.LatencyMs,
+			TLS:        result.TLS,
+			StatusCode: result.StatusCode,
+			EgressIP:   result.EgressIP,
+			Error:      result.Error,
+		})
+	}
+	return models.DB.CreateInBatches(&records, 100).Error
+}
+
+// PruneHealthChecks 清理超过保留天数的探测记录
+func PruneHealthChecks() {
+	cutoff := time.Now().AddDate(0, 0, -healthCheckRetentionDays)
+	if err := models.DB.Where("created_at < ?", cutoff).Delete(&models.HealthCheck{}).Error; err != nil {
+		utils.Warn("清理健康检查记录失败: %v", err)
+	}
+}
+
+// LoadHealthSummaries 按探测地址（协议测试按节点ID）汇总最近一次结果和统计窗口内的成功率，键见 CurrentHealthKey
+func LoadHealthSummaries() (map[string]HealthSummary, error) {
+	var latest []models.HealthCheck
+	if err := models.DB.Where("id IN (?)", models.DB.Model(&models.HealthCheck{}).Select("MAX(id)").Group(healthKeyExpr)).
+		Find(&latest).Error; err != nil {
+		return nil, err
+	}
+
+	var stats []struct {
+		HealthKey string
+		Checks    int
+		Successes int
+	}
+	since := time.Now().Add(-healthSummaryWindowHours * time.Hour)
+	if err := models.DB.Model(&models.HealthCheck{}).
+		Select(healthKeyExpr+" AS health_key, COUNT(*) AS checks, SUM(CASE WHEN success THEN 1 ELSE 0 END) AS successes").
+		Where("created_at >= ?", since).Group("health_key").Scan(&stats).Error; err != nil {
+		return nil, err
+	}
+
+	summaries := make(map[string]HealthSummary, len(latest))
+	for _, check := range latest {
+		key := check.Endpoint
+		if check.ProxyID > 0 {
+			key = protocolHealthKey(check.ProxyID)
+		}
+		summaries[key] = HealthSummary{
+			CheckedAt:  check.CreatedAt,
+			Mode:       check.Mode,
+			Success:    check.Success,
+			LatencyMs:  check.LatencyMs,
+			TLS:        check.TLS,
+			StatusCode: check.StatusCode,
+			EgressIP:   check.EgressIP,
+			Error:      check.Error,
+		}
+	}
+	for _, stat := range stats {
+		summary, exists := summaries[stat.HealthKey]
+		if !exists || stat.Checks == 0 {
+			continue
+		}
+		summary.Checks = stat.Checks
+		summary.SuccessRate = float64(stat.Successes) / float64(stat.Checks)
+		summaries[stat.HealthKey] = summary
+	}
+	return summaries, nil
+}
+
+// HealthHistory 获取节点最近的探测记录（所在地址的TCP探测和该节点的协议测试），按时间倒序
+func HealthHistory(proxy models.Proxy, limit int) ([]models.HealthCheck, error) {
+	var checks []models.HealthCheck
+	err := models.DB.Where("(proxy_id = 0 AND endpoint = ?) OR proxy_id = ?", ProbeEndpoint(proxy), proxy.ID).
+		Order("id DESC").Limit(limit).Find(&checks).Error
+	return checks, err
+}
+
+// FailureStreaks 统计各探测地址（协议测试按节点ID）自最近一次成功以来连续失败的次数，没有失败的不包含在结果中
+func FailureStreaks() (map[string]int, error) {
+	var rows []struct {
+		HealthKey string
+		Failures  int
+	}
+	err := models.DB.Raw(`SELECT `+healthKeyExpr+` AS health_key, COUNT(*) AS failures FROM health_checks h
+		WHERE success = ? AND id > COALESCE((SELECT MAX(id) FROM health_checks s
+			WHERE s.proxy_id = h.proxy_id AND (h.proxy_id > 0 OR s.endpoint = h.endpoint) AND s.success = ?), 0)
+		GROUP BY health_key`, false, true).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	streaks := make(map[string]int, len(rows))
+	for _, row := range rows {
+		streaks[row.HealthKey] = row.Failures
+	}
+	return streaks, nil
+}
+
+// RemapHealthChecks 订阅刷新重建节点后，将协议测试记录迁移到新的节点ID，已移除节点的记录一并删除
+func RemapHealthChecks(tx *gorm.DB, oldKeys map[uint]string, newIDs map[string]uint) error {
+	if len(oldKeys) == 0 {
+		return nil
+	}
+	oldIDs := make([]uint, 0, len(oldKeys))
+	for id := range oldKeys {
+		oldIDs = append(oldIDs, id)
+	}
+
+	// 先读出全部记录再更新，新旧ID重叠时不会重复迁移
+	var checks []models.HealthCheck
+	if err := tx.Select("id", "proxy_id").Where("proxy_id IN ?", oldIDs).Find(&checks).Error; err != nil {
+		return err
+	}
+	remapped := make(map[uint][]uint)
+	for _, check := range checks {
+		newID := newIDs[oldKeys[check.ProxyID]] // 节点已移除时为0，记录删除
+		remapped[newID] = append(remapped[newID], check.ID)
+	}
+	for newID, ids := range remapped {
+		var err error
+		if newID == 0 {
+			err = tx.Where("id IN ?", ids).Delete(&models.HealthCheck{}).Error
+		} else {
+			err = tx.Model(&models.HealthCheck{}).Where("id IN ?", ids).Update("proxy_id", newID).Error
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SplitDeadProxies 按连续失败次数拆分节点，保持原有顺序；未探测过的节点视为可用
+func SplitDeadProxies(proxies []models.Proxy, streaks map[string]int, threshold int, key HealthKey) ([]models.Proxy, []models.Proxy) {
+	if threshold <= 0 {
+		threshold = DefaultDeadNodeThreshold
+	}
+
+	alive := make([]models.Proxy, 0, len(proxies))
+	dead := make([]models.Proxy, 0)
+	for _, proxy := range proxies {
+		if streaks[key.of(proxy)] >= threshold {
+			dead = append(dead, proxy)
+		} else {
+			alive = append(alive, proxy)
+		}
+	}
+	return alive, dead
+}
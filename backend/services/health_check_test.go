@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"proxy-subscription/models"
+)
+
+func listenerProxy(t *testing.T, proxyType string, address string) models.Proxy {
+	t.Helper()
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		t.Fatalf("SplitHostPort(%q) error = %v", address, err)
+	}
+	portNumber, _ := strconv.Atoi(port)
+	return models.Proxy{Name: "local", Type: proxyType, Server: host, Port: portNumber}
+}
+
+func TestProbeProxyTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	proxy := listenerProxy(t, "ss", listener.Addr().String())
+
+	result := ProbeProxy(context.Background(), proxy, ProbeOptions{Timeout: 2 * time.Second, TLS: true})
+	assertEqual(t, result.Success, true, "open port success")
+	assertEqual(t, result.TLS, false, "ss skips tls")
+	assertEqual(t, result.Endpoint, listener.Addr().String(), "endpoint")
+
+	listener.Close()
+	result = ProbeProxy(context.Background(), proxy, ProbeOptions{Timeout: 2 * time.Second})
+	assertEqual(t, result.Success, false, "closed port success")
+	assertEqual(t, result.Error != "", true, "closed port error")
+}
+
+func TestProbeProxyTLSHandshake(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	proxy := listenerProxy(t, "trojan", server.Listener.Addr().String())
+	proxy.SNI = "example.com"
+
+	// 测试服务器使用自签名证书，未允许不安全连接时握手失败
+	result := ProbeProxy(context.Background(), proxy, ProbeOptions{Timeout: 2 * time.Second, TLS: true})
+	assertEqual(t, result.Success, false, "untrusted certificate success")
+	assertEqual(t, result.TLS, true, "tls probed")
+	assertEqual(t, strings.HasPrefix(result.Error, "TLS握手失败"), true, "tls error prefix")
+
+	proxy.AllowInsecure = true
+	result = ProbeProxy(context.Background(), proxy, ProbeOptions{Timeout: 2 * time.Second, TLS: true})
+	assertEqual(t, result.Success, true, "insecure handshake success")
+
+	// 关闭TLS探测时只检查TCP连接
+	proxy.AllowInsecure = false
+	result = ProbeProxy(context.Background(), proxy, ProbeOptions{Timeout: 2 * time.Second})
+	assertEqual(t, result.Success, true, "tcp only success")
+	assertEqual(t, result.TLS, false, "tcp only tls flag")
+
+	// 非TLS端口上的握手会超时或被拒绝
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer listener.Close()
+	plain := listenerProxy(t, "vless", listener.Addr().String())
+	plain.TLS = true
+	result = ProbeProxy(context.Background(), plain, ProbeOptions{Timeout: 200 * time.Millisecond, TLS: true})
+	assertEqual(t, result.Success, false, "plain listener handshake success")
+}
+
+func TestProbeProxiesBoundedConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	active, maxActive, dials := 0, 0, 0
+	originalDial := probeDial
+	probeDial = func(ctx context.Context, address string) (net.Conn, error) {
+		mu.Lock()
+		active++
+		dials++
+		if active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+		client, server := net.Pipe()
+		server.Close()
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+		return client, nil
+	}
+	defer func() { probeDial = originalDial }()
+
+	proxies := make([]models.Proxy, 0, 12)
+	for i := 0; i < 10; i++ {
+		proxies = append(proxies, models.Proxy{Type: "vmess", Server: "node" + strconv.Itoa(i) + ".example.com", Port: 443})
+	}
+	proxies = append(proxies,
+		models.Proxy{Type: "vmess", Server: "node0.example.com", Port: 443}, // 重复地址只探测一次
+		models.Proxy{Type: "hysteria2", Server: "udp.example.com", Port: 443},
+	)
+
+	results := ProbeProxies(context.Background(), proxies, ProbeOptions{Timeout: time.Second}, 3)
+	assertEqual(t, len(results), 10, "result count")
+	assertEqual(t, dials, 10, "dial count")
+	assertEqual(t, maxActive <= 3, true, "concurrency bounded")
+	for _, result := range results {
+		assertEqual(t, result.Success, true, "probe success "+result.Endpoint)
+	}
+}
+
+func TestSplitDeadProxies(t *testing.T) {
+	proxies := []models.Proxy{
+		{Name: "flapping", Server: "a.example.com", Port: 443},
+		{Name: "dead", Server: "b.example.com", Port: 443},
+		{Name: "unchecked", Server: "c.example.com", Port: 443},
+		{Name: "dead 2", Server: "d.example.com", Port: 8443},
+	}
+	streaks := map[string]int{
+		"a.example.com:443":  1,
+		"b.example.com:443":  3,
+		"d.example.com:8443": 5,
+	}
+
+	alive, dead := SplitDeadProxies(proxies, streaks, 3, nil)
+	assertEqual(t, len(alive), 2, "alive count")
+	assertEqual(t, alive[0].Name, "flapping", "single failure kept")
+	assertEqual(t, alive[1].Name, "unchecked", "unchecked kept")
+	assertEqual(t, len(dead), 2, "dead count")
+	assertEqual(t, dead[0].Name, "dead", "dead order")
+	assertEqual(t, dead[1].Name, "dead 2", "dead order")
+
+	alive, dead = SplitDeadProxies(proxies, streaks, 1, nil)
+	assertEqual(t, len(alive), 1, "threshold 1 alive count")
+	assertEqual(t, len(dead), 3, "threshold 1 dead count")
+}
+
+func TestProbeProxiesSeparatesSNI(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	proxies := make([]models.Proxy, 0, 4)
+	for _, sni := range []string{"a.example.com", "b.example.com", "A.example.com", ""} {
+		proxy := listenerProxy(t, "trojan", server.Listener.Addr().String())
+		proxy.SNI = sni
+		proxy.AllowInsecure = true
+		proxies = append(proxies, proxy)
+	}
+	plain := listenerProxy(t, "vmess", server.Listener.Addr().String())
+	plain.SNI = "c.example.com" // 不使用TLS的节点忽略SNI
+	proxies = append(proxies, plain)
+
+	results := ProbeProxies(context.Background(), proxies, ProbeOptions{Timeout: 2 * time.Second, TLS: true}, 2)
+	endpoints := make([]string, 0, len(results))
+	for _, result := range results {
+		endpoints = append(endpoints, result.Endpoint)
+	}
+	address := server.Listener.Addr().String()
+	assertEqual(t, strings.Join(endpoints, ","), address+"/a.example.com,"+address+"/b.example.com,"+address, "probe endpoints")
+	assertEqual(t, ProbeEndpoint(proxies[2]), address+"/a.example.com", "sni key ignores case")
+	assertEqual(t, HealthKey(nil).of(proxies[1]), address+"/b.example.com", "default health key")
+}
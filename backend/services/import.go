@@ -0,0 +1,75 @@
+package services
+
+import (
+	"strings"
+
+	"proxy-subscription/models"
+)
+
+// ImportEntry 导入内容中的一个条目，解析失败时Proxy为nil
+type ImportEntry struct {
+	Line  int    // 分享链接所在行号，Clash等结构化格式为0
+	Input string // 解析失败的原始内容
+	Proxy *models.Proxy
+	Error string
+}
+
+// ParseImportContent 解析粘贴的分享链接、Base64内容或Clash片段，返回识别的格式和逐条结果。
+// 分享链接逐行解析，单行失败不影响其他行
+func ParseImportContent(content string) (string, []ImportEntry, error) {
+	content = decodeSubscriptionContent(content)
+	// 允许直接粘贴不带 proxies: 的Clash节点列表
+	if strings.HasPrefix(strings.TrimSpace(content), "- ") && !clashProxiesPattern.MatchString(content) {
+		content = "proxies:\n" + content
+	}
+
+	parser, _ := DetectParser(content)
+	if _, ok := parser.(uriParser); ok {
+		return parser.Name(), parseImportLines(content), nil
+	}
+
+	result, err := parser.Parse(content)
+	if err != nil {
+		return parser.Name(), nil, err
+	}
+	entries := make([]ImportEntry, 0, len(result.Proxies))
+	for i := range result.Proxies {
+		entries = append(entries, ImportEntry{Proxy: &result.Proxies[i]})
+	}
+	return parser.Name(), entries, nil
+}
+
+// parseImportLines 逐行解析分享链接
+func parseImportLines(content string) []ImportEntry {
+	entries := make([]ImportEntry, 0)
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		for _, link := range expandLine(line) {
+			entry := ImportEntry{Line: i + 1}
+			if uriProxyType(link) == "" {
+				entry.Input = link
+				if scheme := uriScheme(link); scheme != "" {
+					entry.Error = "不支持的链接类型: " + scheme
+				} else {
+					entry.Error = "无法识别的内容"
+				}
+				entries = append(entries, entry)
+				continue
+			}
+
+			proxy, err := parseProxyURI(link)
+			if err != nil {
+				entry.Input = link
+				entry.Error = err.Error()
+			} else {
+				entry.Proxy = &proxy
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,427 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+
+	"proxy-subscription/models"
+	"proxy-subscription/utils"
+)
+
+// ParseResult 订阅解析结果及诊断信息
+type ParseResult struct {
+	Format          string             `json:"format"`
+	Proxies         []models.Proxy     `json:"-"`
+	TotalLines      int                `json:"total_lines"`      // 非空且非注释的行数
+	RecognizedLines int                `json:"recognized_lines"` // 成功解析出节点的行数，结构化格式为节点条目数
+	Errors          []models.LineError `json:"errors"`           // 无法解析的行及原因
+	SkippedSchemes  map[string]int     `json:"skipped_schemes"`  // 不支持的链接类型及出现的行数
+}
+
+// newParseResult 创建空的解析结果
+func newParseResult(format string) ParseResult {
+	return ParseResult{
+		Format:         format,
+		Proxies:        []models.Proxy{},
+		Errors:         []models.LineError{},
+		SkippedSchemes: map[string]int{},
+	}
+}
+
+// Parser 订阅格式解析器
+type Parser interface {
+	// Name 格式名称，解析结果中记录为识别出的格式
+	Name() string
+	// Detect 返回内容属于该格式的置信度，范围0~1
+	Detect(content string) float64
+	// Parse 解析订阅内容
+	Parse(content string) (ParseResult, error)
+}
+
+// 参与自动检测的解析器（按注册顺序，同分时靠前者优先），以及按订阅类型显式指定的解析器
+var (
+	detectParsers []Parser
+	typeParsers   = make(map[string]Parser)
+)
+
+// uriSchemes 分享链接前缀到节点类型的映射
+var uriSchemes = []struct {
+	prefix    string
+	proxyType string
+}{
+	{"vmess://", "vmess"},
+	{"vless://", "vless"},
+	{"ss://", "ss"},
+	{"ssr://", "ssr"},
+	{"trojan://", "trojan"},
+	{"tuic://", "tuic"},
+	{"anytls://", "anytls"},
+	{"hysteria2://", "hysteria2"},
+	{"hy2://", "hysteria2"},
+	{"hysteria://", "hysteria"},
+	{"wireguard://", "wireguard"},
+	{"wg://", "wireguard"},
+	{"http://", "http"},
+	{"https://", "http"},
+	{"socks://", "socks"},
+	{"socks5://", "socks"},
+}
+
+var (
+	clashProxiesPattern     = regexp.MustCompile(`(?m)^proxies:\s*$`)
+	surgeProxySection       = regexp.MustCompile(`(?m)^\[Proxy\]\s*$`)
+	quantumultServerSection = regexp.MustCompile(`(?mi)^\[server_local\]\s*$`)
+	quantumultProxyLine     = regexp.MustCompile(`(?i)^(shadowsocks|vmess|vless|trojan|http|socks5)\s*=`)
+)
+
+func init() {
+	RegisterParser(uriParser{name: "uri"})
+	RegisterParser(funcParser{name: "sip008", detect: detectSIP008, parse: parseSIP008Subscription}, "sip008")
+	RegisterParser(funcParser{name: "json", detect: detectJSON, parse: parseJSONSubscription}, "json")
+	RegisterParser(funcParser{name: "clash", detect: detectClash, parse: parseClashSubscription}, "clash")
+	RegisterParser(funcParser{name: "surge", detect: detectSurge, parse: parseSurgeSubscription}, "surge")
+	RegisterParser(funcParser{name: "quantumult", detect: detectQuantumult, parse: parseQuantumultSubscription}, "quantumult")
+
+	// 指定了协议的订阅类型只接受对应的分享链接
+	typeParsers["v2ray"] = uriParser{name: "v2ray", types: []string{"vmess", "vless"}}
+	typeParsers["ss"] = uriParser{name: "ss", types: []string{"ss"}}
+	typeParsers["sip002"] = uriParser{name: "sip002", types: []string{"ss"}}
+	typeParsers["trojan"] = uriParser{name: "trojan", types: []string{"trojan"}}
+	typeParsers["tuic"] = uriParser{name: "tuic", types: []string{"tuic"}}
+	typeParsers["anytls"] = uriParser{name: "anytls", types: []string{"anytls"}}
+	typeParsers["hysteria2"] = uriParser{name: "hysteria2", types: []string{"hysteria2"}}
+}
+
+// RegisterParser 注册参与自动检测的解析器，subTypes为可显式选择该解析器的订阅类型
+func RegisterParser(parser Parser, subTypes ...string) {
+	detectParsers = append(detectParsers, parser)
+	for _, subType := range subTypes {
+		typeParsers[subType] = parser
+	}
+}
+
+// DetectParser 选出置信度最高的解析器，均无法识别时按分享链接列表处理
+func DetectParser(content string) (Parser, float64) {
+	var best Parser
+	bestScore := 0.0
+	for _, parser := range detectParsers {
+		if score := parser.Detect(content); score > bestScore {
+			best, bestScore = parser, score
+		}
+	}
+	if best == nil {
+		return uriParser{name: "uri"}, 0
+	}
+	return best, bestScore
+}
+
+// ParseSubscription 解析订阅内容，不写入数据库
+func ParseSubscription(content string, subType string) (ParseResult, error) {
+	return parseSubscriptionContent(content, subType)
+}
+
+// DetectScores 返回各自动检测解析器对订阅内容的置信度
+func DetectScores(content string) map[string]float64 {
+	content = decodeSubscriptionContent(content)
+	scores := make(map[string]float64, len(detectParsers))
+	for _, parser := range detectParsers {
+		scores[parser.Name()] = parser.Detect(content)
+	}
+	return scores
+}
+
+// parseSubscriptionContent 解析订阅内容，subType为空、auto或mixed时自动检测格式
+func parseSubscriptionContent(content string, subType string) (ParseResult, error) {
+	content = decodeSubscriptionContent(content)
+
+	parser, ok := typeParsers[subType]
+	if !ok {
+		parser, _ = DetectParser(content)
+	}
+
+	result, err := parser.Parse(content)
+	result.Format = parser.Name()
+	return result, err
+}
+
+// decodeSubscriptionContent 处理整体Base64编码（可能经过gzip压缩）的订阅内容，
+// 解码结果不像文本时保留原始内容
+func decodeSubscriptionContent(content string) string {
+	decoded, err := utils.DecodeBase64(content)
+	if err != nil || len(decoded) == 0 {
+		return content
+	}
+	decoded = gunzipIfNeeded(decoded)
+
+	decodedStr := string(decoded)
+	trimmed := strings.TrimSpace(decodedStr)
+	if printableRatio(decoded) > 0.7 || strings.Contains(decodedStr, "://") ||
+		strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		return decodedStr
+	}
+	return content
+}
+
+// gunzipIfNeeded 数据以gzip文件头（0x1f 0x8b）开头时解压，失败则返回原数据
+func gunzipIfNeeded(data []byte) []byte {
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return data
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return data
+	}
+	defer reader.Close()
+	decompressed, err := io.ReadAll(reader)
+	if err != nil || len(decompressed) == 0 {
+		return data
+	}
+	return decompressed
+}
+
+// printableRatio 计算可打印字符所占比例
+func printableRatio(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	printable := 0
+	for _, b := range data {
+		if b >= 32 && b < 127 || b == 9 || b == 10 || b == 13 {
+			printable++
+		}
+	}
+	return float64(printable) / float64(len(data))
+}
+
+// uriProxyType 返回分享链接对应的节点类型，不是已知分享链接时返回空字符串
+func uriProxyType(line string) string {
+	for _, scheme := range uriSchemes {
+		if strings.HasPrefix(line, scheme.prefix) {
+			return scheme.proxyType
+		}
+	}
+	return ""
+}
+
+// uriScheme 返回行首的URI scheme，没有时返回空字符串
+func uriScheme(line string) string {
+	index := strings.Index(line, "://")
+	if index <= 0 {
+		return ""
+	}
+	scheme := line[:index]
+	for _, r := range scheme {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '+' || r == '-' || r == '.') {
+			return ""
+		}
+	}
+	return strings.ToLower(scheme)
+}
+
+// expandLine 返回一行中包含的分享链接，整行Base64编码的链接会被解码展开
+func expandLine(line string) []string {
+	if uriScheme(line) != "" {
+		return []string{line}
+	}
+	decoded, err := utils.DecodeBase64(line)
+	if err != nil || len(decoded) == 0 {
+		return []string{line}
+	}
+	decodedStr := string(gunzipIfNeeded(decoded))
+	if !strings.Contains(decodedStr, "://") {
+		return []string{line}
+	}
+
+	links := make([]string, 0)
+	for _, link := range strings.Split(decodedStr, "\n") {
+		if link = strings.TrimSpace(link); link != "" {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// uriParser 解析每行一个分享链接的订阅，types为空表示接受所有已知类型
+type uriParser struct {
+	name  string
+	types []string
+}
+
+func (p uriParser) Name() string { return p.name }
+
+// Detect 以可识别的分享链接行所占比例作为置信度
+func (p uriParser) Detect(content string) float64 {
+	total, recognized := 0, 0
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		total++
+		for _, link := range expandLine(line) {
+			if uriProxyType(link) != "" {
+				recognized++
+				break
+			}
+		}
+		if total >= 200 {
+			break
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return 0.9 * float64(recognized) / float64(total)
+}
+
+func (p uriParser) Parse(content string) (ParseResult, error) {
+	result := newParseResult(p.name)
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		result.TotalLines++
+
+		recognized := false
+		for _, link := range expandLine(line) {
+			proxyType := uriProxyType(link)
+			if proxyType == "" {
+				if scheme := uriScheme(link); scheme != "" {
+					result.SkippedSchemes[scheme]++
+				} else {
+					result.Errors = append(result.Errors, models.LineError{Line: i + 1, Reason: "无法识别的内容"})
+				}
+				continue
+			}
+			if !p.accepts(proxyType) {
+				// 订阅类型限定了协议，其他协议的链接视为跳过
+				result.SkippedSchemes[proxyType]++
+				continue
+			}
+
+			proxy, err := parseProxyURI(link)
+			if err != nil {
+				result.Errors = append(result.Errors, models.LineError{Line: i + 1, Reason: err.Error()})
+				continue
+			}
+			result.Proxies = append(result.Proxies, proxy)
+			recognized = true
+		}
+		if recognized {
+			result.RecognizedLines++
+		}
+	}
+	return result, nil
+}
+
+func (p uriParser) accepts(proxyType string) bool {
+	if len(p.types) == 0 {
+		return true
+	}
+	for _, t := range p.types {
+		if t == proxyType {
+			return true
+		}
+	}
+	return false
+}
+
+// funcParser 将整体解析的格式函数适配为Parser
+type funcParser struct {
+	name   string
+	detect func(content string) float64
+	parse  func(content string) ([]models.Proxy, error)
+}
+
+func (p funcParser) Name() string                  { return p.name }
+func (p funcParser) Detect(content string) float64 { return p.detect(content) }
+
+func (p funcParser) Parse(content string) (ParseResult, error) {
+	result := newParseResult(p.name)
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			result.TotalLines++
+		}
+	}
+
+	proxies, err := p.parse(content)
+	if err != nil {
+		return result, err
+	}
+	if proxies != nil {
+		result.Proxies = proxies
+	}
+	result.RecognizedLines = len(result.Proxies)
+	return result, nil
+}
+
+func detectJSON(content string) float64 {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return 0
+	}
+	if json.Valid([]byte(trimmed)) {
+		return 0.9
+	}
+	return 0.2
+}
+
+func detectSIP008(content string) float64 {
+	trimmed := strings.TrimSpace(content)
+	if !strings.HasPrefix(trimmed, "{") {
+		return 0
+	}
+	var config struct {
+		Servers []json.RawMessage `json:"servers"`
+	}
+	if err := json.Unmarshal([]byte(trimmed), &config); err != nil || len(config.Servers) == 0 {
+		return 0
+	}
+	return 0.95
+}
+
+func detectClash(content string) float64 {
+	if clashProxiesPattern.MatchString(content) {
+		return 0.95
+	}
+	if strings.Contains(content, "proxies:") {
+		return 0.4
+	}
+	return 0
+}
+
+func detectSurge(content string) float64 {
+	if surgeProxySection.MatchString(content) {
+		return 0.95
+	}
+	if strings.Contains(content, "[Proxy Group]") {
+		return 0.5
+	}
+	return 0
+}
+
+func detectQuantumult(content string) float64 {
+	if quantumultServerSection.MatchString(content) {
+		return 0.95
+	}
+	total, matched := 0, 0
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		total++
+		if quantumultProxyLine.MatchString(line) {
+			matched++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return 0.85 * float64(matched) / float64(total)
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestDetectParser(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"share links", sampleVmessLink + "\n" + sampleTuicLink, "uri"},
+		{"clash without rules", "port: 7890\nproxies:\n  - name: hk\n    type: ss\n", "clash"},
+		{"surge", "[General]\nloglevel = notify\n\n[Proxy]\nHK = ss, hk.example.com, 443, encrypt-method=aes-128-gcm, password=pwd\n", "surge"},
+		{"quantumult", "[server_local]\nshadowsocks=hk.example.com:443, method=aes-128-gcm, password=pwd, tag=HK\n", "quantumult"},
+		{"sip008", `{"version":1,"servers":[{"server":"hk.example.com","server_port":443,"password":"pwd","method":"aes-128-gcm"}]}`, "sip008"},
+		{"json array", `[{"name":"hk","type":"trojan","server":"hk.example.com","port":443}]`, "json"},
+		{"plain text mentioning SERVER,", "SERVER, not a subscription\nhello world", "uri"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, _ := DetectParser(tt.content)
+			assertEqual(t, parser.Name(), tt.want, "detected parser")
+		})
+	}
+}
+
+func TestParseSubscriptionContentLineErrors(t *testing.T) {
+	encodedLine := base64.StdEncoding.EncodeToString([]byte(sampleAnyTLSLink))
+	content := sampleVmessLink + "\n\njuicity://uuid@example.com:443\nvmess://not-base64!\n" + encodedLine
+
+	result, err := parseSubscriptionContent(content, "")
+	if err != nil {
+		t.Fatalf("parseSubscriptionContent() error = %v", err)
+	}
+
+	assertEqual(t, result.Format, "uri", "format")
+	assertEqual(t, len(result.Proxies), 2, "proxy count")
+	assertEqual(t, result.Proxies[1].Type, "anytls", "base64 line proxy type")
+	assertEqual(t, result.TotalLines, 4, "total lines")
+	assertEqual(t, result.RecognizedLines, 2, "recognized lines")
+	assertEqual(t, len(result.Errors), 1, "error count")
+	assertEqual(t, result.Errors[0].Line, 4, "error line")
+	assertEqual(t, result.SkippedSchemes["juicity"], 1, "skipped juicity lines")
+}
+
+func TestParseSubscriptionContentExplicitType(t *testing.T) {
+	result, err := parseSubscriptionContent(sampleVmessLink+"\n"+sampleTuicLink, "v2ray")
+	if err != nil {
+		t.Fatalf("parseSubscriptionContent() error = %v", err)
+	}
+
+	assertEqual(t, result.Format, "v2ray", "format")
+	assertEqual(t, len(result.Proxies), 1, "proxy count")
+	assertEqual(t, len(result.Errors), 0, "error count")
+	assertEqual(t, result.SkippedSchemes["tuic"], 1, "skipped tuic lines")
+}
+
+func TestParseImportContent(t *testing.T) {
+	format, entries, err := ParseImportContent(sampleVmessLink + "\njuicity://uuid@example.com:443\n\nnot a link\n" + sampleTuicLink)
+	if err != nil {
+		t.Fatalf("ParseImportContent() error = %v", err)
+	}
+	assertEqual(t, format, "uri", "format")
+	assertEqual(t, len(entries), 4, "entry count")
+	assertEqual(t, entries[0].Proxy != nil, true, "line 1 parsed")
+	assertEqual(t, entries[1].Line, 2, "unsupported line")
+	assertEqual(t, entries[1].Error, "不支持的链接类型: juicity", "unsupported error")
+	assertEqual(t, entries[2].Line, 4, "unrecognized line")
+	assertEqual(t, entries[3].Proxy.Type, "tuic", "line 5 type")
+
+	clash := "- {name: \"hk 01\", type: trojan, server: hk.example.com, port: 443, password: secret, alpn: [h2, http/1.1]}\n" +
+		"- name: jp\n  type: ss\n  server: jp.example.com\n  port: 8388\n  cipher: aes-128-gcm\n  password: secret\n"
+	format, entries, err = ParseImportContent(clash)
+	if err != nil {
+		t.Fatalf("ParseImportContent(clash) error = %v", err)
+	}
+	assertEqual(t, format, "clash", "clash format")
+	assertEqual(t, len(entries), 2, "clash entry count")
+	assertEqual(t, entries[0].Proxy.Name, "hk 01", "flow-style name")
+	assertEqual(t, entries[0].Proxy.Port, 443, "flow-style port")
+	assertEqual(t, entries[0].Proxy.Password, "secret", "flow-style password")
+	assertEqual(t, entries[1].Proxy.Method, "aes-128-gcm", "block-style cipher")
+}
@@ -0,0 +1,438 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"proxy-subscription/models"
+)
+
+// DefaultProtocolTestURL 协议测试默认访问的地址
+const DefaultProtocolTestURL = "https://www.gstatic.com/generate_204"
+
+// 探测方式
+const (
+	ProbeModeTCP  = "tcp"  // 仅连接节点端口（及TLS握手）
+	ProbeModeHTTP = "http" // 通过节点协议访问测试地址
+)
+
+// protocolDialer 在已连接到节点的连接上完成协议握手，返回到目标地址的连接
+type protocolDialer func(conn net.Conn, proxy models.Proxy, target string) (net.Conn, error)
+
+var protocolDialers = map[string]protocolDialer{
+	"ss":     dialShadowsocks,
+	"trojan": dialTrojan,
+	"vmess":  dialVMess,
+	"vless":  dialVLESS,
+	"socks":  dialSOCKS5,
+	"http":   dialHTTPConnect,
+}
+
+// CheckProtocolSupport 判断节点能否进行协议测试，不支持时返回原因
+func CheckProtocolSupport(proxy models.Proxy) error {
+	proxyType := strings.ToLower(proxy.Type)
+	if protocolDialers[proxyType] == nil {
+		return errors.New("不支持协议测试的节点类型: " + proxy.Type)
+	}
+	if network := strings.ToLower(proxy.Network); network != "" && network != "tcp" {
+		return errors.New("协议测试暂不支持传输方式: " + proxy.Network)
+	}
+
+	rawConfig := parseRawConfig(proxy.RawConfig)
+	switch proxyType {
+	case "ss":
+		if proxy.Plugin != "" {
+			return errors.New("协议测试暂不支持Shadowsocks插件: " + proxy.Plugin)
+		}
+		if _, err := newSSCipher(proxy.Method, proxy.Password); err != nil {
+			return err
+		}
+	case "vmess":
+		if alterID := rawConfigNumber(rawConfig, "aid", "alterId"); alterID > 0 {
+			return errors.New("协议测试仅支持AEAD认证的VMess节点（alterId为0）")
+		}
+		if _, err := vmessSecurityType(vmessSecurity(rawConfig)); err != nil {
+			return err
+		}
+	case "vless":
+		if flow := rawConfigText(rawConfig, "flow"); flow != "" {
+			return errors.New("协议测试暂不支持VLESS流控: " + flow)
+		}
+		if rawConfigText(rawConfig, "security") == "reality" || rawConfig["reality-opts"] != nil {
+			return errors.New("协议测试暂不支持Reality")
+		}
+	case "trojan":
+		if flow := rawConfigText(rawConfig, "flow"); flow != "" {
+			return errors.New("协议测试暂不支持Trojan流控: " + flow)
+		}
+	}
+	return nil
+}
+
+// ProtocolTest 通过节点协议访问测试地址，记录HTTP响应耗时、状态码和出口IP，测试地址返回2xx时视为成功
+// 测试地址的响应内容为IP地址时（如 https://api.ipify.org）会记录为出口IP
+func ProtocolTest(ctx context.Context, proxy models.Proxy, testURL string, timeout time.Duration) ProbeResult {
+	result := ProbeResult{Endpoint: proxy.HealthEndpoint(), ProxyID: proxy.ID, Mode: ProbeModeHTTP, TLS: protocolUsesTLS(proxy)}
+	if err := CheckProtocolSupport(proxy); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if testURL == "" {
+		testURL = DefaultProtocolTestURL
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				return dialThroughProxy(ctx, proxy, address)
+			},
+			DisableKeepAlives: true,
+		},
+		// 只关心节点能否访问测试地址，不跟随跳转
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, testURL, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	start := time.Now()
+	response, err := client.Do(request)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer response.Body.Close()
+	result.LatencyMs = int(time.Since(start).Milliseconds())
+	result.StatusCode = response.StatusCode
+	// 被劫持或拦截时常返回跳转或错误页，只有2xx（如generate_204的204）视为成功
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		result.Error = "测试地址返回异常状态码: " + response.Status
+		return result
+	}
+	result.Success = true
+
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 64))
+	if ip := net.ParseIP(strings.TrimSpace(string(body))); ip != nil {
+		result.EgressIP = ip.String()
+	}
+	return result
+}
+
+// dialThroughProxy 连接节点并完成TLS和协议握手
+func dialThroughProxy(ctx context.Context, proxy models.Proxy, target string) (net.Conn, error) {
+	conn, err := probeDial(ctx, proxy.HealthEndpoint())
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if protocolUsesTLS(proxy) {
+		tlsConn := tls.Client(conn, proxyTLSConfig(proxy))
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("TLS握手失败: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	proxyConn, err := protocolDialers[strings.ToLower(proxy.Type)](conn, proxy, target)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return proxyConn, nil
+}
+
+// protocolUsesTLS 判断节点协议是否需要先建立TLS连接
+func protocolUsesTLS(proxy models.Proxy) bool {
+	switch strings.ToLower(proxy.Type) {
+	case "trojan":
+		return true
+	case "vmess", "vless", "http":
+		return proxy.TLS
+	}
+	return false
+}
+
+// proxyTLSConfig 按节点的SNI、ALPN和证书校验设置生成TLS配置
+func proxyTLSConfig(proxy models.Proxy) *tls.Config {
+	config := &tls.Config{
+		ServerName:         proxy.SNI,
+		InsecureSkipVerify: proxy.AllowInsecure,
+	}
+	if config.ServerName == "" {
+		config.ServerName = proxy.Server
+	}
+	for _, alpn := range strings.Split(proxy.ALPN, ",") {
+		if alpn = strings.TrimSpace(alpn); alpn != "" {
+			config.NextProtos = append(config.NextProtos, alpn)
+		}
+	}
+	return config
+}
+
+// dialTrojan 发送Trojan请求头：hex(SHA224(密码)) CRLF CMD 地址 CRLF
+func dialTrojan(conn net.Conn, proxy models.Proxy, target string) (net.Conn, error) {
+	address, err := socksAddress(target)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum224([]byte(proxy.Password))
+	header := make([]byte, 0, 56+4+len(address)+1)
+	header = append(header, hex.EncodeToString(hash[:])...)
+	header = append(header, '\r', '\n', 0x01)
+	header = append(header, address...)
+	header = append(header, '\r', '\n')
+	if _, err := conn.Write(header); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// dialVLESS 发送VLESS请求头，响应头在首次读取时跳过
+func dialVLESS(conn net.Conn, proxy models.Proxy, target string) (net.Conn, error) {
+	id, err := parseUUID(proxy.UUID)
+	if err != nil {
+		return nil, err
+	}
+	address, err := vAddress(target)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 0, 19+len(address))
+	header = append(header, 0x00)
+	header = append(header, id[:]...)
+	header = append(header, 0x00, 0x01) // 无附加信息，TCP命令
+	header = append(header, address...)
+	if _, err := conn.Write(header); err != nil {
+		return nil, err
+	}
+	return &vlessConn{Conn: conn}, nil
+}
+
+// vlessConn 读取数据前先跳过VLESS响应头（版本、附加信息长度及内容）
+type vlessConn struct {
+	net.Conn
+	headerRead bool
+}
+
+func (c *vlessConn) Read(b []byte) (int, error) {
+	if !c.headerRead {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(c.Conn, header); err != nil {
+			return 0, err
+		}
+		if header[1] > 0 {
+			if _, err := io.CopyN(io.Discard, c.Conn, int64(header[1])); err != nil {
+				return 0, err
+			}
+		}
+		c.headerRead = true
+	}
+	return c.Conn.Read(b)
+}
+
+// dialSOCKS5 完成SOCKS5握手，设置了用户名时使用用户名/密码认证
+func dialSOCKS5(conn net.Conn, proxy models.Proxy, target string) (net.Conn, error) {
+	address, err := socksAddress(target)
+	if err != nil {
+		return nil, err
+	}
+	username := rawConfigText(parseRawConfig(proxy.RawConfig), "username")
+
+	method := byte(0x00)
+	if username != "" {
+		method = 0x02
+	}
+	if _, err := conn.Write([]byte{0x05, 0x01, method}); err != nil {
+		return nil, err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, err
+	}
+	if reply[0] != 0x05 || reply[1] != method {
+		return nil, errors.New("SOCKS5服务器不接受认证方式")
+	}
+	if method == 0x02 {
+		if len(username) > 255 || len(proxy.Password) > 255 {
+			return nil, errors.New("SOCKS5用户名或密码过长")
+		}
+		auth := []byte{0x01, byte(len(username))}
+		auth = append(auth, username...)
+		auth = append(auth, byte(len(proxy.Password)))
+		auth = append(auth, proxy.Password...)
+		if _, err := conn.Write(auth); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return nil, err
+		}
+		if reply[1] != 0x00 {
+			return nil, errors.New("SOCKS5认证失败")
+		}
+	}
+
+	request := append([]byte{0x05, 0x01, 0x00}, address...)
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	if header[1] != 0x00 {
+		return nil, fmt.Errorf("SOCKS5连接失败，错误码: %d", header[1])
+	}
+	// 跳过绑定地址和端口
+	var skip int64
+	switch header[3] {
+	case 0x01:
+		skip = net.IPv4len + 2
+	case 0x04:
+		skip = net.IPv6len + 2
+	case 0x03:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return nil, err
+		}
+		skip = int64(length[0]) + 2
+	default:
+		return nil, errors.New("SOCKS5响应地址类型无效")
+	}
+	if _, err := io.CopyN(io.Discard, conn, skip); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// dialHTTPConnect 通过HTTP CONNECT建立隧道
+func dialHTTPConnect(conn net.Conn, proxy models.Proxy, target string) (net.Conn, error) {
+	request := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: target},
+		Host:   target,
+		Header: make(http.Header),
+	}
+	if username := rawConfigText(parseRawConfig(proxy.RawConfig), "username"); username != "" || proxy.Password != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + proxy.Password))
+		request.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := request.Write(conn); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		return nil, err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New("HTTP代理CONNECT失败: " + response.Status)
+	}
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn 先读取握手时多读入缓冲区的数据
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// socksAddress 将 host:port 编码为SOCKS5地址格式（ATYP、地址、端口），Shadowsocks和Trojan同样使用该格式
+func socksAddress(target string) ([]byte, error) {
+	host, port, err := splitTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	var address []byte
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			address = append([]byte{0x01}, ip4...)
+		} else {
+			address = append([]byte{0x04}, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return nil, errors.New("域名过长: " + host)
+		}
+		address = append([]byte{0x03, byte(len(host))}, host...)
+	}
+	return binary.BigEndian.AppendUint16(address, port), nil
+}
+
+// vAddress 将 host:port 编码为VMess/VLESS地址格式（端口、ATYP、地址）
+func vAddress(target string) ([]byte, error) {
+	host, port, err := splitTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	address := binary.BigEndian.AppendUint16(nil, port)
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return append(append(address, 0x01), ip4...), nil
+		}
+		return append(append(address, 0x03), ip.To16()...), nil
+	}
+	if len(host) > 255 {
+		return nil, errors.New("域名过长: " + host)
+	}
+	return append(append(address, 0x02, byte(len(host))), host...), nil
+}
+
+// splitTarget 拆分目标地址
+func splitTarget(target string) (string, uint16, error) {
+	host, portText, err := net.SplitHostPort(target)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.ParseUint(portText, 10, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("无效的端口: %s", portText)
+	}
+	return host, uint16(port), nil
+}
+
+// parseUUID 解析带或不带连字符的UUID
+func parseUUID(value string) ([16]byte, error) {
+	var id [16]byte
+	decoded, err := hex.DecodeString(strings.ReplaceAll(strings.TrimSpace(value), "-", ""))
+	if err != nil || len(decoded) != len(id) {
+		return id, errors.New("无效的UUID: " + value)
+	}
+	copy(id[:], decoded)
+	return id, nil
+}
@@ -0,0 +1,577 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"proxy-subscription/models"
+)
+
+const (
+	testUUID     = "b831381d-6324-4d53-ad4f-8cda48b30811"
+	testPassword = "secret"
+	testEgressIP = "203.0.113.9"
+)
+
+// standInHandshake 完成服务端握手，返回与客户端通信的数据流和目标地址
+type standInHandshake func(conn net.Conn) (io.ReadWriter, string, error)
+
+// startStandIn 启动协议替身服务器，握手后连接目标地址并双向转发
+func startStandIn(t *testing.T, listener net.Listener, handshake standInHandshake) {
+	t.Helper()
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(5 * time.Second))
+				stream, target, err := handshake(conn)
+				if err != nil {
+					return
+				}
+				upstream, err := net.Dial("tcp", target)
+				if err != nil {
+					return
+				}
+				defer upstream.Close()
+				go func() {
+					io.Copy(upstream, stream)
+					upstream.Close()
+				}()
+				io.Copy(stream, upstream)
+			}()
+		}
+	}()
+}
+
+// readSocksAddress 读取SOCKS5格式的地址
+func readSocksAddress(r io.Reader) (string, error) {
+	atyp := make([]byte, 1)
+	if _, err := io.ReadFull(r, atyp); err != nil {
+		return "", err
+	}
+	var host string
+	switch atyp[0] {
+	case 0x01, 0x04:
+		ip := make([]byte, net.IPv4len)
+		if atyp[0] == 0x04 {
+			ip = make([]byte, net.IPv6len)
+		}
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case 0x03:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(r, length); err != nil {
+			return "", err
+		}
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(r, domain); err != nil {
+			return "", err
+		}
+		host = string(domain)
+	default:
+		return "", errors.New("invalid address type")
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(r, port); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// readVAddress 读取VMess/VLESS格式的地址
+func readVAddress(r io.Reader) (string, error) {
+	header := make([]byte, 3)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", err
+	}
+	port := binary.BigEndian.Uint16(header)
+	var host string
+	switch header[2] {
+	case 0x01, 0x03:
+		ip := make([]byte, net.IPv4len)
+		if header[2] == 0x03 {
+			ip = make([]byte, net.IPv6len)
+		}
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case 0x02:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(r, length); err != nil {
+			return "", err
+		}
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(r, domain); err != nil {
+			return "", err
+		}
+		host = string(domain)
+	default:
+		return "", errors.New("invalid address type")
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(port))), nil
+}
+
+func socksStandIn(conn net.Conn) (io.ReadWriter, string, error) {
+	greeting := make([]byte, 2)
+	if _, err := io.ReadFull(conn, greeting); err != nil {
+		return nil, "", err
+	}
+	if _, err := io.CopyN(io.Discard, conn, int64(greeting[1])); err != nil {
+		return nil, "", err
+	}
+	conn.Write([]byte{0x05, 0x02})
+
+	auth := make([]byte, 2)
+	if _, err := io.ReadFull(conn, auth); err != nil {
+		return nil, "", err
+	}
+	username := make([]byte, auth[1])
+	io.ReadFull(conn, username)
+	length := make([]byte, 1)
+	io.ReadFull(conn, length)
+	password := make([]byte, length[0])
+	io.ReadFull(conn, password)
+	if string(username) != "user" || string(password) != testPassword {
+		conn.Write([]byte{0x01, 0x01})
+		return nil, "", errors.New("auth failed")
+	}
+	conn.Write([]byte{0x01, 0x00})
+
+	request := make([]byte, 3)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return nil, "", err
+	}
+	target, err := readSocksAddress(conn)
+	if err != nil {
+		return nil, "", err
+	}
+	conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+	return conn, target, nil
+}
+
+func httpConnectStandIn(conn net.Conn) (io.ReadWriter, string, error) {
+	request, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil {
+		return nil, "", err
+	}
+	username, password, ok := (&http.Request{Header: http.Header{"Authorization": request.Header["Proxy-Authorization"]}}).BasicAuth()
+	if request.Method != http.MethodConnect || !ok || username != "user" || password != testPassword {
+		io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+		return nil, "", errors.New("auth failed")
+	}
+	io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+	return conn, request.Host, nil
+}
+
+func shadowsocksStandIn(method string) standInHandshake {
+	return func(conn net.Conn) (io.ReadWriter, string, error) {
+		ssc, err := newSSCipher(method, testPassword)
+		if err != nil {
+			return nil, "", err
+		}
+		stream := newSSConn(conn, ssc)
+		target, err := readSocksAddress(stream)
+		return stream, target, err
+	}
+}
+
+func trojanStandIn(conn net.Conn) (io.ReadWriter, string, error) {
+	hash := sha256.Sum224([]byte(testPassword))
+	header := make([]byte, 56+2+1)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, "", err
+	}
+	if string(header[:56]) != hex.EncodeToString(hash[:]) || header[58] != 0x01 {
+		return nil, "", errors.New("auth failed")
+	}
+	target, err := readSocksAddress(conn)
+	if err != nil {
+		return nil, "", err
+	}
+	_, err = io.CopyN(io.Discard, conn, 2)
+	return conn, target, err
+}
+
+func vlessStandIn(conn net.Conn) (io.ReadWriter, string, error) {
+	id, _ := parseUUID(testUUID)
+	header := make([]byte, 1+16+1+1)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, "", err
+	}
+	if string(header[1:17]) != string(id[:]) || header[18] != 0x01 {
+		return nil, "", errors.New("invalid user")
+	}
+	target, err := readVAddress(conn)
+	if err != nil {
+		return nil, "", err
+	}
+	conn.Write([]byte{0x00, 0x00})
+	return conn, target, nil
+}
+
+// vmessStream 服务端VMess数据流
+type vmessStream struct {
+	io.Reader
+	io.Writer
+}
+
+func vmessStandIn(conn net.Conn) (io.ReadWriter, string, error) {
+	id, _ := parseUUID(testUUID)
+	cmdKey := vmessCmdKey(id)
+
+	prefix := make([]byte, 16+18+8)
+	if _, err := io.ReadFull(conn, prefix); err != nil {
+		return nil, "", err
+	}
+	authID, sealedLength, nonce := prefix[:16], prefix[16:34], prefix[34:]
+
+	block, _ := aes.NewCipher(vmessKDF(cmdKey, "AES Auth ID Encryption")[:16])
+	plainAuthID := make([]byte, 16)
+	block.Decrypt(plainAuthID, authID)
+	if time.Since(time.Unix(int64(binary.BigEndian.Uint64(plainAuthID)), 0)).Abs() > 2*time.Minute {
+		return nil, "", errors.New("invalid auth id")
+	}
+
+	lengthAEAD, _ := newAESGCM(vmessKDF(cmdKey, "VMess Header AEAD Key_Length", string(authID), string(nonce))[:16])
+	length, err := lengthAEAD.Open(nil, vmessKDF(cmdKey, "VMess Header AEAD Nonce_Length", string(authID), string(nonce))[:12], sealedLength, authID)
+	if err != nil {
+		return nil, "", err
+	}
+	headerAEAD, _ := newAESGCM(vmessKDF(cmdKey, "VMess Header AEAD Key", string(authID), string(nonce))[:16])
+	sealedHeader := make([]byte, int(binary.BigEndian.Uint16(length))+headerAEAD.Overhead())
+	if _, err := io.ReadFull(conn, sealedHeader); err != nil {
+		return nil, "", err
+	}
+	header, err := headerAEAD.Open(nil, vmessKDF(cmdKey, "VMess Header AEAD Nonce", string(authID), string(nonce))[:12], sealedHeader, authID)
+	if err != nil {
+		return nil, "", err
+	}
+	checksum := fnv.New32a()
+	checksum.Write(header[:len(header)-4])
+	if binary.BigEndian.Uint32(header[len(header)-4:]) != checksum.Sum32() {
+		return nil, "", errors.New("invalid checksum")
+	}
+	target, err := readVAddress(strings.NewReader(string(header[38 : len(header)-4])))
+	if err != nil {
+		return nil, "", err
+	}
+
+	security := header[35] & 0x0F
+	requestAEAD, _ := vmessBodyAEAD(security, header[17:33])
+	responseKey := sha256.Sum256(header[17:33])
+	responseIV := sha256.Sum256(header[1:17])
+	responseAEAD, _ := vmessBodyAEAD(security, responseKey[:16])
+
+	// 响应头：响应认证、选项、指令、指令长度
+	responseLengthAEAD, _ := newAESGCM(vmessKDF(responseKey[:16], "AEAD Resp Header Len Key")[:16])
+	responseHeaderAEAD, _ := newAESGCM(vmessKDF(responseKey[:16], "AEAD Resp Header Key")[:16])
+	response := responseLengthAEAD.Seal(nil, vmessKDF(responseIV[:16], "AEAD Resp Header Len IV")[:12], []byte{0x00, 0x04}, nil)
+	response = responseHeaderAEAD.Seal(response, vmessKDF(responseIV[:16], "AEAD Resp Header IV")[:12], []byte{header[33], 0x00, 0x00, 0x00}, nil)
+	if _, err := conn.Write(response); err != nil {
+		return nil, "", err
+	}
+
+	return vmessStream{
+		Reader: newVMessChunkReader(conn, requestAEAD, header[1:17]),
+		Writer: newVMessChunkWriter(conn, responseAEAD, responseIV[:16]),
+	}, target, nil
+}
+
+func newEgressServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, testEgressIP+"\n")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTLSListener(t *testing.T) net.Listener {
+	t.Helper()
+	server := httptest.NewUnstartedServer(nil)
+	server.StartTLS()
+	certificate := server.TLS.Certificates[0]
+	server.Close()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{certificate}})
+	if err != nil {
+		t.Fatalf("tls.Listen() error = %v", err)
+	}
+	return listener
+}
+
+func newTCPListener(t *testing.T) net.Listener {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	return listener
+}
+
+func TestProtocolTestStandInServers(t *testing.T) {
+	egress := newEgressServer(t)
+
+	tests := []struct {
+		name      string
+		proxy     models.Proxy
+		tls       bool
+		handshake standInHandshake
+	}{
+		{"ss aes-256-gcm", models.Proxy{Type: "ss", Method: "aes-256-gcm", Password: testPassword}, false, shadowsocksStandIn("aes-256-gcm")},
+		{"ss chacha20", models.Proxy{Type: "ss", Method: "chacha20-ietf-poly1305", Password: testPassword}, false, shadowsocksStandIn("chacha20-ietf-poly1305")},
+		{"trojan", models.Proxy{Type: "trojan", Password: testPassword, SNI: "example.com", AllowInsecure: true}, true, trojanStandIn},
+		{"vless", models.Proxy{Type: "vless", UUID: testUUID, Network: "tcp"}, false, vlessStandIn},
+		{"vless tls", models.Proxy{Type: "vless", UUID: testUUID, TLS: true, AllowInsecure: true}, true, vlessStandIn},
+		{"vmess aes-128-gcm", models.Proxy{Type: "vmess", UUID: testUUID, RawConfig: `{"aid":"0","scy":"auto"}`}, false, vmessStandIn},
+		{"vmess chacha20", models.Proxy{Type: "vmess", UUID: testUUID, RawConfig: `{"alterId":0,"cipher":"chacha20-poly1305"}`}, false, vmessStandIn},
+		{"vmess none tls", models.Proxy{Type: "vmess", UUID: testUUID, TLS: true, AllowInsecure: true, RawConfig: `{"scy":"none"}`}, true, vmessStandIn},
+		{"socks", models.Proxy{Type: "socks", Password: testPassword, RawConfig: `{"username":"user"}`}, false, socksStandIn},
+		{"http", models.Proxy{Type: "http", Password: testPassword, RawConfig: `{"username":"user"}`}, false, httpConnectStandIn},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener := newTCPListener(t)
+			if tt.tls {
+				listener.Close()
+				listener = newTLSListener(t)
+			}
+			startStandIn(t, listener, tt.handshake)
+			proxy := listenerProxy(t, tt.proxy.Type, listener.Addr().String())
+			proxy.Method, proxy.Password, proxy.UUID, proxy.Network = tt.proxy.Method, tt.proxy.Password, tt.proxy.UUID, tt.proxy.Network
+			proxy.TLS, proxy.SNI, proxy.AllowInsecure, proxy.RawConfig = tt.proxy.TLS, tt.proxy.SNI, tt.proxy.AllowInsecure, tt.proxy.RawConfig
+
+			result := ProtocolTest(context.Background(), proxy, egress.URL, 3*time.Second)
+			if !result.Success {
+				t.Fatalf("ProtocolTest() error = %s", result.Error)
+			}
+			assertEqual(t, result.Mode, ProbeModeHTTP, "mode")
+			assertEqual(t, result.StatusCode, http.StatusOK, "status code")
+			assertEqual(t, result.EgressIP, testEgressIP, "egress ip")
+			assertEqual(t, result.TLS, tt.tls, "tls")
+		})
+	}
+}
+
+// 已知答案由独立实现计算：EVP_BytesToKey和HKDF-SHA1取自OpenSSL（openssl enc -md md5 -P、openssl kdf HKDF），
+// VMess KDF取自Python标准库hmac的嵌套实现，认证ID取自 openssl enc -aes-128-ecb
+func TestShadowsocksKnownAnswers(t *testing.T) {
+	sequence := func(n int) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte(i)
+		}
+		return b
+	}
+
+	tests := []struct {
+		method string
+		key    string
+		subkey string
+	}{
+		{"aes-128-gcm", "5ebe2294ecd0e0f08eab7690d2a6ee69", "fd63388ff0e5d89dcd47a01e32f33783"},
+		{"aes-256-gcm", "5ebe2294ecd0e0f08eab7690d2a6ee6926ae5cc854e36b6bdfca366848dea6bb", "556c9aa6723717689c29669ee6bcc4033a3ce613f858fd90f4b3350e2ee00edc"},
+	}
+	for _, tt := range tests {
+		ssc, err := newSSCipher(tt.method, testPassword)
+		if err != nil {
+			t.Fatalf("newSSCipher(%s) error = %v", tt.method, err)
+		}
+		assertEqual(t, hex.EncodeToString(ssc.key), tt.key, tt.method+" master key")
+		subkey, err := ssc.subkey(sequence(len(ssc.key)))
+		if err != nil {
+			t.Fatalf("subkey(%s) error = %v", tt.method, err)
+		}
+		assertEqual(t, hex.EncodeToString(subkey), tt.subkey, tt.method+" subkey")
+	}
+}
+
+func TestVMessKnownAnswers(t *testing.T) {
+	id, _ := parseUUID(testUUID)
+	cmdKey := vmessCmdKey(id)
+	assertEqual(t, hex.EncodeToString(cmdKey), "b50d916ac0cec067981af8e5f38a758f", "cmd key")
+
+	headerAuthID := []byte("\x00\x01\x02\x03\x04\x05\x06\x07\x08\x09\x0a\x0b\x0c\x0d\x0e\x0f")
+	nonce := headerAuthID[:8]
+	assertEqual(t, hex.EncodeToString(vmessKDF(cmdKey)), "1e3858c2acb5e5338a1569aac055c295a0c0e2738b2d941c4bf461cdc363efb4", "kdf without path")
+	assertEqual(t, hex.EncodeToString(vmessKDF(cmdKey, "AES Auth ID Encryption")), "1415ba74ca8b3d041a8f583fb4116315c589ae7b6e81765b601aa166c62871f7", "auth id key")
+	assertEqual(t, hex.EncodeToString(vmessKDF(cmdKey, "VMess Header AEAD Key_Length", string(headerAuthID), string(nonce))), "e6e3dfda1dad7338c6454d172ca8ac0a89bcc0fdb7c3d3a33398898595617474", "header length key")
+
+	authID, err := newVMessAuthID(cmdKey, time.Unix(1700000000, 0), []byte{1, 2, 3, 4})
+	if err != nil {
+		t.Fatalf("newVMessAuthID() error = %v", err)
+	}
+	assertEqual(t, hex.EncodeToString(authID), "4774fe5cc901ea4f81f2159909767a36", "auth id")
+}
+
+func TestProtocolTestRequiresSuccessStatus(t *testing.T) {
+	for _, status := range []int{http.StatusFound, http.StatusForbidden, http.StatusServiceUnavailable} {
+		egress := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Location", "http://portal.example.com/")
+			w.WriteHeader(status)
+		}))
+		t.Cleanup(egress.Close)
+
+		listener := newTCPListener(t)
+		startStandIn(t, listener, shadowsocksStandIn("aes-128-gcm"))
+		proxy := listenerProxy(t, "ss", listener.Addr().String())
+		proxy.Method, proxy.Password = "aes-128-gcm", testPassword
+
+		result := ProtocolTest(context.Background(), proxy, egress.URL, 3*time.Second)
+		assertEqual(t, result.Success, false, "success with status "+strconv.Itoa(status))
+		assertEqual(t, result.StatusCode, status, "status code")
+	}
+
+	noContent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(noContent.Close)
+	listener := newTCPListener(t)
+	startStandIn(t, listener, shadowsocksStandIn("aes-128-gcm"))
+	proxy := listenerProxy(t, "ss", listener.Addr().String())
+	proxy.Method, proxy.Password = "aes-128-gcm", testPassword
+	result := ProtocolTest(context.Background(), proxy, noContent.URL, 3*time.Second)
+	assertEqual(t, result.Success, true, "204 success")
+}
+
+func TestProtocolResultsKeyedByProxy(t *testing.T) {
+	setupTestDB(t)
+	egress := newEgressServer(t)
+
+	listener := newTCPListener(t)
+	startStandIn(t, listener, shadowsocksStandIn("aes-128-gcm"))
+	valid := listenerProxy(t, "ss", listener.Addr().String())
+	valid.ID, valid.Method, valid.Password = 1, "aes-128-gcm", testPassword
+	wrong := valid
+	wrong.ID, wrong.Password = 2, "wrong"
+
+	// 同一地址上的两个节点凭据不同，需分别测试
+	results := ProbeProxies(context.Background(), []models.Proxy{valid, wrong}, ProbeOptions{Timeout: 3 * time.Second, ProtocolTestURL: egress.URL}, 2)
+	assertEqual(t, len(results), 2, "result count")
+	assertEqual(t, results[0].ProxyID, uint(1), "first proxy id")
+	assertEqual(t, results[0].Success, true, "valid proxy success")
+	assertEqual(t, results[1].ProxyID, uint(2), "second proxy id")
+	assertEqual(t, results[1].Success, false, "wrong password success")
+
+	// 地址上的TCP探测独立汇总，不影响协议测试结果
+	tcp := ProbeResult{Endpoint: valid.HealthEndpoint(), Mode: ProbeModeTCP, Success: true}
+	if err := recordProbeResults(append(results, tcp)); err != nil {
+		t.Fatalf("recordProbeResults() error = %v", err)
+	}
+	if err := models.DB.Create(&models.Setting{Key: models.SettingProtocolTestEnabled, Value: "true"}).Error; err != nil {
+		t.Fatalf("create setting error = %v", err)
+	}
+	key := CurrentHealthKey()
+
+	summaries, err := LoadHealthSummaries()
+	if err != nil {
+		t.Fatalf("LoadHealthSummaries() error = %v", err)
+	}
+	assertEqual(t, summaries[key(valid)].Success, true, "valid summary")
+	assertEqual(t, summaries[key(valid)].Mode, ProbeModeHTTP, "valid summary mode")
+	assertEqual(t, summaries[key(wrong)].Success, false, "wrong summary")
+	assertEqual(t, summaries[valid.HealthEndpoint()].Mode, ProbeModeTCP, "endpoint summary mode")
+
+	streaks, err := FailureStreaks()
+	if err != nil {
+		t.Fatalf("FailureStreaks() error = %v", err)
+	}
+	alive, dead := SplitDeadProxies([]models.Proxy{valid, wrong}, streaks, 1, key)
+	assertEqual(t, len(alive), 1, "alive count")
+	assertEqual(t, alive[0].ID, uint(1), "alive proxy")
+	assertEqual(t, len(dead), 1, "dead count")
+	assertEqual(t, dead[0].ID, uint(2), "dead proxy")
+
+	history, err := HealthHistory(wrong, 10)
+	if err != nil {
+		t.Fatalf("HealthHistory() error = %v", err)
+	}
+	assertEqual(t, len(history), 2, "history includes endpoint tcp check and own protocol test")
+}
+
+func TestRemapHealthChecks(t *testing.T) {
+	setupTestDB(t)
+	records := []models.HealthCheck{
+		{Endpoint: "a.example.com:443", ProxyID: 5, Mode: ProbeModeHTTP, Success: true},
+		{Endpoint: "b.example.com:443", ProxyID: 7, Mode: ProbeModeHTTP},
+		{Endpoint: "c.example.com:443", ProxyID: 9, Mode: ProbeModeHTTP},
+		{Endpoint: "a.example.com:443", Mode: ProbeModeTCP, Success: true},
+	}
+	if err := models.DB.Create(&records).Error; err != nil {
+		t.Fatalf("create health checks error = %v", err)
+	}
+
+	// 新旧ID互换时也只迁移一次，已移除的节点记录被删除
+	oldKeys := map[uint]string{5: "a", 7: "b", 9: "c"}
+	newIDs := map[string]uint{"a": 7, "b": 5}
+	if err := RemapHealthChecks(models.DB, oldKeys, newIDs); err != nil {
+		t.Fatalf("RemapHealthChecks() error = %v", err)
+	}
+
+	var checks []models.HealthCheck
+	if err := models.DB.Order("id").Find(&checks).Error; err != nil {
+		t.Fatalf("load health checks error = %v", err)
+	}
+	assertEqual(t, len(checks), 3, "remaining checks")
+	assertEqual(t, checks[0].ProxyID, uint(7), "a remapped")
+	assertEqual(t, checks[1].ProxyID, uint(5), "b remapped")
+	assertEqual(t, checks[2].ProxyID, uint(0), "tcp check untouched")
+}
+
+func TestProtocolTestFailures(t *testing.T) {
+	egress := newEgressServer(t)
+
+	listener := newTCPListener(t)
+	startStandIn(t, listener, shadowsocksStandIn("aes-128-gcm"))
+	proxy := listenerProxy(t, "ss", listener.Addr().String())
+	proxy.Method, proxy.Password = "aes-128-gcm", "wrong"
+	result := ProtocolTest(context.Background(), proxy, egress.URL, time.Second)
+	assertEqual(t, result.Success, false, "wrong ss password success")
+
+	tlsListener := newTLSListener(t)
+	startStandIn(t, tlsListener, trojanStandIn)
+	proxy = listenerProxy(t, "trojan", tlsListener.Addr().String())
+	proxy.Password, proxy.AllowInsecure = "wrong", true
+	result = ProtocolTest(context.Background(), proxy, egress.URL, time.Second)
+	assertEqual(t, result.Success, false, "wrong trojan password success")
+
+	// 未允许不安全连接时自签名证书校验失败
+	proxy.Password, proxy.AllowInsecure = testPassword, false
+	result = ProtocolTest(context.Background(), proxy, egress.URL, time.Second)
+	assertEqual(t, strings.Contains(result.Error, "TLS握手失败"), true, "certificate error")
+
+	unsupported := []models.Proxy{
+		{Type: "vmess", UUID: testUUID, Network: "ws", Server: "example.com", Port: 443},
+		{Type: "vmess", UUID: testUUID, RawConfig: `{"aid":64}`, Server: "example.com", Port: 443},
+		{Type: "vless", UUID: testUUID, RawConfig: `{"security":"reality"}`, Server: "example.com", Port: 443},
+		{Type: "ss", Method: "rc4-md5", Password: testPassword, Server: "example.com", Port: 443},
+		{Type: "hysteria2", Password: testPassword, Server: "example.com", Port: 443},
+	}
+	for _, proxy := range unsupported {
+		assertEqual(t, CheckProtocolSupport(proxy) != nil, true, "unsupported "+proxy.Type+" "+proxy.Network+proxy.RawConfig+proxy.Method)
+	}
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+
+	"proxy-subscription/models"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// ssMaxPayload Shadowsocks AEAD单个数据块的最大长度
+const ssMaxPayload = 0x3FFF
+
+// ssAEADCiphers 支持的Shadowsocks AEAD加密方式及密钥长度
+var ssAEADCiphers = map[string]struct {
+	keySize int
+	newAEAD func(key []byte) (cipher.AEAD, error)
+}{
+	"aes-128-gcm":             {16, newAESGCM},
+	"aes-192-gcm":             {24, newAESGCM},
+	"aes-256-gcm":             {32, newAESGCM},
+	"chacha20-ietf-poly1305":  {32, chacha20poly1305.New},
+	"chacha20-poly1305":       {32, chacha20poly1305.New},
+	"xchacha20-ietf-poly1305": {32, chacha20poly1305.NewX},
+}
+
+// ssCipher Shadowsocks AEAD加密参数，主密钥由密码派生
+type ssCipher struct {
+	key     []byte
+	newAEAD func(key []byte) (cipher.AEAD, error)
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// newSSCipher 按加密方式和密码创建加密参数
+func newSSCipher(method string, password string) (*ssCipher, error) {
+	spec, exists := ssAEADCiphers[strings.ToLower(method)]
+	if !exists {
+		return nil, errors.New("协议测试暂不支持Shadowsocks加密方式: " + method)
+	}
+	return &ssCipher{key: evpBytesToKey(password, spec.keySize), newAEAD: spec.newAEAD}, nil
+}
+
+// evpBytesToKey 与OpenSSL EVP_BytesToKey（MD5）一致的密钥派生
+func evpBytesToKey(password string, keySize int) []byte {
+	var key, previous []byte
+	for len(key) < keySize {
+		hash := md5.New()
+		hash.Write(previous)
+		hash.Write([]byte(password))
+		previous = hash.Sum(nil)
+		key = append(key, previous...)
+	}
+	return key[:keySize]
+}
+
+// subkey 以盐值经HKDF-SHA1派生会话子密钥
+func (c *ssCipher) subkey(salt []byte) ([]byte, error) {
+	subkey := make([]byte, len(c.key))
+	if _, err := io.ReadFull(hkdf.New(sha1.New, c.key, salt, []byte("ss-subkey")), subkey); err != nil {
+		return nil, err
+	}
+	return subkey, nil
+}
+
+// sessionAEAD 以盐值派生的会话子密钥创建加密器
+func (c *ssCipher) sessionAEAD(salt []byte) (cipher.AEAD, error) {
+	subkey, err := c.subkey(salt)
+	if err != nil {
+		return nil, err
+	}
+	return c.newAEAD(subkey)
+}
+
+// dialShadowsocks 建立加密连接并发送目标地址
+func dialShadowsocks(conn net.Conn, proxy models.Proxy, target string) (net.Conn, error) {
+	ssc, err := newSSCipher(proxy.Method, proxy.Password)
+	if err != nil {
+		return nil, err
+	}
+	address, err := socksAddress(target)
+	if err != nil {
+		return nil, err
+	}
+	stream := newSSConn(conn, ssc)
+	if _, err := stream.Write(address); err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// ssConn Shadowsocks AEAD数据流，每个方向以各自的随机盐值开始
+// 每个数据块为：加密的2字节长度 + 加密的数据，nonce按块递增
+type ssConn struct {
+	net.Conn
+	cipher *ssCipher
+
+	writer      cipher.AEAD
+	writeNonce  []byte
+	reader      cipher.AEAD
+	readNonce   []byte
+	readPending []byte
+}
+
+func newSSConn(conn net.Conn, ssc *ssCipher) *ssConn {
+	return &ssConn{Conn: conn, cipher: ssc}
+}
+
+func (c *ssConn) Write(b []byte) (int, error) {
+	var out []byte
+	if c.writer == nil {
+		salt := make([]byte, len(c.cipher.key))
+		if _, err := rand.Read(salt); err != nil {
+			return 0, err
+		}
+		aead, err := c.cipher.sessionAEAD(salt)
+		if err != nil {
+			return 0, err
+		}
+		c.writer = aead
+		c.writeNonce = make([]byte, aead.NonceSize())
+		out = salt
+	}
+
+	written := 0
+	for written < len(b) {
+		size := min(len(b)-written, ssMaxPayload)
+		length := binary.BigEndian.AppendUint16(nil, uint16(size))
+		out = c.writer.Seal(out, c.writeNonce, length, nil)
+		incrementNonce(c.writeNonce)
+		out = c.writer.Seal(out, c.writeNonce, b[written:written+size], nil)
+		incrementNonce(c.writeNonce)
+		written += size
+	}
+	if _, err := c.Conn.Write(out); err != nil {
+		return 0, err
+	}
+	return written, nil
+}
+
+func (c *ssConn) Read(b []byte) (int, error) {
+	if len(c.readPending) == 0 {
+		if err := c.readChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(b, c.readPending)
+	c.readPending = c.readPending[n:]
+	return n, nil
+}
+
+// readChunk 读取并解密一个数据块
+func (c *ssConn) readChunk() error {
+	if c.reader == nil {
+		salt := make([]byte, len(c.cipher.key))
+		if _, err := io.ReadFull(c.Conn, salt); err != nil {
+			return err
+		}
+		aead, err := c.cipher.sessionAEAD(salt)
+		if err != nil {
+			return err
+		}
+		c.reader = aead
+		c.readNonce = make([]byte, aead.NonceSize())
+	}
+
+	overhead := c.reader.Overhead()
+	buf := make([]byte, 2+overhead)
+	if _, err := io.ReadFull(c.Conn, buf); err != nil {
+		return err
+	}
+	length, err := c.reader.Open(buf[:0], c.readNonce, buf, nil)
+	if err != nil {
+		return errors.New("Shadowsocks解密失败，请检查密码和加密方式")
+	}
+	incrementNonce(c.readNonce)
+
+	size := int(binary.BigEndian.Uint16(length)) & ssMaxPayload
+	buf = make([]byte, size+overhead)
+	if _, err := io.ReadFull(c.Conn, buf); err != nil {
+		return err
+	}
+	payload, err := c.reader.Open(buf[:0], c.readNonce, buf, nil)
+	if err != nil {
+		return errors.New("Shadowsocks解密失败，请检查密码和加密方式")
+	}
+	incrementNonce(c.readNonce)
+	c.readPending = payload
+	return nil
+}
+
+// incrementNonce 按小端序递增nonce
+func incrementNonce(nonce []byte) {
+	for i := range nonce {
+		nonce[i]++
+		if nonce[i] != 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"proxy-subscription/models"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// VMess请求头中的加密方式
+const (
+	vmessSecurityAES128GCM        byte = 0x03
+	vmessSecurityChacha20Poly1305 byte = 0x04
+	vmessSecurityNone             byte = 0x05
+)
+
+// vmessMaxPayload 单个数据块的最大明文长度
+const vmessMaxPayload = 8192
+
+// vmessSecurity 读取VMess节点的加密方式，兼容分享链接和Clash的字段名
+func vmessSecurity(rawConfig map[string]interface{}) string {
+	return strings.ToLower(rawConfigText(rawConfig, "scy", "cipher", "security"))
+}
+
+// vmessSecurityType 将加密方式转换为请求头中的取值，auto按AES-128-GCM处理
+func vmessSecurityType(security string) (byte, error) {
+	switch security {
+	case "", "auto", "aes-128-gcm":
+		return vmessSecurityAES128GCM, nil
+	case "chacha20-poly1305", "chacha20-ietf-poly1305":
+		return vmessSecurityChacha20Poly1305, nil
+	case "none":
+		return vmessSecurityNone, nil
+	}
+	return 0, errors.New("协议测试暂不支持VMess加密方式: " + security)
+}
+
+// vmessKDF VMess AEAD使用的嵌套HMAC-SHA256密钥派生
+func vmessKDF(key []byte, path ...string) []byte {
+	creator := func() hash.Hash { return hmac.New(sha256.New, []byte("VMess AEAD KDF")) }
+	for _, value := range path {
+		parent, salt := creator, []byte(value)
+		creator = func() hash.Hash { return hmac.New(parent, salt) }
+	}
+	mac := creator()
+	mac.Write(key)
+	return mac.Sum(nil)
+}
+
+// vmessCmdKey 由用户ID派生指令密钥
+func vmessCmdKey(id [16]byte) []byte {
+	hash := md5.Sum(append(id[:], []byte("c48619fe-8f02-49e0-b9e9-edf763e17e21")...))
+	return hash[:]
+}
+
+// vmessBodyAEAD 按加密方式创建数据块加密器，none返回nil
+func vmessBodyAEAD(security byte, key []byte) (cipher.AEAD, error) {
+	switch security {
+	case vmessSecurityAES128GCM:
+		return newAESGCM(key)
+	case vmessSecurityChacha20Poly1305:
+		first := md5.Sum(key)
+		second := md5.Sum(first[:])
+		return chacha20poly1305.New(append(first[:], second[:]...))
+	}
+	return nil, nil
+}
+
+// vmessAuthID 以当前时间和随机数生成AEAD认证ID
+func vmessAuthID(cmdKey []byte) ([]byte, error) {
+	random := make([]byte, 4)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	return newVMessAuthID(cmdKey, time.Now(), random)
+}
+
+// newVMessAuthID 生成AEAD认证ID：时间戳、4字节随机数和CRC32，经指令密钥派生的AES密钥加密
+func newVMessAuthID(cmdKey []byte, now time.Time, random []byte) ([]byte, error) {
+	plain := make([]byte, 16)
+	binary.BigEndian.PutUint64(plain, uint64(now.Unix()))
+	copy(plain[8:12], random)
+	binary.BigEndian.PutUint32(plain[12:], crc32.ChecksumIEEE(plain[:12]))
+
+	block, err := aes.NewCipher(vmessKDF(cmdKey, "AES Auth ID Encryption")[:16])
+	if err != nil {
+		return nil, err
+	}
+	authID := make([]byte, 16)
+	block.Encrypt(authID, plain)
+	return authID, nil
+}
+
+// sealVMessHeader 以AEAD方式封装请求头：认证ID、加密的长度、连接随机数、加密的请求头
+func sealVMessHeader(cmdKey []byte, header []byte) ([]byte, error) {
+	authID, err := vmessAuthID(cmdKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	lengthAEAD, err := newAESGCM(vmessKDF(cmdKey, "VMess Header AEAD Key_Length", string(authID), string(nonce))[:16])
+	if err != nil {
+		return nil, err
+	}
+	headerAEAD, err := newAESGCM(vmessKDF(cmdKey, "VMess Header AEAD Key", string(authID), string(nonce))[:16])
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := append([]byte{}, authID...)
+	length := binary.BigEndian.AppendUint16(nil, uint16(len(header)))
+	sealed = lengthAEAD.Seal(sealed, vmessKDF(cmdKey, "VMess Header AEAD Nonce_Length", string(authID), string(nonce))[:12], length, authID)
+	sealed = append(sealed, nonce...)
+	sealed = headerAEAD.Seal(sealed, vmessKDF(cmdKey, "VMess Header AEAD Nonce", string(authID), string(nonce))[:12], header, authID)
+	return sealed, nil
+}
+
+// dialVMess 发送AEAD请求头，返回加密的数据流
+func dialVMess(conn net.Conn, proxy models.Proxy, target string) (net.Conn, error) {
+	id, err := parseUUID(proxy.UUID)
+	if err != nil {
+		return nil, err
+	}
+	security, err := vmessSecurityType(vmessSecurity(parseRawConfig(proxy.RawConfig)))
+	if err != nil {
+		return nil, err
+	}
+	address, err := vAddress(target)
+	if err != nil {
+		return nil, err
+	}
+
+	// 版本(1) 数据IV(16) 数据密钥(16) 响应认证(1) 选项(1) 填充长度与加密方式(1) 保留(1) 指令(1) 地址
+	header := make([]byte, 38, 38+len(address)+4)
+	header[0] = 0x01
+	if _, err := rand.Read(header[1:34]); err != nil {
+		return nil, err
+	}
+	header[34] = 0x01 // 分块传输
+	header[35] = security
+	header[37] = 0x01 // TCP
+	header = append(header, address...)
+	checksum := fnv.New32a()
+	checksum.Write(header)
+	header = checksum.Sum(header)
+
+	sealed, err := sealVMessHeader(vmessCmdKey(id), header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(sealed); err != nil {
+		return nil, err
+	}
+
+	stream := &vmessConn{Conn: conn, security: security, responseAuth: header[33]}
+	copy(stream.requestIV[:], header[1:17])
+	copy(stream.requestKey[:], header[17:33])
+	requestAEAD, err := vmessBodyAEAD(security, stream.requestKey[:])
+	if err != nil {
+		return nil, err
+	}
+	stream.writer = newVMessChunkWriter(conn, requestAEAD, stream.requestIV[:])
+	return stream, nil
+}
+
+// vmessConn VMess数据流，首次读取时校验响应头
+type vmessConn struct {
+	net.Conn
+	security     byte
+	responseAuth byte
+	requestKey   [16]byte
+	requestIV    [16]byte
+
+	writer *vmessChunkWriter
+	reader *vmessChunkReader
+}
+
+func (c *vmessConn) Write(b []byte) (int, error) {
+	return c.writer.Write(b)
+}
+
+func (c *vmessConn) Read(b []byte) (int, error) {
+	if c.reader == nil {
+		responseKey := sha256.Sum256(c.requestKey[:])
+		responseIV := sha256.Sum256(c.requestIV[:])
+		if err := readVMessResponseHeader(c.Conn, responseKey[:16], responseIV[:16], c.responseAuth); err != nil {
+			return 0, err
+		}
+		responseAEAD, err := vmessBodyAEAD(c.security, responseKey[:16])
+		if err != nil {
+			return 0, err
+		}
+		c.reader = newVMessChunkReader(c.Conn, responseAEAD, responseIV[:16])
+	}
+	return c.reader.Read(b)
+}
+
+// readVMessResponseHeader 读取并校验AEAD响应头
+func readVMessResponseHeader(r io.Reader, key []byte, iv []byte, responseAuth byte) error {
+	lengthAEAD, err := newAESGCM(vmessKDF(key, "AEAD Resp Header Len Key")[:16])
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 2+lengthAEAD.Overhead())
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+	length, err := lengthAEAD.Open(nil, vmessKDF(iv, "AEAD Resp Header Len IV")[:12], buf, nil)
+	if err != nil {
+		return errors.New("VMess响应头解密失败，请检查UUID")
+	}
+
+	headerAEAD, err := newAESGCM(vmessKDF(key, "AEAD Resp Header Key")[:16])
+	if err != nil {
+		return err
+	}
+	buf = make([]byte, int(binary.BigEndian.Uint16(length))+headerAEAD.Overhead())
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+	header, err := headerAEAD.Open(nil, vmessKDF(iv, "AEAD Resp Header IV")[:12], buf, nil)
+	if err != nil || len(header) < 4 {
+		return errors.New("VMess响应头解密失败，请检查UUID")
+	}
+	if header[0] != responseAuth {
+		return errors.New("VMess响应认证不匹配")
+	}
+	return nil
+}
+
+// vmessChunkNonce 数据块nonce：2字节计数 + IV[2:12]
+func vmessChunkNonce(iv []byte, count uint16) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint16(nonce, count)
+	copy(nonce[2:], iv[2:12])
+	return nonce
+}
+
+// vmessChunkWriter 写入分块数据：2字节长度 + 加密的数据
+type vmessChunkWriter struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	iv    []byte
+	count uint16
+}
+
+func newVMessChunkWriter(w io.Writer, aead cipher.AEAD, iv []byte) *vmessChunkWriter {
+	return &vmessChunkWriter{w: w, aead: aead, iv: iv}
+}
+
+func (w *vmessChunkWriter) Write(b []byte) (int, error) {
+	written := 0
+	for written < len(b) {
+		size := min(len(b)-written, vmessMaxPayload)
+		payload := b[written : written+size]
+		chunk := make([]byte, 2, 2+size+16)
+		if w.aead != nil {
+			chunk = w.aead.Seal(chunk, vmessChunkNonce(w.iv, w.count), payload, nil)
+			w.count++
+		} else {
+			chunk = append(chunk, payload...)
+		}
+		binary.BigEndian.PutUint16(chunk, uint16(len(chunk)-2))
+		if _, err := w.w.Write(chunk); err != nil {
+			return written, err
+		}
+		written += size
+	}
+	return written, nil
+}
+
+// vmessChunkReader 读取分块数据，空数据块表示流结束
+type vmessChunkReader struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	iv      []byte
+	count   uint16
+	pending []byte
+}
+
+func newVMessChunkReader(r io.Reader, aead cipher.AEAD, iv []byte) *vmessChunkReader {
+	return &vmessChunkReader{r: r, aead: aead, iv: iv}
+}
+
+func (r *vmessChunkReader) Read(b []byte) (int, error) {
+	for len(r.pending) == 0 {
+		length := make([]byte, 2)
+		if _, err := io.ReadFull(r.r, length); err != nil {
+			return 0, err
+		}
+		chunk := make([]byte, binary.BigEndian.Uint16(length))
+		if _, err := io.ReadFull(r.r, chunk); err != nil {
+			return 0, err
+		}
+		if r.aead != nil {
+			payload, err := r.aead.Open(chunk[:0], vmessChunkNonce(r.iv, r.count), chunk, nil)
+			if err != nil {
+				return 0, errors.New("VMess数据解密失败")
+			}
+			r.count++
+			chunk = payload
+		}
+		if len(chunk) == 0 {
+			return 0, io.EOF
+		}
+		r.pending = chunk
+	}
+	n := copy(b, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
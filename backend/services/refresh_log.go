@@ -0,0 +1,64 @@
+package services
+
+import (
+	"proxy-subscription/models"
+	"proxy-subscription/utils"
+)
+
+// 刷新记录保留策略
+const (
+	maxStoredLineErrors        = 100 // 每条刷新记录最多保存的错误行数
+	refreshLogsPerSubscription = 20  // 每个订阅保留的刷新记录数
+)
+
+// applyParseResult 将解析结果写入刷新记录
+func applyParseResult(refreshLog *models.RefreshLog, result ParseResult) {
+	refreshLog.Format = result.Format
+	refreshLog.TotalLines = result.TotalLines
+	refreshLog.RecognizedLines = result.RecognizedLines
+	refreshLog.ProxyCount = len(result.Proxies)
+	refreshLog.ErrorCount = len(result.Errors)
+	refreshLog.LineErrors = result.Errors
+	if len(refreshLog.LineErrors) > maxStoredLineErrors {
+		refreshLog.LineErrors = refreshLog.LineErrors[:maxStoredLineErrors]
+	}
+	refreshLog.SkippedSchemes = result.SkippedSchemes
+}
+
+// recordRefreshLog 保存刷新记录，并清理该订阅较早的记录
+func recordRefreshLog(refreshLog models.RefreshLog, refreshErr error) {
+	refreshLog.Success = refreshErr == nil
+	if refreshErr != nil {
+		refreshLog.Error = refreshErr.Error()
+	}
+	if refreshLog.LineErrors == nil {
+		refreshLog.LineErrors = []models.LineError{}
+	}
+	if refreshLog.SkippedSchemes == nil {
+		refreshLog.SkippedSchemes = map[string]int{}
+	}
+
+	if err := models.DB.Create(&refreshLog).Error; err != nil {
+		utils.Warn("保存刷新记录失败 ID=%d, 错误: %v", refreshLog.SubscriptionID, err)
+		return
+	}
+
+	var boundary models.RefreshLog
+	if err := models.DB.Select("id").Where("subscription_id = ?", refreshLog.SubscriptionID).
+		Order("id DESC").Offset(refreshLogsPerSubscription - 1).Limit(1).Find(&boundary).Error; err != nil || boundary.ID == 0 {
+		return
+	}
+	if err := models.DB.Where("subscription_id = ? AND id < ?", refreshLog.SubscriptionID, boundary.ID).
+		Delete(&models.RefreshLog{}).Error; err != nil {
+		utils.Warn("清理刷新记录失败 ID=%d, 错误: %v", refreshLog.SubscriptionID, err)
+	}
+}
+
+// LatestRefreshLog 获取订阅最近一次刷新记录
+func LatestRefreshLog(subscriptionID uint) (models.RefreshLog, bool) {
+	var refreshLog models.RefreshLog
+	if err := models.DB.Where("subscription_id = ?", subscriptionID).Order("id DESC").First(&refreshLog).Error; err != nil {
+		return refreshLog, false
+	}
+	return refreshLog, true
+}
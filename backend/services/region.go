@@ -0,0 +1,187 @@
+package services
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"proxy-subscription/models"
+)
+
+// 地区分组类型
+const (
+	RegionGroupURLTest = "url-test" // 自动选择延迟最低的节点
+	RegionGroupSelect  = "select"   // 手动选择
+)
+
+// DefaultRegionGroupMinSize 默认的地区分组最少节点数
+const DefaultRegionGroupMinSize = 1
+
+// IsValidRegionGroupType 判断地区分组类型是否有效
+func IsValidRegionGroupType(groupType string) bool {
+	return groupType == RegionGroupURLTest || groupType == RegionGroupSelect
+}
+
+// RegionGroupOptions 地区分组选项
+type RegionGroupOptions struct {
+	Enabled bool
+	Type    string   // 分组类型，url-test或select
+	Order   []string // 优先排列的国家代码，其余地区按节点数从多到少排列
+	MinSize int      // 节点数少于该值的地区并入"其他"分组
+	Locale  string   // 分组名称使用的语言
+}
+
+// RegionGroup 按地区划分的节点分组，Code为空表示"其他"分组
+type RegionGroup struct {
+	Code    string
+	Name    string
+	Type    string
+	Proxies []string // 分组内的节点名称，保持输入顺序
+}
+
+// GroupProxiesByRegion 按节点所在国家生成分组，国家优先使用GeoIP查询结果，其次使用名称推测。
+// 无法识别国家及节点数不足的地区归入最后的"其他"分组，分组名称不与节点名称及reserved中的名称重复
+func GroupProxiesByRegion(proxies []models.Proxy, options RegionGroupOptions, reserved ...string) []RegionGroup {
+	if !options.Enabled || len(proxies) == 0 {
+		return nil
+	}
+	groupType := options.Type
+	if !IsValidRegionGroupType(groupType) {
+		groupType = RegionGroupURLTest
+	}
+
+	members := make(map[string][]string)
+	codes := make([]string, 0)
+	for _, proxy := range proxies {
+		code := proxy.LocationCountry()
+		if _, exists := members[code]; !exists && code != "" {
+			codes = append(codes, code)
+		}
+		members[code] = append(members[code], proxy.Name)
+	}
+
+	rank := make(map[string]int, len(options.Order))
+	for i, code := range options.Order {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if _, exists := rank[code]; !exists {
+			rank[code] = i
+		}
+	}
+	sort.SliceStable(codes, func(i, j int) bool {
+		rankI, orderedI := rank[codes[i]]
+		rankJ, orderedJ := rank[codes[j]]
+		if orderedI != orderedJ {
+			return orderedI
+		}
+		if orderedI {
+			return rankI < rankJ
+		}
+		if len(members[codes[i]]) != len(members[codes[j]]) {
+			return len(members[codes[i]]) > len(members[codes[j]])
+		}
+		return codes[i] < codes[j]
+	})
+
+	used := make(map[string]struct{}, len(proxies)+len(reserved))
+	for _, proxy := range proxies {
+		used[proxy.Name] = struct{}{}
+	}
+	for _, name := range reserved {
+		used[name] = struct{}{}
+	}
+
+	groups := make([]RegionGroup, 0, len(codes)+1)
+	folded := make(map[string]struct{})
+	for _, code := range codes {
+		if len(members[code]) < options.MinSize {
+			folded[code] = struct{}{}
+			continue
+		}
+		groups = append(groups, RegionGroup{
+			Code:    code,
+			Name:    uniqueGroupName(regionGroupName(code, options.Locale), used),
+			Type:    groupType,
+			Proxies: members[code],
+		})
+	}
+
+	// "其他"分组保持节点的原有顺序
+	others := make([]string, 0)
+	for _, proxy := range proxies {
+		code := proxy.LocationCountry()
+		if _, isFolded := folded[code]; isFolded || code == "" {
+			others = append(others, proxy.Name)
+		}
+	}
+	if len(others) > 0 {
+		groups = append(groups, RegionGroup{
+			Name:    uniqueGroupName(othersGroupName(options.Locale), used),
+			Type:    groupType,
+			Proxies: others,
+		})
+	}
+	return groups
+}
+
+// regionGroupName 地区分组名称：国旗 + 按语言的国家名称
+func regionGroupName(code, locale string) string {
+	name := models.CountryName(code, locale)
+	if name == "" {
+		name = code
+	}
+	if flag := models.CountryFlag(code); flag != "" {
+		return flag + " " + name
+	}
+	return name
+}
+
+func othersGroupName(locale string) string {
+	if locale == models.DisplayLocaleZh {
+		return "其他"
+	}
+	return "Others"
+}
+
+// uniqueGroupName 与节点或其他分组重名时追加序号
+func uniqueGroupName(name string, used map[string]struct{}) string {
+	candidate := name
+	for suffix := 2; ; suffix++ {
+		if _, exists := used[candidate]; !exists {
+			used[candidate] = struct{}{}
+			return candidate
+		}
+		candidate = name + " " + strconv.Itoa(suffix)
+	}
+}
+
+// LoadRegionGroupOptions 读取地区分组设置
+func LoadRegionGroupOptions() RegionGroupOptions {
+	options := RegionGroupOptions{
+		Enabled: models.GetSetting(models.SettingRegionGroups, "false") == "true",
+		Type:    models.GetSetting(models.SettingRegionGroupType, RegionGroupURLTest),
+		Order:   LoadRegionGroupOrder(),
+		MinSize: DefaultRegionGroupMinSize,
+		Locale:  models.CurrentDisplayLocale(),
+	}
+	if !IsValidRegionGroupType(options.Type) {
+		options.Type = RegionGroupURLTest
+	}
+	if minSize, err := strconv.Atoi(models.GetSetting(models.SettingRegionGroupMinSize, "")); err == nil && minSize > 0 {
+		options.MinSize = minSize
+	}
+	return options
+}
+
+// LoadRegionGroupOrder 读取地区分组的排列顺序，未设置或无效时返回空
+func LoadRegionGroupOrder() []string {
+	value := models.GetSetting(models.SettingRegionGroupOrder, "")
+	if value == "" {
+		return []string{}
+	}
+	var order []string
+	if err := json.Unmarshal([]byte(value), &order); err != nil {
+		return []string{}
+	}
+	return order
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"testing"
+
+	"proxy-subscription/models"
+)
+
+func TestGroupProxiesByRegion(t *testing.T) {
+	proxies := []models.Proxy{
+		{Name: "Node 01"},
+		{Name: "日本 01"},
+		{Name: "香港 01"},
+		{Name: "美国 01"},
+		{Name: "日本 02"},
+		{Name: "Node 02", GeoLocation: models.GeoLocation{GeoCountry: "JP"}},
+		{Name: "美国 02"},
+		{Name: "德国 01"},
+	}
+
+	groups := GroupProxiesByRegion(proxies, RegionGroupOptions{Enabled: true, MinSize: 1, Locale: models.DisplayLocaleZh})
+	assertEqual(t, len(groups), 5, "group count")
+	assertEqual(t, groups[0].Name, "🇯🇵 日本", "largest region first")
+	assertEqual(t, len(groups[0].Proxies), 3, "geo country grouped")
+	assertEqual(t, groups[1].Code, "US", "second largest region")
+	assertEqual(t, groups[2].Code, "DE", "same size sorted by code")
+	assertEqual(t, groups[3].Code, "HK", "same size sorted by code")
+	assertEqual(t, groups[4].Name, "其他", "others last")
+	assertEqual(t, groups[4].Type, RegionGroupURLTest, "default type")
+
+	groups = GroupProxiesByRegion(proxies, RegionGroupOptions{
+		Enabled: true,
+		Type:    RegionGroupSelect,
+		Order:   []string{"hk", "US"},
+		MinSize: 2,
+		Locale:  models.DisplayLocaleEn,
+	})
+	assertEqual(t, len(groups), 3, "small regions folded")
+	assertEqual(t, groups[0].Name, "🇺🇸 United States", "ordered region kept when large enough")
+	assertEqual(t, groups[1].Code, "JP", "unordered region after ordered")
+	assertEqual(t, groups[2].Name, "Others", "english others")
+	assertEqual(t, groups[2].Type, RegionGroupSelect, "select type")
+	wantOthers := []string{"Node 01", "香港 01", "德国 01"}
+	for i, name := range wantOthers {
+		assertEqual(t, groups[2].Proxies[i], name, "others keep input order")
+	}
+
+	if groups := GroupProxiesByRegion(proxies, RegionGroupOptions{}); groups != nil {
+		t.Fatalf("disabled region groups = %v, want nil", groups)
+	}
+}
+
+func TestGroupProxiesByRegionUniqueNames(t *testing.T) {
+	proxies := []models.Proxy{
+		{Name: "🇭🇰 香港"},
+		{Name: "Node 01"},
+	}
+
+	groups := GroupProxiesByRegion(proxies, RegionGroupOptions{Enabled: true, MinSize: 1, Locale: models.DisplayLocaleZh}, "其他")
+	assertEqual(t, groups[0].Name, "🇭🇰 香港 2", "group name avoids proxy name")
+	assertEqual(t, groups[1].Name, "其他 2", "group name avoids reserved name")
+}
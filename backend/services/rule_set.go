@@ -0,0 +1,212 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"proxy-subscription/models"
+)
+
+// ruleSetNamePattern 规则集名称出现在托管地址和配置的引用中，只允许安全字符
+var ruleSetNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// SingboxGeoIPRuleSetURL sing-box不支持在规则集中按国家匹配IP，geoip规则改为引用官方的geoip规则集
+const SingboxGeoIPRuleSetURL = "https://raw.githubusercontent.com/SagerNet/sing-geoip/rule-set/geoip-%s.srs"
+
+// NormalizeRuleSet 规范化并校验规则集，规则值中不允许出现逗号和换行，避免破坏生成的规则
+func NormalizeRuleSet(ruleSet *models.RuleSet) error {
+	ruleSet.Name = strings.TrimSpace(ruleSet.Name)
+	ruleSet.Description = strings.TrimSpace(ruleSet.Description)
+	ruleSet.Policy = strings.ToLower(strings.TrimSpace(ruleSet.Policy))
+	if !ruleSetNamePattern.MatchString(ruleSet.Name) {
+		return errors.New("规则集名称只能包含字母、数字、-和_，且不超过64个字符")
+	}
+	if strings.HasPrefix(strings.ToLower(ruleSet.Name), "geoip-") {
+		return errors.New("规则集名称不能以geoip-开头，该前缀用于sing-box配置中的geoip规则集")
+	}
+	if !models.IsValidRulePolicy(ruleSet.Policy) {
+		return errors.New("不支持的规则集策略: " + ruleSet.Policy)
+	}
+	if ruleSet.Rules == nil {
+		ruleSet.Rules = []models.RuleEntry{}
+	}
+
+	for i := range ruleSet.Rules {
+		rule, err := normalizeRuleEntry(ruleSet.Rules[i])
+		if err != nil {
+			return fmt.Errorf("第 %d 条规则无效: %v", i+1, err)
+		}
+		ruleSet.Rules[i] = rule
+	}
+	return nil
+}
+
+func normalizeRuleEntry(rule models.RuleEntry) (models.RuleEntry, error) {
+	rule.Type = strings.ToLower(strings.TrimSpace(rule.Type))
+	rule.Value = strings.TrimSpace(rule.Value)
+	if !models.IsValidRuleType(rule.Type) {
+		return rule, errors.New("不支持的规则类型 " + rule.Type)
+	}
+	if rule.Value == "" {
+		return rule, errors.New("规则内容不能为空")
+	}
+	if strings.ContainsAny(rule.Value, ",\r\n") {
+		return rule, errors.New("规则内容不能包含逗号或换行")
+	}
+
+	switch rule.Type {
+	case models.RuleDomain, models.RuleDomainKeyword:
+		rule.Value = strings.ToLower(rule.Value)
+	case models.RuleDomainSuffix:
+		rule.Value = strings.TrimPrefix(strings.ToLower(rule.Value), ".")
+	case models.RuleIPCIDR:
+		// 单个IP按/32或/128处理
+		if ip := net.ParseIP(rule.Value); ip != nil {
+			if ip.To4() != nil {
+				rule.Value = ip.String() + "/32"
+			} else {
+				rule.Value = ip.String() + "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(rule.Value)
+		if err != nil {
+			return rule, errors.New("无效的IP段 " + rule.Value)
+		}
+		rule.Value = network.String()
+	case models.RuleGeoIP:
+		rule.Value = strings.ToUpper(rule.Value)
+		if len(rule.Value) != 2 || !isUpperLetters(rule.Value) {
+			return rule, errors.New("无效的国家代码 " + rule.Value)
+		}
+	}
+	if rule.Value == "" {
+		return rule, errors.New("规则内容不能为空")
+	}
+	return rule, nil
+}
+
+func isUpperLetters(value string) bool {
+	for _, r := range value {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// LoadEnabledRuleSets 读取在生成配置中引用的规则集，按优先级从高到低排列
+func LoadEnabledRuleSets() ([]models.RuleSet, error) {
+	ruleSets := make([]models.RuleSet, 0)
+	if err := models.DB.Where("enabled = ?", true).Order("priority DESC, id").Find(&ruleSets).Error; err != nil {
+		return nil, err
+	}
+	return ruleSets, nil
+}
+
+// clashRuleTypes 规则类型在Clash中的名称
+var clashRuleTypes = map[string]string{
+	models.RuleDomain:        "DOMAIN",
+	models.RuleDomainSuffix:  "DOMAIN-SUFFIX",
+	models.RuleDomainKeyword: "DOMAIN-KEYWORD",
+	models.RuleIPCIDR:        "IP-CIDR",
+	models.RuleGeoIP:         "GEOIP",
+	models.RuleProcess:       "PROCESS-NAME",
+}
+
+// classicalRuleLine 生成Clash和Surge通用的"类型,值"形式规则
+func classicalRuleLine(rule models.RuleEntry) string {
+	ruleType := clashRuleTypes[rule.Type]
+	if rule.Type == models.RuleIPCIDR && strings.Contains(rule.Value, ":") {
+		ruleType = "IP-CIDR6"
+	}
+	return ruleType + "," + rule.Value
+}
+
+// GenerateClashRuleProvider 生成classical行为的Clash rule-provider内容
+func GenerateClashRuleProvider(rules []models.RuleEntry) string {
+	var yaml strings.Builder
+	yaml.WriteString("payload:\n")
+	for _, rule := range rules {
+		yaml.WriteString("  - " + classicalRuleLine(rule) + "\n")
+	}
+	return yaml.String()
+}
+
+// GenerateSurgeRuleSet 生成Surge RULE-SET引用的规则列表，每行一条规则
+func GenerateSurgeRuleSet(rules []models.RuleEntry) string {
+	var list strings.Builder
+	for _, rule := range rules {
+		list.WriteString(classicalRuleLine(rule) + "\n")
+	}
+	return list.String()
+}
+
+// singboxRuleFields 规则类型在sing-box规则集中的字段名，geoip无法在规则集中表达
+var singboxRuleFields = map[string]string{
+	models.RuleDomain:        "domain",
+	models.RuleDomainSuffix:  "domain_suffix",
+	models.RuleDomainKeyword: "domain_keyword",
+	models.RuleIPCIDR:        "ip_cidr",
+	models.RuleProcess:       "process_name",
+}
+
+// singboxRuleFieldOrder 固定字段的输出顺序
+var singboxRuleFieldOrder = []string{"domain", "domain_suffix", "domain_keyword", "ip_cidr", "process_name"}
+
+// GenerateSingboxRuleSet 生成sing-box源格式规则集，跳过geoip规则。
+// sing-box同一条规则中的进程与域名等条件为"且"的关系，因此每种字段各自生成一条规则
+func GenerateSingboxRuleSet(rules []models.RuleEntry) (string, error) {
+	values := make(map[string][]string)
+	for _, rule := range rules {
+		if field, ok := singboxRuleFields[rule.Type]; ok {
+			values[field] = append(values[field], rule.Value)
+		}
+	}
+
+	headlessRules := make([]map[string][]string, 0, len(values))
+	for _, field := range singboxRuleFieldOrder {
+		if len(values[field]) > 0 {
+			headlessRules = append(headlessRules, map[string][]string{field: values[field]})
+		}
+	}
+
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"version": 1,
+		"rules":   headlessRules,
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// RuleSetGeoIPCodes 返回规则集中geoip规则的国家代码（去重并保持顺序）
+func RuleSetGeoIPCodes(rules []models.RuleEntry) []string {
+	seen := make(map[string]struct{})
+	codes := make([]string, 0)
+	for _, rule := range rules {
+		if rule.Type != models.RuleGeoIP {
+			continue
+		}
+		if _, exists := seen[rule.Value]; exists {
+			continue
+		}
+		seen[rule.Value] = struct{}{}
+		codes = append(codes, rule.Value)
+	}
+	return codes
+}
+
+// HasSingboxRules 判断规则集中是否有可以写入sing-box规则集的规则
+func HasSingboxRules(rules []models.RuleEntry) bool {
+	for _, rule := range rules {
+		if _, ok := singboxRuleFields[rule.Type]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+
+	"proxy-subscription/models"
+)
+
+func TestNormalizeRuleSet(t *testing.T) {
+	ruleSet := models.RuleSet{
+		Name:   " streaming ",
+		Policy: "Proxy",
+		Rules: []models.RuleEntry{
+			{Type: "Domain", Value: "Www.Example.com"},
+			{Type: "domain-suffix", Value: ".netflix.com"},
+			{Type: "ip-cidr", Value: "203.0.113.7"},
+			{Type: "ip-cidr", Value: "2001:db8::1/32"},
+			{Type: "geoip", Value: "cn"},
+			{Type: "process", Value: "Telegram.exe"},
+		},
+	}
+	if err := NormalizeRuleSet(&ruleSet); err != nil {
+		t.Fatalf("NormalizeRuleSet() error = %v", err)
+	}
+	assertEqual(t, ruleSet.Name, "streaming", "trimmed name")
+	assertEqual(t, ruleSet.Policy, models.RulePolicyProxy, "lowercase policy")
+	want := []string{"www.example.com", "netflix.com", "203.0.113.7/32", "2001:db8::/32", "CN", "Telegram.exe"}
+	for i, value := range want {
+		assertEqual(t, ruleSet.Rules[i].Value, value, "normalized "+ruleSet.Rules[i].Type)
+	}
+
+	invalid := []models.RuleSet{
+		{Name: "bad name", Policy: "proxy"},
+		{Name: "geoip-cn", Policy: "proxy"},
+		{Name: "ads", Policy: "block"},
+		{Name: "ads", Policy: "reject", Rules: []models.RuleEntry{{Type: "url-regex", Value: "ad"}}},
+		{Name: "ads", Policy: "reject", Rules: []models.RuleEntry{{Type: "domain", Value: "a.com,REJECT"}}},
+		{Name: "ads", Policy: "reject", Rules: []models.RuleEntry{{Type: "ip-cidr", Value: "300.0.0.0/8"}}},
+		{Name: "ads", Policy: "reject", Rules: []models.RuleEntry{{Type: "geoip", Value: "CHN"}}},
+		{Name: "ads", Policy: "reject", Rules: []models.RuleEntry{{Type: "domain-suffix", Value: "."}}},
+	}
+	for i := range invalid {
+		if err := NormalizeRuleSet(&invalid[i]); err == nil {
+			t.Errorf("case %d: NormalizeRuleSet() accepted invalid rule set", i)
+		}
+	}
+}
+
+func TestGenerateRuleSetContent(t *testing.T) {
+	rules := []models.RuleEntry{
+		{Type: models.RuleDomainSuffix, Value: "example.com"},
+		{Type: models.RuleIPCIDR, Value: "2001:db8::/32"},
+		{Type: models.RuleGeoIP, Value: "CN"},
+		{Type: models.RuleProcess, Value: "curl"},
+		{Type: models.RuleDomainSuffix, Value: "example.org"},
+		{Type: models.RuleGeoIP, Value: "CN"},
+	}
+
+	assertEqual(t, GenerateClashRuleProvider(rules), "payload:\n"+
+		"  - DOMAIN-SUFFIX,example.com\n"+
+		"  - IP-CIDR6,2001:db8::/32\n"+
+		"  - GEOIP,CN\n"+
+		"  - PROCESS-NAME,curl\n"+
+		"  - DOMAIN-SUFFIX,example.org\n"+
+		"  - GEOIP,CN\n", "clash payload")
+
+	assertEqual(t, GenerateSurgeRuleSet(rules), "DOMAIN-SUFFIX,example.com\n"+
+		"IP-CIDR6,2001:db8::/32\n"+
+		"GEOIP,CN\n"+
+		"PROCESS-NAME,curl\n"+
+		"DOMAIN-SUFFIX,example.org\n"+
+		"GEOIP,CN\n", "surge list")
+
+	content, err := GenerateSingboxRuleSet(rules)
+	if err != nil {
+		t.Fatalf("GenerateSingboxRuleSet() error = %v", err)
+	}
+	var ruleSet struct {
+		Version int                   `json:"version"`
+		Rules   []map[string][]string `json:"rules"`
+	}
+	if err := json.Unmarshal([]byte(content), &ruleSet); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	assertEqual(t, ruleSet.Version, 1, "singbox version")
+	assertEqual(t, len(ruleSet.Rules), 3, "one rule per field, geoip skipped")
+	assertEqual(t, len(ruleSet.Rules[0]["domain_suffix"]), 2, "domain suffixes grouped")
+	assertEqual(t, ruleSet.Rules[2]["process_name"][0], "curl", "process rule separate")
+
+	codes := RuleSetGeoIPCodes(rules)
+	assertEqual(t, len(codes), 1, "geoip codes deduplicated")
+	assertEqual(t, HasSingboxRules([]models.RuleEntry{{Type: models.RuleGeoIP, Value: "CN"}}), false, "geoip only")
+}
@@ -15,6 +15,7 @@ var stopChan chan struct{}
 func InitScheduler() {
 	stopChan = make(chan struct{})
 	go startScheduler()
+	go startHealthChecker()
 	utils.Info("定时任务调度器已启动")
 }
 
@@ -31,8 +32,9 @@ func StopScheduler() {
 func startScheduler() {
 	// 初始检查设置并启动定时器
 	updateScheduler()
+	PruneAccessLogs()
 
-	// 每小时检查一次设置变更
+	// 每小时检查一次设置变更，并清理访问日志
 	settingCheckTicker := time.NewTicker(1 * time.Hour)
 	defer settingCheckTicker.Stop()
 
@@ -40,6 +42,32 @@ func startScheduler() {
 		select {
 		case <-settingCheckTicker.C:
 			updateScheduler()
+			PruneAccessLogs()
+		case <-stopChan:
+			return
+		}
+	}
+}
+
+// startHealthChecker 按设置的间隔定期探测所有节点，每轮结束后重新读取设置
+func startHealthChecker() {
+	// 启动后稍作等待，避免与初始化任务争抢资源
+	timer := time.NewTimer(1 * time.Minute)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			if models.GetSetting(models.SettingHealthCheckEnabled, "true") != "false" {
+				if _, err := RunHealthChecks(); err != nil && err != ErrHealthCheckRunning {
+					utils.Error("健康检查失败: %v", err)
+				}
+			}
+			interval := settingInt(models.SettingHealthCheckInterval, DefaultHealthCheckInterval)
+			if interval <= 0 {
+				interval = DefaultHealthCheckInterval
+			}
+			timer.Reset(time.Duration(interval) * time.Minute)
 		case <-stopChan:
 			return
 		}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"proxy-subscription/models"
+)
+
+// 输出排序策略
+const (
+	SortStrategyNone     = "none"     // 保持数据库中的顺序
+	SortStrategyLatency  = "latency"  // 按最近一次健康检查的延迟升序，未检查或失败的节点排在最后
+	SortStrategyCountry  = "country"  // 按显示名称中的国家代码排序，无法识别的排在最后
+	SortStrategyPriority = "priority" // 自定义节点优先，其次按订阅优先级从高到低
+	SortStrategyName     = "name"     // 按节点名称排序，名称中的数字按数值比较
+	SortStrategyManual   = "manual"   // 按节点上保存的手动顺序，未设置顺序的排在最后
+)
+
+// IsValidSortStrategy 检查排序策略是否受支持
+func IsValidSortStrategy(strategy string) bool {
+	switch strategy {
+	case SortStrategyNone, SortStrategyLatency, SortStrategyCountry, SortStrategyPriority, SortStrategyName, SortStrategyManual:
+		return true
+	default:
+		return false
+	}
+}
+
+// SortOptions 输出排序选项
+type SortOptions struct {
+	Strategy   string                   // 排序策略
+	Priorities map[uint]int             // 订阅ID到优先级的映射，priority策略使用
+	Health     map[string]HealthSummary // 健康汇总，latency策略使用
+	HealthKey  HealthKey                // 查找节点健康汇总的键，为nil时按探测地址
+}
+
+// SortProxies 按策略对节点排序，排序键相同的节点保持原有顺序
+func SortProxies(proxies []models.Proxy, options SortOptions) []models.Proxy {
+	var less func(a, b models.Proxy) bool
+	switch options.Strategy {
+	case SortStrategyLatency:
+		less = func(a, b models.Proxy) bool {
+			latencyA, okA := measuredLatency(a, options.Health, options.HealthKey)
+			latencyB, okB := measuredLatency(b, options.Health, options.HealthKey)
+			if okA != okB {
+				return okA
+			}
+			return latencyA < latencyB
+		}
+	case SortStrategyCountry:
+		// 国家代码只取决于名称，预先计算避免每次比较都重新匹配
+		codes := make(map[string]string, len(proxies))
+		for _, proxy := range proxies {
+			if _, exists := codes[proxy.Name]; !exists {
+				codes[proxy.Name] = proxy.NameCountryCode()
+			}
+		}
+		less = func(a, b models.Proxy) bool {
+			codeA, codeB := codes[a.Name], codes[b.Name]
+			if (codeA == "") != (codeB == "") {
+				return codeA != ""
+			}
+			return codeA < codeB
+		}
+	case SortStrategyPriority:
+		less = func(a, b models.Proxy) bool {
+			if a.IsCustom != b.IsCustom {
+				return a.IsCustom
+			}
+			return options.Priorities[a.SubscriptionID] > options.Priorities[b.SubscriptionID]
+		}
+	case SortStrategyName:
+		less = func(a, b models.Proxy) bool {
+			return naturalLess(a.Name, b.Name)
+		}
+	case SortStrategyManual:
+		less = func(a, b models.Proxy) bool {
+			if (a.SortOrder > 0) != (b.SortOrder > 0) {
+				return a.SortOrder > 0
+			}
+			return a.SortOrder < b.SortOrder
+		}
+	default:
+		return proxies
+	}
+
+	sorted := make([]models.Proxy, len(proxies))
+	copy(sorted, proxies)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return less(sorted[i], sorted[j])
+	})
+	return sorted
+}
+
+// measuredLatency 返回节点最近一次成功探测的延迟
+func measuredLatency(proxy models.Proxy, health map[string]HealthSummary, key HealthKey) (int, bool) {
+	summary, exists := health[key.of(proxy)]
+	if !exists || !summary.Success {
+		return 0, false
+	}
+	return summary.LatencyMs, true
+}
+
+// naturalLess 忽略大小写比较名称，连续数字按数值比较，使"节点2"排在"节点10"之前
+func naturalLess(a, b string) bool {
+	ra, rb := []rune(strings.ToLower(a)), []rune(strings.ToLower(b))
+	i, j := 0, 0
+	for i < len(ra) && j < len(rb) {
+		if unicode.IsDigit(ra[i]) && unicode.IsDigit(rb[j]) {
+			startA, startB := i, j
+			for i < len(ra) && unicode.IsDigit(ra[i]) {
+				i++
+			}
+			for j < len(rb) && unicode.IsDigit(rb[j]) {
+				j++
+			}
+			numberA := strings.TrimLeft(string(ra[startA:i]), "0")
+			numberB := strings.TrimLeft(string(rb[startB:j]), "0")
+			if len(numberA) != len(numberB) {
+				return len(numberA) < len(numberB)
+			}
+			if numberA != numberB {
+				return numberA < numberB
+			}
+			continue
+		}
+		if ra[i] != rb[j] {
+			return ra[i] < rb[j]
+		}
+		i++
+		j++
+	}
+	return len(ra)-i < len(rb)-j
+}
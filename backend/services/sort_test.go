@@ -0,0 +1,105 @@
+package services
+
+import (
+	"testing"
+
+	"proxy-subscription/models"
+)
+
+func proxyNames(proxies []models.Proxy) []string {
+	names := make([]string, 0, len(proxies))
+	for _, proxy := range proxies {
+		names = append(names, proxy.Name)
+	}
+	return names
+}
+
+func assertNames(t *testing.T, proxies []models.Proxy, want []string, label string) {
+	t.Helper()
+	got := proxyNames(proxies)
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %v, want %v", label, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("%s: got %v, want %v", label, got, want)
+		}
+	}
+}
+
+func TestSortProxiesByLatency(t *testing.T) {
+	proxies := []models.Proxy{
+		{Name: "unchecked", Server: "a.example.com", Port: 443},
+		{Name: "slow", Server: "b.example.com", Port: 443},
+		{Name: "failed", Server: "c.example.com", Port: 443},
+		{Name: "fast", Server: "d.example.com", Port: 443},
+		{Name: "fast 2", Server: "e.example.com", Port: 443},
+	}
+	health := map[string]HealthSummary{
+		"b.example.com:443": {Success: true, LatencyMs: 300},
+		"c.example.com:443": {Success: false, LatencyMs: 5},
+		"d.example.com:443": {Success: true, LatencyMs: 40},
+		"e.example.com:443": {Success: true, LatencyMs: 40},
+	}
+
+	sorted := SortProxies(proxies, SortOptions{Strategy: SortStrategyLatency, Health: health})
+	assertNames(t, sorted, []string{"fast", "fast 2", "slow", "unchecked", "failed"}, "latency order")
+	assertEqual(t, proxies[0].Name, "unchecked", "input untouched")
+}
+
+func TestSortProxiesByCountry(t *testing.T) {
+	proxies := []models.Proxy{
+		{Name: "unknown"},
+		{Name: "美国 01"},
+		{Name: "香港 01"},
+		{Name: "日本 01"},
+		{Name: "香港 02"},
+	}
+
+	sorted := SortProxies(proxies, SortOptions{Strategy: SortStrategyCountry})
+	assertNames(t, sorted, []string{"香港 01", "香港 02", "日本 01", "美国 01", "unknown"}, "country order")
+}
+
+func TestSortProxiesByPriority(t *testing.T) {
+	proxies := []models.Proxy{
+		{Name: "low", SubscriptionID: 1},
+		{Name: "high", SubscriptionID: 2},
+		{Name: "custom", IsCustom: true},
+		{Name: "low 2", SubscriptionID: 1},
+		{Name: "unset", SubscriptionID: 3},
+	}
+	priorities := map[uint]int{1: 1, 2: 10}
+
+	sorted := SortProxies(proxies, SortOptions{Strategy: SortStrategyPriority, Priorities: priorities})
+	assertNames(t, sorted, []string{"custom", "high", "low", "low 2", "unset"}, "priority order")
+}
+
+func TestSortProxiesByName(t *testing.T) {
+	proxies := []models.Proxy{
+		{Name: "节点10"},
+		{Name: "节点2"},
+		{Name: "b"},
+		{Name: "A"},
+		{Name: "节点02"},
+		{Name: "节点"},
+	}
+
+	sorted := SortProxies(proxies, SortOptions{Strategy: SortStrategyName})
+	assertNames(t, sorted, []string{"A", "b", "节点", "节点2", "节点02", "节点10"}, "name order")
+}
+
+func TestSortProxiesManualAndNone(t *testing.T) {
+	proxies := []models.Proxy{
+		{Name: "unset"},
+		{Name: "third", SortOrder: 3},
+		{Name: "first", SortOrder: 1},
+		{Name: "unset 2"},
+		{Name: "second", SortOrder: 2},
+	}
+
+	sorted := SortProxies(proxies, SortOptions{Strategy: SortStrategyManual})
+	assertNames(t, sorted, []string{"first", "second", "third", "unset", "unset 2"}, "manual order")
+
+	sorted = SortProxies(proxies, SortOptions{Strategy: SortStrategyNone})
+	assertNames(t, sorted, []string{"unset", "third", "first", "unset 2", "second"}, "none keeps order")
+}
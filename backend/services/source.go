@@ -0,0 +1,98 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"proxy-subscription/models"
+)
+
+// maxSubscriptionFileSize 本地订阅文件的最大大小
+const maxSubscriptionFileSize = 10 << 20
+
+// loadSubscriptionContent 按订阅来源读取原始内容
+func loadSubscriptionContent(subscription *models.Subscription) (string, error) {
+	switch subscription.SourceType {
+	case models.SubscriptionSourceInline:
+		return subscription.Content, nil
+	case models.SubscriptionSourceFile:
+		return readSubscriptionFile(subscription.URL)
+	case "", models.SubscriptionSourceURL:
+		return fetchSubscriptionContent(subscription.URL)
+	default:
+		return "", fmt.Errorf("不支持的订阅来源: %s", subscription.SourceType)
+	}
+}
+
+// ResolveSubscriptionFile 将 file:// 地址解析为数据目录下的文件路径，
+// 相对路径基于数据目录，指向数据目录之外的路径（包括通过符号链接）会被拒绝
+func ResolveSubscriptionFile(fileURL string) (string, error) {
+	if !strings.HasPrefix(fileURL, "file://") {
+		return "", errors.New("本地订阅地址必须以 file:// 开头")
+	}
+	path := strings.TrimPrefix(fileURL, "file://")
+	if path == "" {
+		return "", errors.New("本地订阅文件路径不能为空")
+	}
+
+	dataDir, err := models.DataDir()
+	if err != nil {
+		return "", err
+	}
+	dataDir, err = filepath.Abs(dataDir)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dataDir, path)
+	}
+
+	resolvedDir, err := filepath.EvalSymlinks(dataDir)
+	if err != nil {
+		return "", fmt.Errorf("数据目录不可用: %w", err)
+	}
+	resolvedPath, err := filepath.EvalSymlinks(filepath.Clean(path))
+	if err != nil {
+		return "", fmt.Errorf("本地订阅文件不存在: %s", fileURL)
+	}
+	rel, err := filepath.Rel(resolvedDir, resolvedPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.New("本地订阅文件必须位于数据目录下")
+	}
+	return resolvedPath, nil
+}
+
+// readSubscriptionFile 读取数据目录下的本地订阅文件
+func readSubscriptionFile(fileURL string) (string, error) {
+	path, err := ResolveSubscriptionFile(fileURL)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("打开本地订阅文件失败: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("读取本地订阅文件失败: %w", err)
+	}
+	if info.IsDir() {
+		return "", errors.New("本地订阅路径是目录")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSubscriptionFileSize+1))
+	if err != nil {
+		return "", fmt.Errorf("读取本地订阅文件失败: %w", err)
+	}
+	if len(data) > maxSubscriptionFileSize {
+		return "", errors.New("本地订阅文件过大")
+	}
+	return string(data), nil
+}
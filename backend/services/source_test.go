@@ -0,0 +1,47 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"proxy-subscription/models"
+)
+
+func TestLoadSubscriptionContentFromFile(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv("DATA_DIR", dataDir)
+	if err := os.MkdirAll(filepath.Join(dataDir, "nodes"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "nodes", "static.txt"), []byte(sampleAnyTLSLink), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(t.TempDir(), "outside.txt")
+	if err := os.WriteFile(outside, []byte(sampleAnyTLSLink), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dataDir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fileURL := range []string{"file://nodes/static.txt", "file://" + filepath.Join(dataDir, "nodes", "static.txt")} {
+		content, err := loadSubscriptionContent(&models.Subscription{SourceType: models.SubscriptionSourceFile, URL: fileURL})
+		if err != nil {
+			t.Fatalf("loadSubscriptionContent(%q) error = %v", fileURL, err)
+		}
+		assertEqual(t, content, sampleAnyTLSLink, fileURL)
+	}
+
+	for _, fileURL := range []string{"file://../outside.txt", "file://" + outside, "file://link.txt", "file://missing.txt", "file://", "nodes/static.txt"} {
+		if _, err := ResolveSubscriptionFile(fileURL); err == nil {
+			t.Errorf("ResolveSubscriptionFile(%q) error = nil, want error", fileURL)
+		}
+	}
+
+	content, err := loadSubscriptionContent(&models.Subscription{SourceType: models.SubscriptionSourceInline, Content: sampleTuicLink})
+	if err != nil {
+		t.Fatalf("loadSubscriptionContent(inline) error = %v", err)
+	}
+	assertEqual(t, content, sampleTuicLink, "inline content")
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -20,28 +21,34 @@ import (
 	"proxy-subscription/utils"
 )
 
-// RefreshSubscription 刷新订阅内容
-func RefreshSubscription(subscription *models.Subscription) error {
-	utils.Info("开始获取订阅内容 ID=%d, URL=%s", subscription.ID, subscription.URL)
+// RefreshSubscription 刷新订阅内容，每次刷新的结果和解析报告都会写入刷新记录
+func RefreshSubscription(subscription *models.Subscription) (err error) {
+	refreshLog := models.RefreshLog{SubscriptionID: subscription.ID}
+	defer func() {
+		recordRefreshLog(refreshLog, err)
+	}()
 
 	// 获取订阅内容
-	content, err := fetchSubscriptionContent(subscription.URL)
+	content, err := loadSubscriptionContent(subscription)
 	if err != nil {
-		utils.Error("获取订阅内容失败 ID=%d, URL=%s, 错误: %v", subscription.ID, subscription.URL, err)
+		utils.Error("获取订阅内容失败 ID=%d, 来源=%s, URL=%s, 错误: %v", subscription.ID, subscription.SourceType, subscription.URL, err)
 		return fmt.Errorf("获取订阅内容失败: %w", err)
 	}
-
-	utils.Info("订阅内容获取成功 ID=%d, 内容长度=%d", subscription.ID, len(content))
+	refreshLog.ContentLength = len(content)
 
 	// 解析订阅内容
-	utils.Info("开始解析订阅内容 ID=%d, Type=%s", subscription.ID, subscription.Type)
-	proxies, err := parseSubscriptionContent(content, subscription.Type)
+	result, err := parseSubscriptionContent(content, subscription.Type)
+	applyParseResult(&refreshLog, result)
 	if err != nil {
 		utils.Error("解析订阅内容失败 ID=%d, Type=%s, 错误: %v", subscription.ID, subscription.Type, err)
 		return fmt.Errorf("解析订阅内容失败: %w", err)
 	}
+	proxies := result.Proxies
 
-	utils.Info("订阅内容解析成功 ID=%d, 解析出 %d 个代理节点", subscription.ID, len(proxies))
+	// 按本地GeoIP数据库查询节点实际位置，没有数据库时跳过
+	if err := ApplyGeoLocations(context.Background(), proxies); err != nil && !errors.Is(err, ErrGeoIPUnavailable) {
+		utils.Warn("查询节点位置失败 ID=%d, 错误: %v", subscription.ID, err)
+	}
 
 	// 开始事务
 	tx := models.DB.Begin()
@@ -68,8 +75,37 @@ func RefreshSubscription(subscription *models.Subscription) error {
 		manualSourceKeys[sourceKey] = struct{}{}
 	}
 
+	// 刷新会重建节点，记录手动排序序号以便按节点标识恢复
+	var sortedProxies []models.Proxy
+	if err := tx.Select("source_key", "sort_order").
+		Where("subscription_id = ? AND (manual_override = ? OR manual_override IS NULL) AND sort_order > ?", subscription.ID, false, 0).
+		Find(&sortedProxies).Error; err != nil {
+		tx.Rollback()
+		utils.Error("读取节点排序失败 ID=%d, 错误: %v", subscription.ID, err)
+		return fmt.Errorf("读取节点排序失败: %w", err)
+	}
+	sortOrders := make(map[string]int, len(sortedProxies))
+	for _, proxy := range sortedProxies {
+		if proxy.SourceKey != "" {
+			sortOrders[proxy.SourceKey] = proxy.SortOrder
+		}
+	}
+
+	// 记录被重建节点的标识，以便迁移链式关系
+	var rebuiltProxies []models.Proxy
+	if err := tx.Select("id", "source_key").
+		Where("subscription_id = ? AND (manual_override = ? OR manual_override IS NULL)", subscription.ID, false).
+		Find(&rebuiltProxies).Error; err != nil {
+		tx.Rollback()
+		utils.Error("读取节点标识失败 ID=%d, 错误: %v", subscription.ID, err)
+		return fmt.Errorf("读取节点标识失败: %w", err)
+	}
+	oldKeys := make(map[uint]string, len(rebuiltProxies))
+	for _, proxy := range rebuiltProxies {
+		oldKeys[proxy.ID] = proxy.SourceKey
+	}
+
 	// 删除旧的代理节点
-	utils.Info("删除旧的代理节点 ID=%d", subscription.ID)
 	if err := tx.Where("subscription_id = ? AND (manual_override = ? OR manual_override IS NULL)", subscription.ID, false).Delete(&models.Proxy{}).Error; err != nil {
 		tx.Rollback()
 		utils.Error("删除旧代理节点失败 ID=%d, 错误: %v", subscription.ID, err)
@@ -77,8 +113,7 @@ func RefreshSubscription(subscription *models.Subscription) error {
 	}
 
 	// 添加新的代理节点
-	utils.Info("开始添加新的代理节点 ID=%d, 数量=%d", subscription.ID, len(proxies))
-
+	newIDs := make(map[string]uint, len(proxies))
 	for i, proxy := range proxies {
 		proxy.SubscriptionID = subscription.ID
 		proxy.IsCustom = false
@@ -87,17 +122,29 @@ func RefreshSubscription(subscription *models.Subscription) error {
 		if _, exists := manualSourceKeys[proxy.SourceKey]; exists {
 			continue
 		}
+		proxy.SortOrder = sortOrders[proxy.SourceKey]
 		if err := tx.Create(&proxy).Error; err != nil {
 			tx.Rollback()
 			utils.Error("添加代理节点失败 ID=%d, 节点索引=%d, 节点名称=%s, 错误: %v", subscription.ID, i, proxy.Name, err)
 			return fmt.Errorf("添加代理节点失败: %w", err)
 		}
+		newIDs[proxy.SourceKey] = proxy.ID
 	}
 
-	utils.Info("代理节点添加成功 ID=%d, 成功添加 %d 个节点", subscription.ID, len(proxies))
+	if err := RemapProxyChains(tx, oldKeys, newIDs); err != nil {
+		tx.Rollback()
+		utils.Error("迁移链式代理失败 ID=%d, 错误: %v", subscription.ID, err)
+		return fmt.Errorf("迁移链式代理失败: %w", err)
+	}
+	if err := RemapHealthChecks(tx, oldKeys, newIDs); err != nil {
+		tx.Rollback()
+		utils.Error("迁移协议测试记录失败 ID=%d, 错误: %v", subscription.ID, err)
+		return fmt.Errorf("迁移协议测试记录失败: %w", err)
+	}
 
-	// 更新订阅的最后更新时间
+	// 更新订阅的最后更新时间和识别出的格式
 	subscription.LastUpdated = time.Now()
+	subscription.DetectedFormat = result.Format
 	if err := tx.Save(subscription).Error; err != nil {
 		tx.Rollback()
 		utils.Error("更新订阅最后更新时间失败 ID=%d, 错误: %v", subscription.ID, err)
@@ -110,19 +157,23 @@ func RefreshSubscription(subscription *models.Subscription) error {
 		return fmt.Errorf("提交事务失败: %w", err)
 	}
 
-	utils.Info("订阅刷新完成 ID=%d, 成功刷新 %d 个代理节点", subscription.ID, len(proxies))
+	utils.Info("订阅刷新完成 ID=%d, 格式=%s, 节点=%d, 解析失败=%d 行", subscription.ID, result.Format, len(proxies), len(result.Errors))
 	return nil
 }
 
-// fetchSubscriptionContent 获取订阅内容
+// fetchSubscriptionContent 使用默认选项获取订阅内容
 func fetchSubscriptionContent(subscriptionURL string) (string, error) {
-	utils.Info("开始HTTP请求获取订阅内容 URL=%s", subscriptionURL)
+	result, err := FetchSubscription(subscriptionURL, FetchOptions{})
+	return result.Content, err
+}
 
+// FetchSubscription 按选项获取订阅内容，并解析响应中的流量信息
+func FetchSubscription(subscriptionURL string, options FetchOptions) (FetchResult, error) {
 	// 创建HTTP请求
 	req, err := http.NewRequest("GET", subscriptionURL, nil)
 	if err != nil {
 		utils.Error("创建HTTP请求失败 URL=%s, 错误: %v", subscriptionURL, err)
-		return "", fmt.Errorf("创建HTTP请求失败: %w", err)
+		return FetchResult{}, fmt.Errorf("创建HTTP请求失败: %w", err)
 	}
 
 	// 设置请求头，模拟真实浏览器请求
@@ -133,14 +184,20 @@ func fetchSubscriptionContent(subscriptionURL string) (string, error) {
 	req.Header.Set("Connection", "keep-alive")
 	req.Header.Set("Cache-Control", "no-cache")
 	req.Header.Set("Pragma", "no-cache")
+	if options.UserAgent != "" {
+		req.Header.Set("User-Agent", options.UserAgent)
+	}
+	for key, value := range options.Headers {
+		req.Header.Set(key, value)
+	}
 
-	// 如果URL包含域名，设置Referer
-	if parsedURL, err := url.Parse(subscriptionURL); err == nil {
+	// 如果URL包含域名且未指定Referer，设置Referer
+	if parsedURL, err := url.Parse(subscriptionURL); err == nil && req.Header.Get("Referer") == "" {
 		req.Header.Set("Referer", fmt.Sprintf("%s://%s/", parsedURL.Scheme, parsedURL.Host))
 	}
 
 	client := &http.Client{
-		Timeout: 30 * time.Second,
+		Timeout: options.timeout(),
 		// 不自动跟随重定向，避免丢失请求头
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			// 在重定向时保持请求头
@@ -152,19 +209,20 @@ func fetchSubscriptionContent(subscriptionURL string) (string, error) {
 			return nil
 		},
 	}
+	if options.PublicOnly {
+		client.Transport = publicOnlyTransport()
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
 		utils.Error("HTTP请求失败 URL=%s, 错误: %v", subscriptionURL, err)
-		return "", fmt.Errorf("HTTP请求失败: %w", err)
+		return FetchResult{}, fmt.Errorf("HTTP请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
-	utils.Info("HTTP请求成功 URL=%s, 状态码=%d", subscriptionURL, resp.StatusCode)
-
 	if resp.StatusCode != http.StatusOK {
-		// 读取响应体以获取更多错误信息
-		bodyBytes, _ := io.ReadAll(resp.Body)
+		// 读取响应体开头部分以获取更多错误信息
+		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
 		bodyStr := string(bodyBytes)
 		if len(bodyStr) > 200 {
 			bodyStr = bodyStr[:200] + "..."
@@ -188,431 +246,42 @@ func fetchSubscriptionContent(subscriptionURL string) (string, error) {
 		}
 
 		utils.Error("HTTP状态码异常 URL=%s, 状态码=%d, 状态=%s, 响应体: %s", subscriptionURL, resp.StatusCode, resp.Status, bodyStr)
-		return "", fmt.Errorf(errorMsg)
+		// 保留状态码，便于预览和转换接口返回诊断信息
+		return FetchResult{StatusCode: resp.StatusCode, ContentType: resp.Header.Get("Content-Type")}, errors.New(errorMsg)
 	}
 
-	body, err := io.ReadAll(resp.Body)
-
+	body, err := readLimitedBody(resp.Body)
 	if err != nil {
 		utils.Error("读取响应体失败 URL=%s, 错误: %v", subscriptionURL, err)
-		return "", fmt.Errorf("读取响应体失败: %w", err)
+		return FetchResult{StatusCode: resp.StatusCode}, fmt.Errorf("读取响应体失败: %w", err)
 	}
 
 	// 检查响应是否被压缩，如果是则解压
 	contentEncoding := resp.Header.Get("Content-Encoding")
 	if contentEncoding == "gzip" || contentEncoding == "x-gzip" {
-		utils.Info("检测到gzip压缩响应，开始解压，压缩数据长度=%d", len(body))
 		reader, err := gzip.NewReader(bytes.NewReader(body))
 		if err != nil {
 			utils.Warn("创建gzip读取器失败: %v，使用原始内容", err)
 		} else {
-			decompressed, err := io.ReadAll(reader)
+			decompressed, err := readLimitedBody(reader)
 			reader.Close()
 			if err != nil {
 				utils.Warn("gzip解压失败: %v，使用原始内容", err)
 			} else {
-				utils.Info("gzip解压成功，解压后长度=%d", len(decompressed))
 				body = decompressed
 			}
 		}
 	}
 
-	utils.Info("订阅内容获取成功 URL=%s, 内容长度=%d", subscriptionURL, len(body))
-	return string(body), nil
-}
-
-// parseSubscriptionContent 解析订阅内容
-func parseSubscriptionContent(content string, subType string) ([]models.Proxy, error) {
-	// 尝试解码Base64内容
-	decoded, err := utils.DecodeBase64(content)
-	if err == nil && len(decoded) > 0 {
-		// Base64解码成功，检查是否需要解压
-		var finalContent []byte = decoded
-
-		// 检查是否是gzip压缩的数据（gzip文件头：0x1f 0x8b）
-		if len(decoded) >= 2 && decoded[0] == 0x1f && decoded[1] == 0x8b {
-			utils.Info("检测到gzip压缩数据，开始解压，压缩数据长度=%d", len(decoded))
-			reader, err := gzip.NewReader(bytes.NewReader(decoded))
-			if err == nil {
-				decompressed, err := io.ReadAll(reader)
-				reader.Close()
-				if err == nil && len(decompressed) > 0 {
-					utils.Info("gzip解压成功，解压后长度=%d", len(decompressed))
-					finalContent = decompressed
-				} else {
-					utils.Warn("gzip解压失败: %v，使用原始解码内容", err)
-				}
-			} else {
-				utils.Warn("创建gzip读取器失败: %v，使用原始解码内容", err)
-			}
-		}
-
-		// 尝试将解码后的内容转换为字符串
-		decodedStr := string(finalContent)
-
-		// 检查解码后的内容是否看起来像有效的代理链接或配置
-		// 检查是否包含可打印字符（至少70%是可打印字符，降低阈值以处理更多情况）
-		printableCount := 0
-		for _, b := range finalContent {
-			if b >= 32 && b < 127 || b == 9 || b == 10 || b == 13 {
-				printableCount++
-			}
-		}
-		printableRatio := float64(printableCount) / float64(len(finalContent))
-
-		utils.Info("Base64解码后内容分析: 长度=%d, 可打印字符比例=%.2f%%, 前100字符: %s",
-			len(decodedStr), printableRatio*100, getPreview(decodedStr, 100))
-
-		// 如果可打印字符比例足够高，或者包含明显的代理链接标识，使用解码后的内容
-		if printableRatio > 0.7 || strings.Contains(decodedStr, "://") ||
-			strings.HasPrefix(strings.TrimSpace(decodedStr), "{") ||
-			strings.HasPrefix(strings.TrimSpace(decodedStr), "[") {
-			utils.Info("使用Base64解码后的内容进行解析")
-			content = decodedStr
-		} else {
-			// 解码后的内容看起来不像有效格式
-			utils.Warn("Base64解码后的内容格式异常（可打印字符比例=%.2f%%），尝试其他解析方式", printableRatio*100)
-			// 如果解压后的内容可打印字符比例很低，可能是每行都是Base64编码的链接
-			// 尝试逐行Base64解码（使用字节数组，因为可能是二进制数据）
-			utils.Info("尝试对解压后的内容进行逐行Base64解码（字节模式）")
-			if proxies, err := parseLineByLineBase64FromBytes(finalContent, subType); err == nil && len(proxies) > 0 {
-				return proxies, nil
-			}
-			// 尝试字符串模式的逐行Base64解码
-			utils.Info("尝试对解压后的内容进行逐行Base64解码（字符串模式）")
-			if proxies, err := parseLineByLineBase64(decodedStr, subType); err == nil && len(proxies) > 0 {
-				return proxies, nil
-			}
-			// 如果原始内容看起来像Base64，尝试逐行Base64解码
-			if isBase64Like(content) {
-				utils.Info("原始内容看起来像Base64，尝试逐行Base64解码")
-				return parseLineByLineBase64(content, subType)
-			}
-			// 否则尝试直接解析原始内容（可能是纯文本格式）
-			utils.Info("尝试使用原始内容进行解析")
-		}
-	} else {
-		// Base64解码失败，可能是纯文本格式，使用原始内容
-		utils.Info("订阅内容Base64解码失败，使用原始内容，前100字符: %s", getPreview(content, 100))
-	}
-
-	// 根据订阅类型解析内容
-	switch subType {
-	case "v2ray":
-		return parseV2raySubscription(content)
-	case "ss":
-		return parseSSSubscription(content)
-	case "trojan":
-		return parseTrojanSubscription(content)
-	case "tuic", "anytls", "hysteria2":
-		return parseSpecificURISubscription(content, subType)
-	case "mixed":
-		return parseMixedSubscription(content)
-	case "sip002":
-		return parseSSSubscription(content) // SIP002是SS的一种标准格式
-	case "sip008":
-		return parseSIP008Subscription(content)
-	case "clash":
-		return parseClashSubscription(content)
-	case "surge":
-		return parseSurgeSubscription(content)
-	case "quantumult":
-		return parseQuantumultSubscription(content)
-	case "json":
-		return parseJSONSubscription(content)
-	default:
-		// 尝试自动检测类型
-		return autoDetectAndParse(content)
-	}
-}
-
-// isBase64Like 检查字符串是否看起来像Base64编码
-func isBase64Like(s string) bool {
-	if len(s) < 4 {
-		return false
-	}
-	// Base64只包含A-Z, a-z, 0-9, +, /, = 和可能的空格/换行
-	validChars := 0
-	for _, r := range s {
-		if (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') ||
-			(r >= '0' && r <= '9') || r == '+' || r == '/' || r == '=' ||
-			r == ' ' || r == '\n' || r == '\r' || r == '\t' {
-			validChars++
-		}
-	}
-	return float64(validChars)/float64(len(s)) > 0.9
-}
-
-// parseLineByLineBase64FromBytes 从字节数组逐行Base64解码并解析
-func parseLineByLineBase64FromBytes(data []byte, subType string) ([]models.Proxy, error) {
-	utils.Info("尝试从字节数组逐行Base64解码，数据长度=%d", len(data))
-	var allProxies []models.Proxy
-
-	// 按换行符分割（支持\n和\r\n）
-	lines := bytes.Split(data, []byte("\n"))
-	if len(lines) == 1 {
-		// 如果没有\n，尝试\r\n
-		lines = bytes.Split(data, []byte("\r\n"))
-	}
-
-	utils.Info("分割后共 %d 行", len(lines))
-
-	for i, lineBytes := range lines {
-		// 去除首尾空白字符
-		lineBytes = bytes.TrimSpace(lineBytes)
-		if len(lineBytes) == 0 {
-			continue
-		}
-
-		// 尝试将字节数组转换为字符串（可能是Base64编码的字符串）
-		lineStr := string(lineBytes)
-
-		// 检查这一行是否看起来像Base64（至少包含Base64字符）
-		base64Like := false
-		base64CharCount := 0
-		for _, b := range lineBytes {
-			if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') ||
-				(b >= '0' && b <= '9') || b == '+' || b == '/' || b == '=' {
-				base64CharCount++
-			}
-		}
-		base64Ratio := 0.0
-		if len(lineBytes) > 0 {
-			base64Ratio = float64(base64CharCount) / float64(len(lineBytes))
-			if base64Ratio > 0.8 {
-				base64Like = true
-			}
-		}
-
-		if !base64Like {
-			// 如果这一行不像Base64，跳过
-			if i < 3 {
-				previewLen := 50
-				if len(lineBytes) < previewLen {
-					previewLen = len(lineBytes)
-				}
-				utils.Info("行 %d 不像Base64编码，跳过，前%d字节(hex): %x", i+1, previewLen, lineBytes[:previewLen])
-			}
-			continue
-		}
-
-		// 尝试Base64解码这一行
-		decoded, err := utils.DecodeBase64(lineStr)
-		if err != nil {
-			// 如果这一行不是Base64，跳过
-			if i < 3 {
-				utils.Info("行 %d Base64解码失败: %v", i+1, err)
-			}
-			continue
-		}
-
-		// 检查解码后是否是gzip压缩
-		if len(decoded) >= 2 && decoded[0] == 0x1f && decoded[1] == 0x8b {
-			reader, err := gzip.NewReader(bytes.NewReader(decoded))
-			if err == nil {
-				decompressed, err := io.ReadAll(reader)
-				reader.Close()
-				if err == nil {
-					decoded = decompressed
-				}
-			}
-		}
-
-		decodedStr := string(decoded)
-		utils.Info("行 %d Base64解码成功，解码后长度=%d, 前100字符: %s", i+1, len(decodedStr), getPreview(decodedStr, 100))
-
-		// 尝试解析解码后的内容
-		proxies, err := autoDetectAndParse(decodedStr)
-		if err == nil && len(proxies) > 0 {
-			allProxies = append(allProxies, proxies...)
-		} else if err != nil {
-			utils.Warn("行 %d Base64解码后解析失败: %v", i+1, err)
-		}
-	}
-
-	if len(allProxies) > 0 {
-		utils.Info("从字节数组逐行Base64解码成功，解析出 %d 个代理节点", len(allProxies))
-		return allProxies, nil
-	}
-
-	return nil, fmt.Errorf("从字节数组逐行Base64解码未找到代理")
-}
-
-// parseLineByLineBase64 逐行Base64解码并解析
-func parseLineByLineBase64(content string, subType string) ([]models.Proxy, error) {
-	utils.Info("尝试逐行Base64解码（字符串模式）")
-	var allProxies []models.Proxy
-	lines := strings.Split(content, "\n")
-
-	for i, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		// 尝试Base64解码这一行
-		decoded, err := utils.DecodeBase64(line)
-		if err != nil {
-			// 如果这一行不是Base64，可能是纯文本链接，跳过
-			continue
-		}
-
-		// 检查解码后是否是gzip压缩
-		if len(decoded) >= 2 && decoded[0] == 0x1f && decoded[1] == 0x8b {
-			reader, err := gzip.NewReader(bytes.NewReader(decoded))
-			if err == nil {
-				decompressed, err := io.ReadAll(reader)
-				reader.Close()
-				if err == nil {
-					decoded = decompressed
-				}
-			}
-		}
-
-		decodedStr := string(decoded)
-		utils.Info("行 %d Base64解码成功，解码后长度=%d, 前100字符: %s", i+1, len(decodedStr), getPreview(decodedStr, 100))
-
-		// 尝试解析解码后的内容
-		proxies, err := autoDetectAndParse(decodedStr)
-		if err == nil && len(proxies) > 0 {
-			allProxies = append(allProxies, proxies...)
-		} else if err != nil {
-			utils.Warn("行 %d Base64解码后解析失败: %v", i+1, err)
-		}
-	}
-
-	if len(allProxies) > 0 {
-		utils.Info("逐行Base64解码成功，解析出 %d 个代理节点", len(allProxies))
-		return allProxies, nil
-	}
-
-	// 如果逐行解码失败，尝试整体解码后按换行分割
-	utils.Info("逐行Base64解码未找到代理，尝试整体解码")
-	return autoDetectAndParse(content)
-}
-
-// getPreview 获取内容预览，用于日志
-func getPreview(content string, maxLen int) string {
-	if len(content) <= maxLen {
-		return content
-	}
-	return content[:maxLen] + "..."
-}
-
-// getPreviewBytes 获取字节数组的十六进制预览，用于日志
-func getPreviewBytes(data []byte, maxLen int) string {
-	if len(data) <= maxLen {
-		return fmt.Sprintf("%x", data)
-	}
-	return fmt.Sprintf("%x...", data[:maxLen])
-}
-
-// parseV2raySubscription 解析V2Ray订阅
-func parseV2raySubscription(content string) ([]models.Proxy, error) {
-	var proxies []models.Proxy
-	lines := strings.Split(content, "\n")
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		// 处理vmess://开头的链接
-		if strings.HasPrefix(line, "vmess://") {
-			proxy, err := parseVmessLink(line)
-			if err != nil {
-				continue // 跳过解析失败的链接
-			}
-			proxies = append(proxies, proxy)
-		} else if strings.HasPrefix(line, "vless://") {
-			proxy, err := parseVlessLink(line)
-			if err != nil {
-				continue
-			}
-			proxies = append(proxies, proxy)
-		}
-	}
-
-	return proxies, nil
-}
-
-// parseSSSubscription 解析Shadowsocks订阅
-func parseSSSubscription(content string) ([]models.Proxy, error) {
-	var proxies []models.Proxy
-	lines := strings.Split(content, "\n")
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		// 处理ss://开头的链接
-		if strings.HasPrefix(line, "ss://") {
-			proxy, err := parseSSLink(line)
-			if err != nil {
-				continue // 跳过解析失败的链接
-			}
-			proxies = append(proxies, proxy)
-		}
-	}
-
-	return proxies, nil
-}
-
-// parseTrojanSubscription 解析Trojan订阅
-func parseTrojanSubscription(content string) ([]models.Proxy, error) {
-	var proxies []models.Proxy
-	lines := strings.Split(content, "\n")
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		// 处理trojan://开头的链接
-		if strings.HasPrefix(line, "trojan://") {
-			proxy, err := parseTrojanLink(line)
-			if err != nil {
-				continue // 跳过解析失败的链接
-			}
-			proxies = append(proxies, proxy)
-		}
-	}
-
-	return proxies, nil
-}
-
-func parseSpecificURISubscription(content string, proxyType string) ([]models.Proxy, error) {
-	var proxies []models.Proxy
-	lines := strings.Split(content, "\n")
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-		if proxyType == "tuic" && !strings.HasPrefix(line, "tuic://") {
-			continue
-		}
-		if proxyType == "anytls" && !strings.HasPrefix(line, "anytls://") {
-			continue
-		}
-		if proxyType == "hysteria2" && !strings.HasPrefix(line, "hysteria2://") && !strings.HasPrefix(line, "hy2://") {
-			continue
-		}
-		proxy, err := parseProxyURI(line)
-		if err != nil {
-			continue
-		}
-		proxies = append(proxies, proxy)
-	}
-
-	return proxies, nil
+	return FetchResult{
+		Content:     string(body),
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		UserInfo:    ParseSubscriptionUserInfo(resp.Header.Get("Subscription-Userinfo")),
+	}, nil
 }
 
+// hasExtendedProxyScheme 检查是否为扩展协议（tuic/anytls/hysteria2）链接
 func hasExtendedProxyScheme(value string) bool {
 	return strings.HasPrefix(value, "tuic://") ||
 		strings.HasPrefix(value, "anytls://") ||
@@ -620,216 +289,6 @@ func hasExtendedProxyScheme(value string) bool {
 		strings.HasPrefix(value, "hy2://")
 }
 
-// autoDetectAndParse 自动检测并解析订阅类型
-func autoDetectAndParse(content string) ([]models.Proxy, error) {
-	utils.Info("开始自动检测订阅类型，内容长度=%d", len(content))
-
-	// 检查是否为JSON格式
-	trimmed := strings.TrimSpace(content)
-	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
-		utils.Info("检测到JSON格式订阅")
-		return parseJSONSubscription(content)
-	}
-
-	// 检查是否为Clash配置
-	if strings.Contains(content, "proxies:") && (strings.Contains(content, "yaml") || strings.Contains(content, "rules:")) {
-		utils.Info("检测到Clash格式订阅")
-		return parseClashSubscription(content)
-	}
-
-	// 检查是否为Surge配置
-	if strings.Contains(content, "[Proxy]") || strings.Contains(content, "[Proxy Group]") {
-		utils.Info("检测到Surge格式订阅")
-		return parseSurgeSubscription(content)
-	}
-
-	// 检查是否为Quantumult配置
-	if strings.Contains(content, "shadowsocks=") || strings.Contains(content, "vmess=") || strings.Contains(content, "SERVER,") {
-		utils.Info("检测到Quantumult格式订阅")
-		return parseQuantumultSubscription(content)
-	}
-
-	// 检查内容是否是二进制数据（包含大量不可打印字符）
-	contentBytes := []byte(content)
-	printableCount := 0
-	for _, b := range contentBytes {
-		if b >= 32 && b < 127 || b == 9 || b == 10 || b == 13 {
-			printableCount++
-		}
-	}
-	printableRatio := float64(printableCount) / float64(len(contentBytes))
-
-	// 如果可打印字符比例很低，可能是二进制数据，尝试逐行Base64解码
-	if len(contentBytes) > 0 && printableRatio < 0.5 {
-		utils.Info("检测到内容可能是二进制数据（可打印字符比例=%.2f%%），尝试逐行Base64解码", printableRatio*100)
-		if proxies, err := parseLineByLineBase64FromBytes(contentBytes, ""); err == nil && len(proxies) > 0 {
-			return proxies, nil
-		}
-		utils.Warn("逐行Base64解码未找到代理，继续尝试其他解析方式")
-	}
-
-	// 逐行解析URI
-	utils.Info("尝试逐行解析URI格式订阅")
-	var proxies []models.Proxy
-	lines := strings.Split(content, "\n")
-
-	utils.Info("内容共 %d 行，开始解析", len(lines))
-
-	// 打印前5行的实际内容用于调试
-	nonEmptyLines := 0
-	for i, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if trimmed != "" {
-			nonEmptyLines++
-			if nonEmptyLines <= 5 {
-				utils.Info("第 %d 行内容预览（前100字符）: %s", i+1, getPreview(trimmed, 100))
-			}
-		}
-	}
-	utils.Info("非空行总数: %d", nonEmptyLines)
-
-	vmessCount := 0
-	ssCount := 0
-	trojanCount := 0
-	otherCount := 0
-	errorCount := 0
-
-	for i, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		var proxy models.Proxy
-		var err error
-
-		switch {
-		case strings.HasPrefix(line, "vmess://"):
-			vmessCount++
-			proxy, err = parseVmessLink(line)
-			if err != nil {
-				utils.Warn("解析vmess链接失败 行=%d, 错误: %v, 链接前50字符: %s", i+1, err, getPreview(line, 50))
-				errorCount++
-			}
-		case strings.HasPrefix(line, "vless://"):
-			proxy, err = parseVlessLink(line)
-			if err != nil {
-				utils.Warn("parse vless link failed line=%d, error=%v", i+1, err)
-				errorCount++
-			}
-		case strings.HasPrefix(line, "ss://"):
-			ssCount++
-			proxy, err = parseSSLink(line)
-			if err != nil {
-				utils.Warn("解析ss链接失败 行=%d, 错误: %v, 链接前50字符: %s", i+1, err, getPreview(line, 50))
-				errorCount++
-			}
-		case strings.HasPrefix(line, "trojan://"):
-			trojanCount++
-			proxy, err = parseTrojanLink(line)
-			if err != nil {
-				utils.Warn("解析trojan链接失败 行=%d, 错误: %v, 链接前50字符: %s", i+1, err, getPreview(line, 50))
-				errorCount++
-			}
-		case strings.HasPrefix(line, "tuic://"):
-			proxy, err = parseTuicLink(line)
-			if err != nil {
-				utils.Warn("解析tuic链接失败 行=%d, 错误: %v, 链接前50字符: %s", i+1, err, getPreview(line, 50))
-				errorCount++
-			}
-		case strings.HasPrefix(line, "anytls://"):
-			proxy, err = parseAnyTLSLink(line)
-			if err != nil {
-				utils.Warn("解析anytls链接失败 行=%d, 错误: %v, 链接前50字符: %s", i+1, err, getPreview(line, 50))
-				errorCount++
-			}
-		case strings.HasPrefix(line, "hysteria2://") || strings.HasPrefix(line, "hy2://"):
-			proxy, err = parseHysteria2Link(line)
-			if err != nil {
-				utils.Warn("解析hysteria2链接失败 行=%d, 错误: %v, 链接前50字符: %s", i+1, err, getPreview(line, 50))
-				errorCount++
-			}
-		case strings.HasPrefix(line, "ssr://"):
-			proxy, err = parseSSRLink(line)
-			if err != nil {
-				utils.Warn("解析ssr链接失败 行=%d, 错误: %v", i+1, err)
-				errorCount++
-			}
-		case strings.HasPrefix(line, "http://") || strings.HasPrefix(line, "https://"):
-			proxy, err = parseHTTPLink(line)
-			if err != nil {
-				utils.Warn("解析http链接失败 行=%d, 错误: %v", i+1, err)
-				errorCount++
-			}
-		case strings.HasPrefix(line, "socks://") || strings.HasPrefix(line, "socks5://"):
-			proxy, err = parseSOCKSLink(line)
-			if err != nil {
-				utils.Warn("解析socks链接失败 行=%d, 错误: %v", i+1, err)
-				errorCount++
-			}
-		default:
-			otherCount++
-			// 记录前10行不支持的内容，用于调试
-			if otherCount <= 10 {
-				utils.Info("跳过不支持的行 %d, 内容前100字符: %s", i+1, getPreview(line, 100))
-				// 尝试检查是否是Base64编码的链接
-				if decoded, err := utils.DecodeBase64(line); err == nil && len(decoded) > 0 {
-					decodedStr := string(decoded)
-					utils.Info("  该行可能是Base64编码，解码后前100字符: %s", getPreview(decodedStr, 100))
-					// 如果解码后看起来像代理链接，尝试解析
-					if strings.HasPrefix(decodedStr, "vmess://") ||
-						strings.HasPrefix(decodedStr, "vless://") ||
-						strings.HasPrefix(decodedStr, "ss://") ||
-						strings.HasPrefix(decodedStr, "trojan://") ||
-						hasExtendedProxyScheme(decodedStr) {
-						utils.Info("  检测到解码后是代理链接，尝试解析")
-						var decodedProxy models.Proxy
-						var decodedErr error
-						switch {
-						case strings.HasPrefix(decodedStr, "vmess://"):
-							vmessCount++
-							decodedProxy, decodedErr = parseVmessLink(decodedStr)
-						case strings.HasPrefix(decodedStr, "vless://"):
-							decodedProxy, decodedErr = parseVlessLink(decodedStr)
-						case strings.HasPrefix(decodedStr, "ss://"):
-							ssCount++
-							decodedProxy, decodedErr = parseSSLink(decodedStr)
-						case strings.HasPrefix(decodedStr, "trojan://"):
-							trojanCount++
-							decodedProxy, decodedErr = parseTrojanLink(decodedStr)
-						case strings.HasPrefix(decodedStr, "tuic://"):
-							decodedProxy, decodedErr = parseTuicLink(decodedStr)
-						case strings.HasPrefix(decodedStr, "anytls://"):
-							decodedProxy, decodedErr = parseAnyTLSLink(decodedStr)
-						case strings.HasPrefix(decodedStr, "hysteria2://") || strings.HasPrefix(decodedStr, "hy2://"):
-							decodedProxy, decodedErr = parseHysteria2Link(decodedStr)
-						}
-						if decodedErr == nil {
-							proxies = append(proxies, decodedProxy)
-							otherCount-- // 修正计数
-							utils.Info("  成功解析Base64编码的代理链接")
-							continue
-						} else {
-							utils.Warn("  解析Base64解码后的链接失败: %v", decodedErr)
-							errorCount++
-						}
-					}
-				}
-			}
-			continue // 跳过不支持的链接
-		}
-
-		if err == nil {
-			proxies = append(proxies, proxy)
-		}
-	}
-
-	utils.Info("解析完成: vmess=%d, ss=%d, trojan=%d, 其他=%d, 错误=%d, 成功解析=%d",
-		vmessCount, ssCount, trojanCount, otherCount, errorCount, len(proxies))
-
-	return proxies, nil
-}
-
 // parseVmessLink 解析Vmess链接
 func parseVmessLink(link string) (models.Proxy, error) {
 	// 解析vmess链接格式：vmess://base64(JSON配置)
@@ -1319,6 +778,97 @@ func parseHysteria2Link(link string) (models.Proxy, error) {
 	return proxy, nil
 }
 
+// parseHysteriaLink 解析Hysteria(v1)链接
+// 格式：hysteria://host:port?protocol=udp&auth=xxx&peer=sni&insecure=1&upmbps=100&downmbps=100&alpn=h3&obfsParam=xxx#name
+func parseHysteriaLink(link string) (models.Proxy, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return models.Proxy{}, fmt.Errorf("URL解析错误: %v", err)
+	}
+	if u.Host == "" {
+		return models.Proxy{}, errors.New("Hysteria链接格式错误：缺少主机地址")
+	}
+	host, portStr, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return models.Proxy{}, errors.New("Hysteria链接格式错误：缺少端口")
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return models.Proxy{}, fmt.Errorf("端口解析失败: %v", err)
+	}
+
+	query := u.Query()
+	up := firstQueryValue(query, "upmbps", "up")
+	down := firstQueryValue(query, "downmbps", "down")
+	if up == "" || down == "" {
+		return models.Proxy{}, errors.New("Hysteria链接格式错误：缺少上行或下行带宽")
+	}
+
+	name := u.Fragment
+	if name != "" {
+		if decodedName, err := url.QueryUnescape(name); err == nil {
+			name = decodedName
+		}
+	} else {
+		name = host
+	}
+	proxy := models.Proxy{
+		Type:          "hysteria",
+		Name:          name,
+		Server:        host,
+		Port:          port,
+		Password:      firstQueryValue(query, "auth", "auth_str"),
+		TLS:           true,
+		SNI:           firstQueryValue(query, "peer", "sni"),
+		ALPN:          query.Get("alpn"),
+		AllowInsecure: truthyQuery(query, "insecure", "allowInsecure", "skip-cert-verify"),
+	}
+	proxy.RawConfig = marshalQueryRawConfig(query, map[string]interface{}{
+		"protocol": query.Get("protocol"),
+		"up":       up,
+		"down":     down,
+		"obfs":     firstQueryValue(query, "obfsParam", "obfs-password"),
+	})
+	return proxy, nil
+}
+
+// parseWireGuardLink 解析WireGuard链接
+// 格式：wireguard://privateKey@host:port?publickey=xxx&presharedkey=xxx&address=10.0.0.2/32,fd00::2/128&mtu=1280&reserved=1,2,3#name
+func parseWireGuardLink(link string) (models.Proxy, error) {
+	if strings.HasPrefix(link, "wg://") {
+		link = "wireguard://" + strings.TrimPrefix(link, "wg://")
+	}
+	proxy, query, err := parseCredentialProxyURL(link, "wireguard", false)
+	if err != nil {
+		return models.Proxy{}, err
+	}
+
+	publicKey := firstQueryValue(query, "publickey", "public-key", "peer")
+	address := firstQueryValue(query, "address", "ip")
+	if publicKey == "" || address == "" {
+		return models.Proxy{}, errors.New("WireGuard链接格式错误：缺少对端公钥或本地地址")
+	}
+
+	rawConfig := map[string]interface{}{
+		"private_key":    proxy.Password,
+		"public_key":     publicKey,
+		"pre_shared_key": firstQueryValue(query, "presharedkey", "pre-shared-key"),
+		"reserved":       query.Get("reserved"),
+		"mtu":            query.Get("mtu"),
+	}
+	for _, addr := range strings.Split(address, ",") {
+		addr = strings.TrimSpace(addr)
+		if strings.Contains(addr, ":") {
+			rawConfig["ipv6"] = addr
+		} else if addr != "" {
+			rawConfig["ip"] = addr
+		}
+	}
+	proxy.Password = ""
+	proxy.RawConfig = marshalQueryRawConfig(nil, rawConfig)
+	return proxy, nil
+}
+
 func parseCredentialProxyURL(link string, proxyType string, hasUUID bool) (models.Proxy, url.Values, error) {
 	u, err := url.Parse(link)
 	if err != nil {
@@ -1432,12 +982,6 @@ func isIP(host string) bool {
 	return true
 }
 
-// parseMixedSubscription 解析混合类型订阅
-func parseMixedSubscription(content string) ([]models.Proxy, error) {
-	// 混合类型订阅实际上就是自动检测并解析
-	return autoDetectAndParse(content)
-}
-
 // parseSIP008Subscription 解析SIP008格式的Shadowsocks订阅
 // SIP008格式是一种JSON格式的SS订阅标准
 // 参考: https://shadowsocks.org/en/wiki/SIP008-Online-Configuration-Delivery.html
@@ -1489,7 +1033,7 @@ func parseClashSubscription(content string) ([]models.Proxy, error) {
 	// 使用简单的字符串处理方式解析YAML
 	// 注意：这是一个简化的实现，实际应该使用YAML解析库
 	var proxies []models.Proxy
-	lines := strings.Split(content, "\n")
+	lines := strings.Split(expandClashFlowProxies(content), "\n")
 
 	inProxies := false
 	var currentProxy *models.Proxy
@@ -1535,6 +1079,9 @@ func parseClashSubscription(content string) ([]models.Proxy, error) {
 		switch {
 		case strings.HasPrefix(line, "type:"):
 			currentProxy.Type = strings.TrimSpace(strings.TrimPrefix(line, "type:"))
+			if currentProxy.Type == "socks5" {
+				currentProxy.Type = "socks"
+			}
 		case strings.HasPrefix(line, "server:"):
 			currentProxy.Server = strings.TrimSpace(strings.TrimPrefix(line, "server:"))
 		case strings.HasPrefix(line, "port:"):
@@ -1565,18 +1112,15 @@ func parseClashSubscription(content string) ([]models.Proxy, error) {
 			currentProxy.AllowInsecure = skipStr == "true"
 		case strings.HasPrefix(line, "username:"):
 			// 存储用户名到RawConfig
-			username := strings.TrimSpace(strings.TrimPrefix(line, "username:"))
-			rawConfig := make(map[string]interface{})
-			if currentProxy.RawConfig != "" {
-				// 如果已有RawConfig，先解析
-				if err := json.Unmarshal([]byte(currentProxy.RawConfig), &rawConfig); err != nil {
-					rawConfig = make(map[string]interface{})
-				}
-			}
-			rawConfig["username"] = username
-			if jsonData, err := json.Marshal(rawConfig); err == nil {
-				currentProxy.RawConfig = string(jsonData)
-			}
+			setRawConfigValue(currentProxy, "username", strings.TrimSpace(strings.TrimPrefix(line, "username:")))
+		case strings.HasPrefix(line, "protocol:"):
+			setRawConfigValue(currentProxy, "protocol", strings.TrimSpace(strings.TrimPrefix(line, "protocol:")))
+		case strings.HasPrefix(line, "obfs:"):
+			setRawConfigValue(currentProxy, "obfs", strings.TrimSpace(strings.TrimPrefix(line, "obfs:")))
+		case strings.HasPrefix(line, "protocol-param:"):
+			setRawConfigValue(currentProxy, "protoparam", strings.TrimSpace(strings.TrimPrefix(line, "protocol-param:")))
+		case strings.HasPrefix(line, "obfs-param:"):
+			setRawConfigValue(currentProxy, "obfsparam", strings.TrimSpace(strings.TrimPrefix(line, "obfs-param:")))
 		}
 	}
 
@@ -1588,6 +1132,78 @@ func parseClashSubscription(content string) ([]models.Proxy, error) {
 	return proxies, nil
 }
 
+// setRawConfigValue 向节点的RawConfig写入单个字段
+func setRawConfigValue(proxy *models.Proxy, key string, value interface{}) {
+	rawConfig := make(map[string]interface{})
+	if proxy.RawConfig != "" {
+		// 如果已有RawConfig，先解析
+		if err := json.Unmarshal([]byte(proxy.RawConfig), &rawConfig); err != nil {
+			rawConfig = make(map[string]interface{})
+		}
+	}
+	rawConfig[key] = value
+	if jsonData, err := json.Marshal(rawConfig); err == nil {
+		proxy.RawConfig = string(jsonData)
+	}
+}
+
+// expandClashFlowProxies 将 "- {name: a, type: ss, ...}" 形式的单行节点展开为多行形式
+func expandClashFlowProxies(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "- {") || !strings.HasSuffix(trimmed, "}") {
+			continue
+		}
+
+		var name string
+		fields := make([]string, 0)
+		for _, pair := range splitClashFlowPairs(trimmed[3 : len(trimmed)-1]) {
+			kv := strings.SplitN(pair, ":", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			key := strings.TrimSpace(kv[0])
+			value := strings.Trim(strings.TrimSpace(kv[1]), `"'`)
+			if key == "name" {
+				name = value
+				continue
+			}
+			fields = append(fields, "  "+key+": "+value)
+		}
+		if name == "" {
+			continue
+		}
+		lines[i] = "- name: " + name + "\n" + strings.Join(fields, "\n")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// splitClashFlowPairs 按顶层逗号拆分YAML行内映射，忽略引号和嵌套括号中的逗号
+func splitClashFlowPairs(content string) []string {
+	var pairs []string
+	depth, start := 0, 0
+	var quote rune
+	for i, r := range content {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '[' || r == '{':
+			depth++
+		case r == ']' || r == '}':
+			depth--
+		case r == ',' && depth == 0:
+			pairs = append(pairs, content[start:i])
+			start = i + 1
+		}
+	}
+	return append(pairs, content[start:])
+}
+
 // parseSurgeSubscription 解析Surge格式的订阅
 func parseSurgeSubscription(content string) ([]models.Proxy, error) {
 	var proxies []models.Proxy
@@ -2381,6 +1997,10 @@ func parseProxyURI(uri string) (models.Proxy, error) {
 		return parseAnyTLSLink(uri)
 	case strings.HasPrefix(uri, "hysteria2://") || strings.HasPrefix(uri, "hy2://"):
 		return parseHysteria2Link(uri)
+	case strings.HasPrefix(uri, "hysteria://"):
+		return parseHysteriaLink(uri)
+	case strings.HasPrefix(uri, "wireguard://") || strings.HasPrefix(uri, "wg://"):
+		return parseWireGuardLink(uri)
 	case strings.HasPrefix(uri, "ssr://"):
 		return parseSSRLink(uri)
 	case strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://"):
@@ -2679,9 +2299,9 @@ func isValidProxy(proxy models.Proxy) bool {
 		return proxy.UUID != "" && proxy.Password != ""
 	case "anytls", "hysteria2":
 		return proxy.Password != ""
-	case "http":
-		return true
-	case "socks":
+	case "ssr":
+		return proxy.Method != "" && proxy.Password != ""
+	case "http", "socks", "hysteria", "wireguard":
 		return true
 	default:
 		return false
@@ -131,10 +131,11 @@ func TestParseHysteria2LinkSample(t *testing.T) {
 }
 
 func TestParseSubscriptionContentMixedSamples(t *testing.T) {
-	proxies, err := parseSubscriptionContent(sampleVmessLink+"\n"+sampleVlessLink+"\n"+sampleTuicLink+"\n"+sampleAnyTLSLink+"\n"+sampleHysteria2Link, "mixed")
+	result, err := parseSubscriptionContent(sampleVmessLink+"\n"+sampleVlessLink+"\n"+sampleTuicLink+"\n"+sampleAnyTLSLink+"\n"+sampleHysteria2Link, "mixed")
 	if err != nil {
 		t.Fatalf("parseSubscriptionContent() error = %v", err)
 	}
+	proxies := result.Proxies
 	if len(proxies) != 5 {
 		t.Fatalf("parseSubscriptionContent() returned %d proxies, want 5", len(proxies))
 	}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"proxy-subscription/models"
+)
+
+// UAFormatRule User-Agent到输出格式的映射规则，Keyword按不区分大小写的子串匹配
+type UAFormatRule struct {
+	Keyword string `json:"keyword"`
+	Format  string `json:"format"`
+}
+
+// DefaultUAFormatRules 默认的客户端识别规则，按顺序匹配第一个可用的格式
+var DefaultUAFormatRules = []UAFormatRule{
+	{Keyword: "sing-box", Format: "singbox"},
+	{Keyword: "SFA/", Format: "singbox"},
+	{Keyword: "SFI/", Format: "singbox"},
+	{Keyword: "SFM/", Format: "singbox"},
+	{Keyword: "SFT/", Format: "singbox"},
+	{Keyword: "mihomo", Format: "clash"},
+	{Keyword: "clash", Format: "clash"},
+	{Keyword: "stash", Format: "clash"},
+	{Keyword: "Shadowrocket", Format: "clash"},
+	{Keyword: "Surge", Format: "surge"},
+	{Keyword: "v2rayN", Format: "base64"},
+	{Keyword: "NekoRay", Format: "base64"},
+	{Keyword: "NekoBox", Format: "base64"},
+}
+
+// ValidateUAFormatRules 校验User-Agent映射规则，关键字和输出格式都不能为空，
+// supported不为nil时输出格式必须是其认可的格式
+func ValidateUAFormatRules(rules []UAFormatRule, supported func(string) bool) error {
+	for i, rule := range rules {
+		if strings.TrimSpace(rule.Keyword) == "" {
+			return fmt.Errorf("第 %d 条客户端识别规则缺少关键字", i+1)
+		}
+		if strings.TrimSpace(rule.Format) == "" {
+			return fmt.Errorf("第 %d 条客户端识别规则缺少输出格式", i+1)
+		}
+		if supported != nil && !supported(rule.Format) {
+			return fmt.Errorf("第 %d 条客户端识别规则的输出格式不受支持: %s", i+1, rule.Format)
+		}
+	}
+	return nil
+}
+
+// LoadUAFormatRules 从设置中读取User-Agent映射规则，未设置或无效时使用默认规则
+func LoadUAFormatRules() []UAFormatRule {
+	value := models.GetSetting(models.SettingUAFormatRules, "")
+	if value == "" {
+		return DefaultUAFormatRules
+	}
+	rules, err := ParseUAFormatRules(value)
+	if err != nil {
+		return DefaultUAFormatRules
+	}
+	return rules
+}
+
+// ParseUAFormatRules 解析JSON格式的User-Agent映射规则
+func ParseUAFormatRules(value string) ([]UAFormatRule, error) {
+	var rules []UAFormatRule
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return nil, errors.New("客户端识别规则格式无效")
+	}
+	if err := ValidateUAFormatRules(rules, nil); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// DetectFormatFromUserAgent 按规则顺序识别客户端，跳过supported返回false的格式，
+// 没有匹配时返回空字符串
+func DetectFormatFromUserAgent(userAgent string, rules []UAFormatRule, supported func(string) bool) string {
+	userAgent = strings.ToLower(userAgent)
+	if userAgent == "" {
+		return ""
+	}
+	for _, rule := range rules {
+		if !strings.Contains(userAgent, strings.ToLower(rule.Keyword)) {
+			continue
+		}
+		if supported != nil && !supported(rule.Format) {
+			continue
+		}
+		return rule.Format
+	}
+	return ""
+}
@@ -0,0 +1,70 @@
+package services
+
+import "testing"
+
+func TestDetectFormatFromUserAgent(t *testing.T) {
+	supported := func(format string) bool {
+		return format == "base64" || format == "clash" || format == "json" || format == "singbox" || format == "surge"
+	}
+
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{"ClashForAndroid/2.5.12", "clash"},
+		{"clash-verge/v1.7.7", "clash"},
+		{"mihomo/1.18.5", "clash"},
+		{"Stash/2.4.7 Clash/1.9.0", "clash"},
+		{"v2rayN/6.45", "base64"},
+		{"NekoBox/Android/1.3.3", "base64"},
+		{"Shadowrocket/2070 CFNetwork/1485 Darwin/23.1.0", "clash"},
+		{"Surge iOS/2920", "surge"},
+		{"Surge%20Mac/2460", "surge"},
+		{"SFA/1.8.0 (Android 14; sing-box 1.8.0)", "singbox"},
+		{"sing-box 1.9.0", "singbox"},
+		{"Quantumult%20X/1.4.1", ""},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64)", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.userAgent, func(t *testing.T) {
+			assertEqual(t, DetectFormatFromUserAgent(tt.userAgent, DefaultUAFormatRules, supported), tt.want, "format")
+		})
+	}
+}
+
+func TestParseUAFormatRules(t *testing.T) {
+	rules, err := ParseUAFormatRules(`[{"keyword":"MyClient","format":"json"}]`)
+	if err != nil {
+		t.Fatalf("ParseUAFormatRules() error = %v", err)
+	}
+	assertEqual(t, DetectFormatFromUserAgent("myclient/1.0", rules, nil), "json", "custom rule")
+
+	if _, err := ParseUAFormatRules(`[{"keyword":"","format":"json"}]`); err == nil {
+		t.Fatal("ParseUAFormatRules() accepted a rule without keyword")
+	}
+}
+
+func TestValidateUAFormatRules(t *testing.T) {
+	supported := func(format string) bool { return format == "clash" || format == "singbox" }
+
+	tests := []struct {
+		name    string
+		rules   []UAFormatRule
+		wantErr bool
+	}{
+		{"valid", []UAFormatRule{{Keyword: "mihomo", Format: "clash"}}, false},
+		{"unknown format", []UAFormatRule{{Keyword: "mihomo", Format: "clahs"}}, true},
+		{"empty keyword", []UAFormatRule{{Keyword: "mihomo", Format: "clash"}, {Keyword: " ", Format: "clash"}}, true},
+		{"empty format", []UAFormatRule{{Keyword: "mihomo", Format: ""}}, true},
+		{"empty rule", []UAFormatRule{{}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUAFormatRules(tt.rules, supported)
+			assertEqual(t, err != nil, tt.wantErr, "ValidateUAFormatRules() error")
+		})
+	}
+}
@@ -104,13 +104,16 @@ api.interceptors.response.use(
 export interface Subscription {
   id?: number;
   name: string;
+  source_type?: 'url' | 'file' | 'inline';
   url: string;
+  content?: string;
   type: string;
   enabled: boolean;
   lastUpdated?: string;
   createdAt?: string;
   updatedAt?: string;
   valid_proxy_count?: number;
+  detected_format?: string;
 }
 
 export interface Proxy {
@@ -119,8 +122,10 @@ export interface Proxy {
   is_custom?: boolean;
   manual_override?: boolean;
   source_key?: string;
+  sort_order?: number;
   name: string;
   display_name?: string;
+  flag?: string; // 名称推测国家的国旗 emoji
   type: string;
   server: string;
   port: number;
@@ -138,6 +143,27 @@ export interface Proxy {
   allow_insecure?: boolean;
   rawConfig?: string;
   subscription_name?: string;
+  health?: ProxyHealth | null;
+  // 按本地 GeoIP 数据库查询到的实际位置
+  geo_ip?: string;
+  geo_country?: string;
+  geo_asn?: number;
+  geo_as_org?: string;
+  geo_mismatch?: boolean;
+}
+
+// 节点健康检查汇总，success_rate 为最近24小时的成功率
+export interface ProxyHealth {
+  checked_at: string;
+  mode: string;
+  success: boolean;
+  latency_ms: number;
+  tls: boolean;
+  status_code?: number;
+  egress_ip?: string;
+  error?: string;
+  checks: number;
+  success_rate: number;
 }
 
 // 订阅相关API
@@ -151,6 +177,24 @@ export const subscriptionApi = {
 };
 
 // 代理节点相关API
+export interface ProxyImportResult {
+  line?: number;
+  status: 'created' | 'duplicate' | 'error';
+  id?: number;
+  name?: string;
+  type?: string;
+  input?: string;
+  error?: string;
+}
+
+export interface ProxyImportResponse {
+  format: string;
+  created: number;
+  duplicate: number;
+  failed: number;
+  results: ProxyImportResult[];
+}
+
 export const proxyApi = {
   getAll: (subscriptionId?: number) => {
     const params = subscriptionId ? { subscription_id: subscriptionId } : {};
@@ -160,6 +204,63 @@ export const proxyApi = {
   create: (proxy: Proxy) => api.post<Proxy>('/proxies', proxy),
   update: (id: number, proxy: Proxy) => api.put<Proxy>(`/proxies/${id}`, proxy),
   delete: (id: number) => api.delete(`/proxies/${id}`),
+  import: (content: string) => api.post<ProxyImportResponse>('/proxies/import', { content }),
+  checkAll: () => api.post('/proxies/health-check'),
+  check: (id: number) => api.post(`/proxies/${id}/health-check`),
+  protocolTest: (id: number, url?: string) => api.post<ProxyProbeResult>(`/proxies/${id}/protocol-test`, url ? { url } : {}),
+  // 按数组顺序保存手动排序，未列出的节点清除序号
+  reorder: (ids: number[]) => api.put('/proxies/order', { ids }),
+};
+
+// 单次探测结果，mode 为 tcp（端口探测）或 http（协议测试）
+export interface ProxyProbeResult {
+  endpoint: string;
+  mode: string;
+  success: boolean;
+  latency_ms: number;
+  tls: boolean;
+  status_code?: number;
+  egress_ip?: string;
+  error?: string;
+}
+
+// 规则集相关API，启用的规则集按 priority 从高到低在 Clash/sing-box 配置中引用
+export interface RuleEntry {
+  type: 'domain' | 'domain-suffix' | 'domain-keyword' | 'ip-cidr' | 'geoip' | 'process';
+  value: string;
+}
+
+export interface RuleSet {
+  id?: number;
+  name: string;
+  description?: string;
+  policy: 'proxy' | 'direct' | 'reject';
+  enabled: boolean;
+  priority: number;
+  rules: RuleEntry[];
+}
+
+export const ruleSetApi = {
+  getAll: () => api.get<RuleSet[]>('/rule-sets'),
+  getById: (id: number) => api.get<RuleSet>(`/rule-sets/${id}`),
+  create: (ruleSet: RuleSet) => api.post<RuleSet>('/rule-sets', ruleSet),
+  update: (id: number, ruleSet: RuleSet) => api.put<RuleSet>(`/rule-sets/${id}`, ruleSet),
+  delete: (id: number) => api.delete(`/rule-sets/${id}`),
+};
+
+// 链式代理相关API
+export interface ProxyChain {
+  id?: number;
+  proxy_id: number;
+  via_proxy_id: number;
+  proxy_name?: string;
+  via_name?: string;
+}
+
+export const proxyChainApi = {
+  getAll: () => api.get<ProxyChain[]>('/proxy-chains'),
+  create: (chain: ProxyChain) => api.post<ProxyChain>('/proxy-chains', chain),
+  delete: (id: number) => api.delete(`/proxy-chains/${id}`),
 };
 
 // 获取合并订阅链接
@@ -179,4 +280,17 @@ export const settingsApi = {
   }) => api.post('/settings', settings),
 };
 
+// 输出格式相关API
+export interface OutputFormat {
+  name: string;
+  content_type: string;
+  description: string;
+  supported_types: string[] | null;
+  supports_chains?: boolean;
+}
+
+export const formatApi = {
+  getAll: () => api.get<OutputFormat[]>('/formats'),
+};
+
 export default api;